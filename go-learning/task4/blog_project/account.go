@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 一次性 token 的用途
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeMFAPending    = "mfa_pending" // 密码已验证，等待输入两步验证码
+)
+
+var errActionTokenInvalid = errors.New("一次性 token 无效、已使用或已过期")
+
+// ActionClaims 一次性 token 中的数据；Email 用于在用户修改邮箱后让旧的验证链接失效
+type ActionClaims struct {
+	UserID  uint   `json:"uid"`
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// actionTokenKey 一次性 token 的签名密钥，由 JWT 密钥派生
+// 与访问 token 使用不同的密钥，两种 token 不能互相冒用
+func actionTokenKey() []byte {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte("action-token"))
+	return mac.Sum(nil)
+}
+
+// issueActionToken 签发一次性 token，同一用户同一用途之前未使用的 token 会被作废
+func issueActionToken(tx *gorm.DB, user User, purpose string, ttl time.Duration) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := tx.Model(&ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+	record := ActionToken{UserID: user.ID, Purpose: purpose, JTI: jti, ExpiresAt: now.Add(ttl)}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	claims := &ActionClaims{
+		UserID:  user.ID,
+		Purpose: purpose,
+		Email:   user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(record.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "your_blog_project",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(actionTokenKey())
+}
+
+// parseActionToken 校验一次性 token 的签名、有效期和用途，不检查是否已使用
+func parseActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	claims := &ActionClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("非预期的签名算法")
+		}
+		return actionTokenKey(), nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != purpose {
+		return nil, errActionTokenInvalid
+	}
+	return claims, nil
+}
+
+// markActionTokenUsed 把 token 标记为已使用
+// 标记使用与检查状态在同一条 UPDATE 中完成，并发提交同一个 token 时只有一个请求会成功
+func markActionTokenUsed(tx *gorm.DB, claims *ActionClaims) error {
+	now := time.Now()
+	result := tx.Model(&ActionToken{}).
+		Where("jti = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", claims.ID, claims.UserID, claims.Purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errActionTokenInvalid
+	}
+	return nil
+}
+
+// consumeActionToken 校验一次性 token 并标记为已使用，返回 token 对应的用户
+func consumeActionToken(tx *gorm.DB, tokenString, purpose string) (*User, error) {
+	claims, err := parseActionToken(tokenString, purpose)
+	if err != nil {
+		return nil, err
+	}
+	if err := markActionTokenUsed(tx, claims); err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := tx.First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errActionTokenInvalid
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, errActionTokenInvalid
+	}
+	return &user, nil
+}
+
+// mailLocale 邮件使用的语言：用户设置了语言偏好时优先使用
+func mailLocale(c *gin.Context, user User) string {
+	if validLocale(user.Locale) {
+		return user.Locale
+	}
+	return localeOf(c)
+}
+
+// actionLink 生成邮件中的链接
+func actionLink(path, token string) string {
+	return strings.TrimRight(currentConfig().Mail.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// shortDuration 把有效期格式化为 48h、30m 这样的写法
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// sendVerificationEmail 签发邮箱验证 token 并发送验证邮件
+func sendVerificationEmail(c *gin.Context, user User) error {
+	ttl := currentConfig().Auth.EmailVerificationTTL.Duration
+	token, err := issueActionToken(DB, user, PurposeVerifyEmail, ttl)
+	if err != nil {
+		return err
+	}
+	locale := mailLocale(c, user)
+	sendMailAsync(Email{
+		To:      user.Email,
+		Subject: translate(locale, "mail.verify_subject"),
+		Body:    translate(locale, "mail.verify_body", user.Username, shortDuration(ttl), actionLink("/api/v1/verify-email", token)),
+	})
+	return nil
+}
+
+// VerifyEmailHandler 验证邮箱；token 可以放在查询参数中（直接点击邮件中的链接），也可以放在请求体中
+func VerifyEmailHandler(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" form:"token" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	var user *User
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = consumeActionToken(tx, req.Token, PurposeVerifyEmail); err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return tx.Model(user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeVerificationTokenInvalid, "user.verification_token_invalid"))
+		} else {
+			abortWithError(c, internalError("user.verify_failed", err))
+		}
+		return
+	}
+
+	log.Printf("用户 %s 已验证邮箱", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.email_verified"), "user_id": user.ID})
+}
+
+// ResendVerificationHandler 重新发送验证邮件，之前发出的验证链接随之失效
+func ResendVerificationHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.Email == "" {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeEmailMissing, "user.email_missing"))
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		abortWithError(c, newAPIError(http.StatusConflict, CodeEmailAlreadyVerified, "user.email_already_verified"))
+		return
+	}
+	if err := sendVerificationEmail(c, user); err != nil {
+		abortWithError(c, internalError("user.verification_send_failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.verification_sent")})
+}
+
+// ForgotPasswordHandler 发送重置密码邮件
+// 无论邮箱是否注册都返回相同的响应，避免被用来探测注册邮箱
+func ForgotPasswordHandler(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	var user User
+	err := DB.Where("email = ?", req.Email).First(&user).Error
+	switch {
+	case err == nil:
+		ttl := currentConfig().Auth.PasswordResetTTL.Duration
+		token, err := issueActionToken(DB, user, PurposeResetPassword, ttl)
+		if err != nil {
+			abortWithError(c, internalError("auth.reset_request_failed", err))
+			return
+		}
+		locale := mailLocale(c, user)
+		sendMailAsync(Email{
+			To:      user.Email,
+			Subject: translate(locale, "mail.reset_subject"),
+			Body:    translate(locale, "mail.reset_body", user.Username, shortDuration(ttl), actionLink("/reset-password", token)),
+		})
+		log.Printf("已向用户 %s 发送重置密码邮件", user.Username)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		abortWithError(c, internalError("db.query_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.password_reset_sent")})
+}
+
+// ResetPasswordHandler 使用重置密码 token 设置新密码，吊销该用户所有的刷新 token，并解除账号锁定
+// 能收到重置邮件说明用户拥有该邮箱，所以同时把邮箱标记为已验证
+func ResetPasswordHandler(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	hashedPassword, err := HashPassword(req.Password)
+	if err != nil {
+		abortWithError(c, internalError("user.password_hash_failed", err))
+		return
+	}
+
+	var user *User
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = consumeActionToken(tx, req.Token, PurposeResetPassword); err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"password": hashedPassword}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 重置密码后解除因密码错误导致的锁定
+		if err := resetLoginFailures(tx, *user); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		if errors.Is(err, errActionTokenInvalid) {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeResetTokenInvalid, "auth.reset_token_invalid"))
+		} else {
+			abortWithError(c, internalError("auth.reset_failed", err))
+		}
+		return
+	}
+
+	log.Printf("用户 %s 已重置密码", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.password_reset")})
+}
+
+// accountVerified 判断账号是否已验证：邮箱已验证，或者是没有邮箱的钱包账号（钱包签名已经证明了身份）
+func accountVerified(user User) bool {
+	return user.EmailVerifiedAt != nil || (user.Email == "" && user.WalletAddress != nil)
+}
+
+// RequireVerifiedEmail 未验证邮箱的账号只能执行只读请求，需要放在 AuthMiddleware 之后
+// 访问 token 中的 email_verified 可能是签发时的旧值，所以为 false 时再查一次数据库
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		claimsVal, _ := c.Get("claims")
+		claims, ok := claimsVal.(*Claims)
+		if !ok {
+			abortWithError(c, errAuthRequired)
+			return
+		}
+		if !claims.EmailVerified {
+			var user User
+			if err := DB.Select("id", "email", "email_verified_at", "wallet_address").First(&user, claims.UserID).Error; err != nil {
+				abortWithError(c, toAPIError(err))
+				return
+			}
+			if !accountVerified(user) {
+				abortWithError(c, newAPIError(http.StatusForbidden, CodeEmailNotVerified, "auth.email_not_verified"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
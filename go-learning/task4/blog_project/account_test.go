@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureMailer 把发出的邮件放入通道，供测试取出邮件中的链接
+type captureMailer chan Email
+
+func (m captureMailer) Send(msg Email) error {
+	m <- msg
+	return nil
+}
+
+// useCaptureMailer 替换全局邮件驱动，测试结束后恢复
+func useCaptureMailer(t *testing.T) captureMailer {
+	t.Helper()
+	m := make(captureMailer, 10)
+	prev := mailer
+	mailer = m
+	t.Cleanup(func() { mailer = prev })
+	return m
+}
+
+// nextMail 等待下一封邮件
+func (m captureMailer) nextMail(t *testing.T) Email {
+	t.Helper()
+	select {
+	case msg := <-m:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有收到邮件")
+		return Email{}
+	}
+}
+
+// expectNoMail 确认没有发出邮件
+func (m captureMailer) expectNoMail(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-m:
+		t.Errorf("不应发送邮件: %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+var mailLinkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// mailToken 取出邮件链接中的一次性 token
+func mailToken(t *testing.T, msg Email) string {
+	t.Helper()
+	m := mailLinkToken.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("邮件中没有链接: %s", msg.Body)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestEmailVerificationFlow(t *testing.T) {
+	r := setupTestApp(t)
+	mails := useCaptureMailer(t)
+
+	w := doJSON(r, http.MethodPost, "/api/v1/register", "", map[string]string{
+		"username": "alice", "password": "Passw0rd!x", "email": "alice@example.com", "locale": LocaleEnUS,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("注册失败: %d %s", w.Code, w.Body.String())
+	}
+	first := mails.nextMail(t)
+	if first.To != "alice@example.com" || first.Subject != messages[LocaleEnUS]["mail.verify_subject"] {
+		t.Errorf("验证邮件应按用户的语言偏好发送: %+v", first)
+	}
+	if !strings.Contains(first.Body, "48h") {
+		t.Errorf("邮件中应写明有效期: %s", first.Body)
+	}
+
+	var user User
+	DB.Where("username = ?", "alice").First(&user)
+	token := testToken(t, user)
+	// 未验证邮箱的账号只能执行只读请求
+	w = doJSON(r, http.MethodPost, "/api/v1/posts", token, map[string]string{"title": "t", "content": "c"})
+	if w.Code != http.StatusForbidden || errorCode(t, w) != CodeEmailNotVerified {
+		t.Fatalf("未验证邮箱不能发文章: %d %s", w.Code, w.Body.String())
+	}
+
+	// 重新发送后旧链接失效
+	if w := doJSON(r, http.MethodPost, "/api/v1/verify-email/resend", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("重新发送验证邮件失败: %d %s", w.Code, w.Body.String())
+	}
+	second := mails.nextMail(t)
+	w = doJSON(r, http.MethodPost, "/api/v1/verify-email", "", map[string]string{"token": mailToken(t, first)})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeVerificationTokenInvalid {
+		t.Errorf("旧的验证链接应失效: %d %s", w.Code, w.Body.String())
+	}
+
+	// 直接点击邮件中的链接（GET + 查询参数）
+	w = doJSON(r, http.MethodGet, "/api/v1/verify-email?token="+url.QueryEscape(mailToken(t, second)), "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("验证邮箱失败: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodGet, "/api/v1/verify-email?token="+url.QueryEscape(mailToken(t, second)), "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("验证链接只能使用一次: %d", w.Code)
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/posts", token, map[string]string{"title": "t", "content": "c"})
+	if w.Code != http.StatusCreated {
+		t.Errorf("验证邮箱后旧 token 也应可以发文章: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/verify-email/resend", token, nil)
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeEmailAlreadyVerified {
+		t.Errorf("已验证的邮箱不能重新发送: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestActionTokenRules(t *testing.T) {
+	setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+
+	verify, err := issueActionToken(DB, user, PurposeVerifyEmail, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用途不同的 token 不能互相冒用
+	if _, err := consumeActionToken(DB, verify, PurposeResetPassword); err != errActionTokenInvalid {
+		t.Errorf("验证邮箱的 token 不能用于重置密码: %v", err)
+	}
+	// 访问 token 使用不同的密钥签名
+	if _, err := parseActionToken(testToken(t, user), PurposeVerifyEmail); err != errActionTokenInvalid {
+		t.Errorf("访问 token 不能当作一次性 token 使用: %v", err)
+	}
+
+	// 修改邮箱后，发往旧邮箱的链接失效
+	DB.Model(&user).Update("email", "new@example.com")
+	if _, err := consumeActionToken(DB, verify, PurposeVerifyEmail); err != errActionTokenInvalid {
+		t.Errorf("修改邮箱后旧链接应失效: %v", err)
+	}
+
+	user.Email = "new@example.com"
+	expired, err := issueActionToken(DB, user, PurposeVerifyEmail, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumeActionToken(DB, expired, PurposeVerifyEmail); err != errActionTokenInvalid {
+		t.Errorf("过期的 token 应失效: %v", err)
+	}
+
+	for d, want := range map[time.Duration]string{48 * time.Hour: "48h", time.Hour: "1h", 30 * time.Minute: "30m", 90 * time.Minute: "1h30m"} {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%v) = %q，期望 %q", d, got, want)
+		}
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	r := setupTestApp(t)
+	mails := useCaptureMailer(t)
+	user := createTestUser(t, "alice", RoleUser)
+	DB.Model(&user).Update("email_verified_at", nil)
+
+	login := func(password string) int {
+		t.Helper()
+		return doJSON(r, http.MethodPost, "/api/v1/login", "", map[string]string{"username": "alice", "password": password}).Code
+	}
+	w := doJSON(r, http.MethodPost, "/api/v1/login", "", map[string]string{"username": "alice", "password": "Passw0rd!x"})
+	refresh, _ := decodeJSON(t, w)["refresh_token"].(string)
+
+	// 未注册的邮箱返回相同的响应，但不发送邮件
+	unknown := doJSON(r, http.MethodPost, "/api/v1/password/forgot", "", map[string]string{"email": "nobody@example.com"})
+	mails.expectNoMail(t)
+	known := doJSON(r, http.MethodPost, "/api/v1/password/forgot", "", map[string]string{"email": user.Email})
+	if unknown.Code != http.StatusOK || unknown.Body.String() != known.Body.String() {
+		t.Errorf("是否注册的邮箱应返回相同的响应: %s / %s", unknown.Body.String(), known.Body.String())
+	}
+	token := mailToken(t, mails.nextMail(t))
+
+	w = doJSON(r, http.MethodPost, "/api/v1/password/reset", "", map[string]string{"token": token, "password": "short"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+		t.Errorf("新密码也要满足强度要求: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/password/reset", "", map[string]string{"token": token, "password": "N3wPassw0rd!"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("重置密码失败: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/password/reset", "", map[string]string{"token": token, "password": "An0therPass!"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeResetTokenInvalid {
+		t.Errorf("重置链接只能使用一次: %d %s", w.Code, w.Body.String())
+	}
+
+	if login("Passw0rd!x") != http.StatusUnauthorized || login("N3wPassw0rd!") != http.StatusOK {
+		t.Error("重置后应只能使用新密码登录")
+	}
+	// 重置密码吊销之前的会话，并把邮箱标记为已验证
+	w = doJSON(r, http.MethodPost, "/api/v1/token/refresh", "", map[string]string{"refresh_token": refresh})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("重置密码后旧的刷新 token 应失效: %d %s", w.Code, w.Body.String())
+	}
+	DB.First(&user, user.ID)
+	if user.EmailVerifiedAt == nil {
+		t.Error("能收到重置邮件说明拥有该邮箱，应标记为已验证")
+	}
+}
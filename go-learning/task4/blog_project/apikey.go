@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// API Key 供脚本和 CI 使用：请求头 Authorization: ApiKey <key>
+// 每个 key 只能访问创建时选择的权限范围（scope），同时仍然受用户角色权限的限制
+
+// apiKeyPrefix 所有 API Key 的前缀，方便在日志、代码仓库中识别泄露的 key
+const apiKeyPrefix = "blog_"
+
+// apiKeyDisplayLength 列表中展示的 key 开头部分的长度，用于区分不同的 key
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+// API Key 的权限范围
+const (
+	ScopeRead             = "read"              // 读取需要登录的数据，例如个人资料、修订历史
+	ScopePostsWrite       = "posts:write"       // 创建、编辑、删除文章，恢复修订版本
+	ScopeCommentsWrite    = "comments:write"    // 发表、编辑、删除评论
+	ScopeCommentsModerate = "comments:moderate" // 审核评论
+	ScopeAdmin            = "admin"             // 管理用户角色和安全策略
+)
+
+// apiKeyScopes 所有可选的权限范围
+var apiKeyScopes = []string{ScopeRead, ScopePostsWrite, ScopeCommentsWrite, ScopeCommentsModerate, ScopeAdmin}
+
+// validAPIKeyScope 判断权限范围是否存在
+func validAPIKeyScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateAPIKey 校验 API Key，并按 key 所属用户的当前状态构造 Claims
+func authenticateAPIKey(key string) (*Claims, *APIError) {
+	errInvalid := newAPIError(http.StatusUnauthorized, CodeAPIKeyInvalid, "auth.api_key_invalid")
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, errInvalid
+	}
+
+	var apiKey APIKey
+	if err := DB.Where("key_hash = ?", hashToken(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalid
+		}
+		return nil, internalError("auth.token_check_failed", err)
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil {
+		return nil, errInvalid
+	}
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		return nil, newAPIError(http.StatusUnauthorized, CodeAPIKeyExpired, "auth.api_key_expired")
+	}
+
+	var user User
+	if err := DB.First(&user, apiKey.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalid
+		}
+		return nil, internalError("auth.token_check_failed", err)
+	}
+
+	// 最近使用时间只用于展示，每分钟最多更新一次
+	if err := DB.Model(&APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", apiKey.ID, now.Add(-time.Minute)).
+		Update("last_used_at", now).Error; err != nil {
+		log.Printf("更新 API Key 最近使用时间失败: %v", err)
+	}
+
+	return &Claims{
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		Locale:        user.Locale,
+		EmailVerified: accountVerified(user),
+		MFA:           user.TOTPEnabledAt != nil,
+		APIKeyID:      apiKey.ID,
+		Scopes:        strings.Fields(apiKey.Scopes),
+	}, nil
+}
+
+// hasScope 判断请求是否拥有权限范围；使用访问 token（交互式登录）时不受权限范围限制
+func hasScope(claims *Claims, scope string) bool {
+	if claims.APIKeyID == 0 {
+		return true
+	}
+	for _, s := range claims.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope 使用 API Key 访问时要求 key 拥有指定的权限范围，需要放在 AuthMiddleware 之后
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimsVal, _ := c.Get("claims")
+		claims, ok := claimsVal.(*Claims)
+		if !ok {
+			abortWithError(c, errAuthRequired)
+			return
+		}
+		if !hasScope(claims, scope) {
+			abortWithError(c, newAPIError(http.StatusForbidden, CodeInsufficientScope, "auth.insufficient_scope", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 只允许交互式登录的访问 token，API Key 不能管理账号（例如创建新的 key、修改两步验证）
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimsVal, _ := c.Get("claims")
+		claims, ok := claimsVal.(*Claims)
+		if !ok {
+			abortWithError(c, errAuthRequired)
+			return
+		}
+		if claims.APIKeyID != 0 {
+			abortWithError(c, newAPIError(http.StatusForbidden, CodeAPIKeyNotAllowed, "auth.api_key_not_allowed"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// normalizeScopes 去掉重复的权限范围，并按固定顺序排列
+func normalizeScopes(scopes []string) []string {
+	var result []string
+	for _, scope := range apiKeyScopes {
+		for _, s := range scopes {
+			if s == scope {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// apiKeyView API Key 的公开信息，不包含 key 本身
+func apiKeyView(key APIKey) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"prefix":       key.Prefix,
+		"scopes":       strings.Fields(key.Scopes),
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"created_at":   key.CreatedAt,
+	}
+}
+
+// APIKeyCreateRequest 创建 API Key 的请求体，expires_at 为空表示永不过期
+type APIKeyCreateRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,apiscope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyHandler 创建 API Key，key 的明文只在这里返回一次
+func CreateAPIKeyHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("创建 API Key 请求: 用户ID=%v", userID)
+
+	var req APIKeyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidAPIKeyExpiry, "api_key.invalid_expires_at"))
+		return
+	}
+
+	secret, err := randomToken(24)
+	if err != nil {
+		abortWithError(c, internalError("api_key.create_failed", err))
+		return
+	}
+	key := apiKeyPrefix + secret
+	apiKey := APIKey{
+		UserID:    userID.(uint),
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   hashToken(key),
+		Scopes:    strings.Join(normalizeScopes(req.Scopes), " "),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := DB.Create(&apiKey).Error; err != nil {
+		abortWithError(c, internalError("api_key.create_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": T(c, "api_key.created"),
+		"key":     key,
+		"api_key": apiKeyView(apiKey),
+	})
+}
+
+// ListAPIKeysHandler 列出当前用户的 API Key（包括已吊销的）
+func ListAPIKeysHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var keys []APIKey
+	if err := DB.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		abortWithError(c, internalError("api_key.list_failed", err))
+		return
+	}
+
+	views := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		views = append(views, apiKeyView(key))
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": views})
+}
+
+// RevokeAPIKeyHandler 吊销当前用户的一个 API Key，重复吊销不会报错
+func RevokeAPIKeyHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("吊销 API Key 请求: key ID=%s, 用户ID=%v", c.Param("id"), userID)
+
+	keyID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidID)
+		return
+	}
+	var apiKey APIKey
+	if err := DB.Where("user_id = ?", userID).First(&apiKey, keyID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, newAPIError(http.StatusNotFound, CodeAPIKeyNotFound, "api_key.not_found"))
+		} else {
+			abortWithError(c, internalError("api_key.revoke_failed", err))
+		}
+		return
+	}
+	if apiKey.RevokedAt == nil {
+		if err := DB.Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+			abortWithError(c, internalError("api_key.revoke_failed", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "api_key.revoked"), "api_key": apiKeyView(apiKey)})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// createAPIKey 通过接口创建 API Key，返回明文 key 和 ID
+func createAPIKey(t *testing.T, r http.Handler, token string, scopes ...string) (string, uint) {
+	t.Helper()
+	w := doJSON(r, http.MethodPost, "/api/v1/api-keys", token, map[string]interface{}{"name": "ci", "scopes": scopes})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建 API Key 失败: %d %s", w.Code, w.Body.String())
+	}
+	body := decodeJSON(t, w)
+	return body["key"].(string), uint(body["api_key"].(map[string]interface{})["id"].(float64))
+}
+
+// doWithAPIKey 使用 API Key 发送请求，返回状态码和错误码
+func doWithAPIKey(t *testing.T, r http.Handler, method, path, key string, body interface{}) (int, string) {
+	t.Helper()
+	w := doJSON(r, method, path, "", body, "Authorization", "ApiKey "+key)
+	return w.Code, errorCode(t, w)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	readKey, _ := createAPIKey(t, r, token, ScopeRead)
+	writeKey, _ := createAPIKey(t, r, token, ScopePostsWrite, ScopeRead, ScopePostsWrite)
+	adminKey, _ := createAPIKey(t, r, token, ScopeAdmin)
+	newPost := map[string]string{"title": "from ci", "content": "content"}
+
+	tests := []struct {
+		name     string
+		key      string
+		method   string
+		path     string
+		body     interface{}
+		wantCode int
+		wantErr  string
+	}{
+		{"read 可以读取个人资料", readKey, http.MethodGet, "/api/v1/profile", nil, http.StatusOK, ""},
+		{"read 不能发文章", readKey, http.MethodPost, "/api/v1/posts", newPost, http.StatusForbidden, CodeInsufficientScope},
+		{"posts:write 可以发文章", writeKey, http.MethodPost, "/api/v1/posts", newPost, http.StatusCreated, ""},
+		{"posts:write 不能发评论", writeKey, http.MethodPost, "/api/v1/posts/1/comments", map[string]string{"content": "c"}, http.StatusForbidden, CodeInsufficientScope},
+		{"admin 范围仍受用户角色限制", adminKey, http.MethodGet, "/api/v1/admin/role-policies", nil, http.StatusForbidden, CodeForbidden},
+		{"API Key 不能管理 API Key", readKey, http.MethodGet, "/api/v1/api-keys", nil, http.StatusForbidden, CodeAPIKeyNotAllowed},
+		{"缺少前缀的 key", "not-a-key", http.MethodGet, "/api/v1/profile", nil, http.StatusUnauthorized, CodeAPIKeyInvalid},
+		{"不存在的 key", apiKeyPrefix + "0000", http.MethodGet, "/api/v1/profile", nil, http.StatusUnauthorized, CodeAPIKeyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, errCode := doWithAPIKey(t, r, tt.method, tt.path, tt.key, tt.body)
+			if code != tt.wantCode || errCode != tt.wantErr {
+				t.Errorf("期望 %d %s，得到 %d %s", tt.wantCode, tt.wantErr, code, errCode)
+			}
+		})
+	}
+
+	// 重复的权限范围被去掉，并按固定顺序保存
+	var key APIKey
+	DB.Where("key_hash = ?", hashToken(writeKey)).First(&key)
+	if key.Scopes != ScopeRead+" "+ScopePostsWrite {
+		t.Errorf("权限范围应去重并排序，得到 %q", key.Scopes)
+	}
+}
+
+func TestAPIKeyCreateValidation(t *testing.T) {
+	r := setupTestApp(t)
+	token := testToken(t, createTestUser(t, "alice", RoleUser))
+
+	for name, body := range map[string]map[string]interface{}{
+		"未知的权限范围": {"name": "ci", "scopes": []string{"posts:delete"}},
+		"缺少权限范围":  {"name": "ci", "scopes": []string{}},
+		"过去的过期时间": {"name": "ci", "scopes": []string{ScopeRead}, "expires_at": time.Now().Add(-time.Hour)},
+	} {
+		if w := doJSON(r, http.MethodPost, "/api/v1/api-keys", token, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s 应返回 400: %d %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAPIKeyExpiryAndRevocation(t *testing.T) {
+	r := setupTestApp(t)
+	alice := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, alice)
+	key, id := createAPIKey(t, r, token, ScopeRead)
+
+	if code, _ := doWithAPIKey(t, r, http.MethodGet, "/api/v1/profile", key, nil); code != http.StatusOK {
+		t.Fatalf("新创建的 key 应可以使用: %d", code)
+	}
+	var stored APIKey
+	DB.First(&stored, id)
+	if stored.LastUsedAt == nil {
+		t.Error("使用后应记录最近使用时间")
+	}
+
+	// 过期
+	DB.Model(&APIKey{}).Where("id = ?", id).Update("expires_at", time.Now().Add(-time.Second))
+	if code, errCode := doWithAPIKey(t, r, http.MethodGet, "/api/v1/profile", key, nil); code != http.StatusUnauthorized || errCode != CodeAPIKeyExpired {
+		t.Errorf("过期的 key 应返回 401 %s: %d %s", CodeAPIKeyExpired, code, errCode)
+	}
+	DB.Model(&APIKey{}).Where("id = ?", id).Update("expires_at", nil)
+
+	// 其他用户不能吊销，ID 必须是数字
+	other := testToken(t, createTestUser(t, "bob", RoleUser))
+	path := fmt.Sprintf("/api/v1/api-keys/%d", id)
+	if w := doJSON(r, http.MethodDelete, path, other, nil); w.Code != http.StatusNotFound || errorCode(t, w) != CodeAPIKeyNotFound {
+		t.Errorf("吊销其他用户的 key 应返回 404: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, http.MethodDelete, "/api/v1/api-keys/1%20OR%201=1", token, nil); w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidRequest {
+		t.Errorf("非数字 ID 应返回 400: %d %s", w.Code, w.Body.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := doJSON(r, http.MethodDelete, path, token, nil); w.Code != http.StatusOK {
+			t.Fatalf("第 %d 次吊销应成功: %d %s", i+1, w.Code, w.Body.String())
+		}
+	}
+	if code, errCode := doWithAPIKey(t, r, http.MethodGet, "/api/v1/profile", key, nil); code != http.StatusUnauthorized || errCode != CodeAPIKeyInvalid {
+		t.Errorf("吊销后的 key 应返回 401: %d %s", code, errCode)
+	}
+	w := doJSON(r, http.MethodGet, "/api/v1/api-keys", token, nil)
+	keys := decodeJSON(t, w)["api_keys"].([]interface{})
+	if len(keys) != 1 || keys[0].(map[string]interface{})["revoked_at"] == nil {
+		t.Errorf("列表中应保留已吊销的 key 并标明吊销时间: %s", w.Body.String())
+	}
+}
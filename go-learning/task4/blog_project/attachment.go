@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 文章附件：作者通过 multipart 表单上传，文件类型按内容识别，图片会生成缩略图
+// 下载接口与文章的可见性一致，作者未发布的文章的附件其他人也无法下载
+
+// thumbnailMaxPixels 生成缩略图时允许的最大像素数，防止很小的压缩文件解码出巨大的图片耗尽内存
+const thumbnailMaxPixels = 40 * 1000 * 1000
+
+// multipartOverhead 请求体中除文件内容以外的 multipart 边界和表单字段允许占用的字节数
+const multipartOverhead = 1 << 20
+
+// uploadExtensions 按识别出的类型决定保存时的扩展名，不使用客户端提供的扩展名
+var uploadExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+var errAttachmentNotFound = newAPIError(http.StatusNotFound, CodeAttachmentNotFound, "attachment.not_found")
+
+// AfterFind 填充附件的下载地址
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.fillURLs()
+	return nil
+}
+
+// fillURLs 附件通过接口下载，而不是直接暴露存储地址
+func (a *Attachment) fillURLs() {
+	a.URL = fmt.Sprintf("/api/v1/attachments/%d", a.ID)
+	a.ThumbnailURL = ""
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = a.URL + "/thumbnail"
+	}
+}
+
+// sniffContentType 按文件开头的内容识别 MIME 类型，返回完整类型（可能带 charset）和不带参数的类型
+func sniffContentType(data []byte) (string, string) {
+	contentType := http.DetectContentType(data)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType, contentType
+	}
+	return contentType, mediaType
+}
+
+// allowedUploadType 判断类型是否在 uploads.allowed_types 中
+func allowedUploadType(mediaType string, allowed []string) bool {
+	for _, t := range allowed {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFilename 只保留原始文件名的最后一段，去掉控制字符，并限制在 255 个字节以内
+func cleanFilename(name, ext string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "file" + ext
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// readUpload 读取上传的文件，超过 maxSize 时返回 errFileTooLarge
+func readUpload(fh *multipart.FileHeader, maxSize int) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, errFileTooLarge(maxSize)
+	}
+	return data, nil
+}
+
+// errFileTooLarge 文件超过大小限制，提示以 MB 为单位的上限
+func errFileTooLarge(maxSize int) *APIError {
+	return newAPIError(http.StatusRequestEntityTooLarge, CodeFileTooLarge, "attachment.too_large",
+		fmt.Sprintf("%.1f", float64(maxSize)/(1<<20)))
+}
+
+// makeThumbnail 解码图片并生成最长边不超过 maxSide 的缩略图；JPEG 输出 JPEG，其余输出 PNG 以保留透明度
+// 不支持解码的图片类型（如 WebP）返回 nil
+func makeThumbnail(data []byte, mediaType string, maxSide int) (width, height int, thumb []byte, err error) {
+	if mediaType != "image/jpeg" && mediaType != "image/png" && mediaType != "image/gif" {
+		return 0, 0, nil, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if cfg.Width*cfg.Height > thumbnailMaxPixels {
+		return cfg.Width, cfg.Height, nil, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	dst := resizeImage(src, maxSide)
+	var buf bytes.Buffer
+	if mediaType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return cfg.Width, cfg.Height, buf.Bytes(), nil
+}
+
+// resizeImage 按比例缩小图片，每个目标像素取对应源区域的平均值（盒式滤波），不会放大图片
+func resizeImage(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			tw, th = maxSide, max(1, h*maxSide/w)
+		} else {
+			tw, th = max(1, w*maxSide/h), maxSide
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// thumbnailContentType 缩略图的类型，与 makeThumbnail 的输出格式一致
+func thumbnailContentType(key string) string {
+	if strings.HasSuffix(key, ".jpg") {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// UploadAttachmentHandler 给文章上传附件，表单字段名为 file
+func UploadAttachmentHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("上传附件请求: 文章ID=%s, 用户ID=%v", c.Param("id"), userID)
+	cfg := currentConfig().Uploads
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidPostID)
+		return
+	}
+
+	var post Post
+	if err := DB.Select("id").First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errPostNotFound)
+		} else {
+			abortWithError(c, internalError("post.get_failed", err))
+		}
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(cfg.MaxSize)+multipartOverhead)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			abortWithError(c, errFileTooLarge(cfg.MaxSize))
+		case errors.Is(err, http.ErrMissingFile), errors.Is(err, http.ErrNotMultipart):
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeAttachmentFileRequired, "attachment.file_required"))
+		default:
+			abortWithError(c, badRequest(err))
+		}
+		return
+	}
+	if fh.Size > int64(cfg.MaxSize) {
+		abortWithError(c, errFileTooLarge(cfg.MaxSize))
+		return
+	}
+	data, err := readUpload(fh, cfg.MaxSize)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+
+	// 客户端声明的 Content-Type 和扩展名都不可信，只按文件内容识别
+	contentType, mediaType := sniffContentType(data)
+	if !allowedUploadType(mediaType, cfg.AllowedTypes) {
+		abortWithError(c, newAPIError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "attachment.unsupported_type", mediaType).
+			WithDetails(gin.H{"allowed_types": cfg.AllowedTypes}))
+		return
+	}
+	ext, ok := uploadExtensions[mediaType]
+	if !ok {
+		ext = ".bin"
+	}
+
+	width, height, thumb, err := makeThumbnail(data, mediaType, cfg.ThumbnailSize)
+	if err != nil {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidImage, "attachment.invalid_image").Wrap(err))
+		return
+	}
+
+	name, err := randomToken(16)
+	if err != nil {
+		abortWithError(c, internalError("attachment.upload_failed", err))
+		return
+	}
+	attachment := Attachment{
+		PostID:      post.ID,
+		UserID:      userID.(uint),
+		Filename:    cleanFilename(fh.Filename, ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       width,
+		Height:      height,
+		StorageKey:  fmt.Sprintf("attachments/%d/%s%s", post.ID, name, ext),
+	}
+
+	ctx := c.Request.Context()
+	if err := storage.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		abortWithError(c, internalError("attachment.upload_failed", err))
+		return
+	}
+	if thumb != nil {
+		thumbExt := ".png"
+		if mediaType == "image/jpeg" {
+			thumbExt = ".jpg"
+		}
+		attachment.ThumbnailKey = fmt.Sprintf("attachments/%d/%s_thumb%s", post.ID, name, thumbExt)
+		if err := storage.Put(ctx, attachment.ThumbnailKey, bytes.NewReader(thumb), int64(len(thumb)), thumbnailContentType(attachment.ThumbnailKey)); err != nil {
+			deleteStoredObjects(attachment)
+			abortWithError(c, internalError("attachment.upload_failed", err))
+			return
+		}
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attachment).Error; err != nil {
+			return err
+		}
+		return touchPost(tx, post.ID)
+	})
+	if err != nil {
+		deleteStoredObjects(attachment)
+		abortWithError(c, internalError("attachment.upload_failed", err))
+		return
+	}
+	attachment.fillURLs()
+
+	log.Printf("附件上传成功: 附件ID=%d, 文章ID=%d, 类型=%s, 大小=%d", attachment.ID, post.ID, mediaType, attachment.Size)
+	c.JSON(http.StatusCreated, gin.H{"message": T(c, "attachment.uploaded"), "attachment": attachment})
+}
+
+// deleteStoredObjects 删除附件在存储中的文件，失败时只记录日志
+func deleteStoredObjects(attachment Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Delete(context.Background(), key); err != nil {
+			log.Printf("删除存储中的文件 %s 失败: %v", key, err)
+		}
+	}
+}
+
+// GetPostAttachmentsHandler 获取文章的附件列表
+func GetPostAttachmentsHandler(c *gin.Context) {
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidPostID)
+		return
+	}
+
+	var post Post
+	if err := DB.Scopes(visiblePosts(c)).Select("id").First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errPostNotFound)
+		} else {
+			abortWithError(c, internalError("post.get_failed", err))
+		}
+		return
+	}
+
+	var attachments []Attachment
+	if err := DB.Where("post_id = ?", post.ID).Order("id").Find(&attachments).Error; err != nil {
+		abortWithError(c, internalError("attachment.list_failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "attachment.listed"), "attachments": attachments})
+}
+
+// findVisibleAttachment 查找附件，所属文章对当前用户不可见时按不存在处理
+func findVisibleAttachment(c *gin.Context) (*Attachment, *APIError) {
+	attachmentID, ok := parseID(c.Param("id"))
+	if !ok {
+		return nil, errInvalidID
+	}
+	var attachment Attachment
+	if err := DB.First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errAttachmentNotFound
+		}
+		return nil, internalError("attachment.get_failed", err)
+	}
+	var count int64
+	if err := DB.Model(&Post{}).Scopes(visiblePosts(c)).Where("posts.id = ?", attachment.PostID).Count(&count).Error; err != nil {
+		return nil, internalError("attachment.get_failed", err)
+	}
+	if count == 0 {
+		return nil, errAttachmentNotFound
+	}
+	return &attachment, nil
+}
+
+// serveAttachment 从存储中读取文件返回给客户端
+// 图片以 inline 方式返回，其他文件强制下载；同时禁止浏览器猜测类型，避免上传的文件被当作 HTML 执行
+func serveAttachment(c *gin.Context, thumbnail bool) {
+	attachment, apiErr := findVisibleAttachment(c)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	key, contentType, size := attachment.StorageKey, attachment.ContentType, attachment.Size
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			abortWithError(c, errAttachmentNotFound)
+			return
+		}
+		key, contentType, size = attachment.ThumbnailKey, thumbnailContentType(attachment.ThumbnailKey), -1
+	}
+
+	body, err := storage.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			abortWithError(c, errAttachmentNotFound)
+		} else {
+			abortWithError(c, internalError("attachment.download_failed", err))
+		}
+		return
+	}
+	defer body.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+	}
+	if v := mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}); v != "" {
+		headers["Content-Disposition"] = v
+	} else {
+		headers["Content-Disposition"] = disposition
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, body, headers)
+}
+
+// DownloadAttachmentHandler 下载附件
+func DownloadAttachmentHandler(c *gin.Context) {
+	serveAttachment(c, false)
+}
+
+// AttachmentThumbnailHandler 获取图片附件的缩略图
+func AttachmentThumbnailHandler(c *gin.Context) {
+	serveAttachment(c, true)
+}
+
+// attachmentOwner 返回路由参数 :id 对应附件所属文章的作者
+func attachmentOwner(c *gin.Context) (uint, error) {
+	attachmentID, ok := parseID(c.Param("id"))
+	if !ok {
+		return 0, errInvalidID
+	}
+	var attachment Attachment
+	if err := DB.Select("id", "post_id").First(&attachment, attachmentID).Error; err != nil {
+		return 0, err
+	}
+	var post Post
+	if err := DB.Select("id", "user_id").First(&post, attachment.PostID).Error; err != nil {
+		return 0, err
+	}
+	return post.UserID, nil
+}
+
+// DeleteAttachmentHandler 删除附件（文章作者或能编辑任意文章的角色）
+func DeleteAttachmentHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("删除附件请求: 附件ID=%s, 用户ID=%v", c.Param("id"), userID)
+	attachmentID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	var attachment Attachment
+	if err := DB.First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errAttachmentNotFound)
+		} else {
+			abortWithError(c, internalError("attachment.get_failed", err))
+		}
+		return
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		return touchPost(tx, attachment.PostID)
+	})
+	if err != nil {
+		abortWithError(c, internalError("attachment.delete_failed", err))
+		return
+	}
+	// 先删除记录再删除文件：删除文件失败只会留下无人引用的文件，不会出现记录指向不存在的文件
+	deleteStoredObjects(attachment)
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "attachment.deleted")})
+}
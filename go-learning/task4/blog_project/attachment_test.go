@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadFile 以 multipart 表单上传文件，contentType 是客户端声明的类型
+func uploadFile(r http.Handler, path, token, filename, contentType string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	header.Set("Content-Type", contentType)
+	part, _ := mw.CreatePart(header)
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSniffContentType(t *testing.T) {
+	allowed := defaultConfig().Uploads.AllowedTypes
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		allowed bool
+	}{
+		{"PNG", testPNG(t, 2, 2), "image/png", true},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"), "image/gif", true},
+		{"JPEG", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), "image/jpeg", true},
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf", true},
+		{"纯文本", []byte("hello world"), "text/plain", true},
+		{"HTML", []byte("<!DOCTYPE html><script>alert(1)</script>"), "text/html", false},
+		{"SVG", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`), "text/xml", false},
+		// 没有 XML 声明的 SVG 识别为纯文本，下载时以 text/plain 和 nosniff 返回，浏览器不会执行
+		{"没有声明的 SVG", []byte(`<svg onload="alert(1)"/>`), "text/plain", true},
+		{"可执行文件", []byte("MZ\x90\x00\x03\x00\x00\x00"), "application/octet-stream", false},
+		{"ZIP", []byte("PK\x03\x04\x14\x00"), "application/zip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mediaType := sniffContentType(tt.data)
+			if mediaType != tt.want {
+				t.Errorf("识别为 %s，期望 %s", mediaType, tt.want)
+			}
+			if got := allowedUploadType(mediaType, allowed); got != tt.allowed {
+				t.Errorf("allowedUploadType(%s) = %v，期望 %v", mediaType, got, tt.allowed)
+			}
+		})
+	}
+	if !allowedUploadType("IMAGE/PNG", allowed) {
+		t.Error("类型比较应忽略大小写")
+	}
+}
+
+func TestUploadAttachment(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) {
+		cfg.Uploads.MaxSize = 4 << 10
+		cfg.Uploads.ThumbnailSize = 8
+	})
+	author := createTestUser(t, "author", RoleUser)
+	other := createTestUser(t, "other", RoleUser)
+	token := testToken(t, author)
+	post := createTestPost(t, author, "with attachments", "content")
+	path := fmt.Sprintf("/api/v1/posts/%d/attachments", post.ID)
+
+	// 声明为图片的 HTML 按内容识别后被拒绝
+	w := uploadFile(r, path, token, "cat.png", "image/png", []byte("<html><script>alert(1)</script></html>"))
+	assertAPIError(t, w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "")
+
+	// 文件超过 uploads.max_size
+	w = uploadFile(r, path, token, "big.txt", "text/plain", bytes.Repeat([]byte("a"), 5<<10))
+	assertAPIError(t, w, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "")
+	// 请求体超过 MaxBytesReader 的上限，在读完之前就被截断
+	w = uploadFile(r, path, token, "huge.txt", "text/plain", bytes.Repeat([]byte("a"), multipartOverhead+8<<10))
+	assertAPIError(t, w, http.StatusRequestEntityTooLarge, CodeFileTooLarge, "")
+
+	// 不是作者不能上传
+	w = uploadFile(r, path, testToken(t, other), "a.png", "image/png", testPNG(t, 2, 2))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("非作者上传应返回 403: %d %s", w.Code, w.Body.String())
+	}
+
+	// 类型按内容识别，不采用客户端声明的类型
+	data := testPNG(t, 32, 16)
+	w = uploadFile(r, path, token, "../../photo.bin", "application/x-msdownload", data)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("上传失败: %d %s", w.Code, w.Body.String())
+	}
+	var attachment Attachment
+	if err := DB.Order("id desc").First(&attachment).Error; err != nil {
+		t.Fatal(err)
+	}
+	if attachment.ContentType != "image/png" || attachment.Filename != "photo.bin" ||
+		!strings.HasSuffix(attachment.StorageKey, ".png") || attachment.Width != 32 || attachment.Height != 16 {
+		t.Fatalf("附件信息错误: %+v", attachment)
+	}
+
+	w = doJSON(r, http.MethodGet, attachment.URL, "", nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+		t.Fatalf("下载失败: %d", w.Code)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("下载响应头错误: %v", w.Header())
+	}
+	w = doJSON(r, http.MethodGet, attachment.ThumbnailURL, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("下载缩略图失败: %d", w.Code)
+	}
+	thumb, err := png.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+	if err != nil || thumb.Width != 8 || thumb.Height != 4 {
+		t.Fatalf("缩略图尺寸错误: %+v %v", thumb, err)
+	}
+
+	// 删除附件同时删除存储中的文件
+	w = doJSON(r, http.MethodDelete, fmt.Sprintf("/api/v1/attachments/%d", attachment.ID), token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("删除附件失败: %d %s", w.Code, w.Body.String())
+	}
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if _, err := storage.Get(context.Background(), key); !errors.Is(err, errObjectNotFound) {
+			t.Errorf("删除附件后 %s 应从存储中删除: %v", key, err)
+		}
+	}
+}
+
+func TestAttachmentInvalidID(t *testing.T) {
+	r := setupTestApp(t)
+	token := testToken(t, createTestUser(t, "admin", RoleAdmin))
+
+	// 非数字的 ID 直接返回 400，不会拼进查询
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/posts/1%20OR%201=1/attachments"},
+		{http.MethodPost, "/api/v1/posts/1%20OR%201=1/attachments"},
+		{http.MethodGet, "/api/v1/attachments/1%20OR%201=1"},
+		{http.MethodGet, "/api/v1/attachments/abc/thumbnail"},
+		{http.MethodDelete, "/api/v1/attachments/0"},
+		{http.MethodDelete, "/api/v1/attachments/1;DROP%20TABLE%20attachments"},
+	} {
+		w := doJSON(r, req.method, req.path, token, nil)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidRequest {
+			t.Errorf("%s %s 应返回 400: %d %s", req.method, req.path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// runCommand 处理命令行子命令，例如 `blog_project search-reindex`
+// 没有子命令时返回 false，由 main 继续启动 HTTP 服务
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "serve":
+		return false
+	case "search-reindex":
+		InitDatabase()
+		if err := rebuildSearchIndex(DB); err != nil {
+			log.Fatalf("重建全文索引失败: %v", err)
+		}
+		log.Println("全文索引重建完成。")
+	case "migrate":
+		runMigrateCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", args[0])
+		fmt.Fprintln(os.Stderr, "可用命令:")
+		fmt.Fprintln(os.Stderr, "  serve            启动 HTTP 服务（默认）")
+		fmt.Fprintln(os.Stderr, "  search-reindex   重建全文检索索引（仅 SQLite）")
+		fmt.Fprintln(os.Stderr, "  migrate <cmd>    数据库迁移，cmd 为 up / down [n] / status / redo")
+		os.Exit(2)
+	}
+	return true
+}
+
+// runMigrateCommand 处理 `blog_project migrate up|down [n]|status|redo`
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: blog_project migrate up | down [n] | status | redo")
+		os.Exit(2)
+	}
+	connectDatabase()
+
+	switch args[0] {
+	case "up":
+		n, err := migrateUp(DB)
+		if err != nil {
+			log.Fatalf("迁移失败: %v", err)
+		}
+		log.Printf("迁移完成，共执行 %d 个迁移。", n)
+	case "down":
+		steps, err := parseSteps(args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := migrateDown(DB, steps)
+		if err != nil {
+			log.Fatalf("回滚失败: %v", err)
+		}
+		log.Printf("回滚完成，共回滚 %d 个迁移。", n)
+	case "status":
+		if err := printMigrationStatus(DB, os.Stdout); err != nil {
+			log.Fatalf("获取迁移状态失败: %v", err)
+		}
+	case "redo":
+		// 回滚最近一次迁移后重新执行，用于开发时调试迁移
+		if _, err := migrateDown(DB, 1); err != nil {
+			log.Fatalf("回滚失败: %v", err)
+		}
+		if _, err := migrateUp(DB); err != nil {
+			log.Fatalf("迁移失败: %v", err)
+		}
+		log.Println("已重新执行最近一次迁移。")
+	default:
+		fmt.Fprintf(os.Stderr, "未知的迁移命令: %s\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 评论审核状态
+const (
+	CommentStatusPending  = "pending"  // 待审核，不公开展示
+	CommentStatusApproved = "approved" // 已通过，公开展示
+	CommentStatusRejected = "rejected" // 审核未通过
+	CommentStatusHidden   = "hidden"   // 通过后又被版主隐藏
+)
+
+var errInvalidModerationStatus = newAPIError(http.StatusBadRequest, CodeInvalidModerationStatus, "comment.invalid_moderation_status")
+
+// validModerationStatus 版主可以设置的审核状态
+func validModerationStatus(status string) bool {
+	switch status {
+	case CommentStatusApproved, CommentStatusRejected, CommentStatusHidden:
+		return true
+	}
+	return false
+}
+
+// initialCommentStatus 根据文章设置决定新评论是否需要审核
+func initialCommentStatus(post *Post) string {
+	if post.CommentsNeedApproval {
+		return CommentStatusPending
+	}
+	return CommentStatusApproved
+}
+
+// commentOwner 返回路由参数 :id 对应评论的作者
+func commentOwner(c *gin.Context) (uint, error) {
+	commentID, ok := parseID(c.Param("id"))
+	if !ok {
+		return 0, errInvalidID
+	}
+	var comment Comment
+	if err := DB.Select("id", "user_id").First(&comment, commentID).Error; err != nil {
+		return 0, err
+	}
+	return comment.UserID, nil
+}
+
+// findComment 按路由参数 :id 查找评论，找不到或 ID 无效时直接返回错误响应
+func findComment(c *gin.Context, query *gorm.DB, comment *Comment) bool {
+	commentID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidID)
+		return false
+	}
+	if err := query.First(comment, commentID).Error; err != nil {
+		respondCommentLookupError(c, err)
+		return false
+	}
+	return true
+}
+
+// commentDescendants 返回评论下面所有回复（包括回复的回复）的 ID，逐层查询
+func commentDescendants(tx *gorm.DB, commentID uint) ([]uint, error) {
+	var all []uint
+	for level := []uint{commentID}; len(level) > 0; {
+		var next []uint
+		if err := tx.Model(&Comment{}).Where("parent_id IN ?", level).Pluck("id", &next).Error; err != nil {
+			return nil, err
+		}
+		all = append(all, next...)
+		level = next
+	}
+	return all, nil
+}
+
+// respondCommentLookupError 统一处理查找评论时的错误
+func respondCommentLookupError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		abortWithError(c, errCommentNotFound)
+	} else {
+		abortWithError(c, internalError("comment.get_failed", err))
+	}
+}
+
+// UpdateCommentHandler 编辑评论（仅作者本人）
+// 如果文章要求审核评论，编辑后的评论需要重新审核
+func UpdateCommentHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("编辑评论请求: 评论ID=%s, 用户ID=%v", c.Param("id"), userID)
+
+	var req struct {
+		Content string `json:"content" binding:"required,maxcontent=comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	var comment Comment
+	if !findComment(c, DB.Preload("Post"), &comment) {
+		return
+	}
+	if comment.Status == CommentStatusRejected || comment.Status == CommentStatusHidden {
+		abortWithError(c, newAPIError(http.StatusForbidden, CodeCommentLocked, "comment.locked"))
+		return
+	}
+
+	comment.Content = req.Content
+	if comment.Post.CommentsNeedApproval {
+		comment.Status = CommentStatusPending
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&comment).Select("content", "status").Updates(Comment{
+			Content: comment.Content,
+			Status:  comment.Status,
+		}).Error; err != nil {
+			return err
+		}
+		return touchPost(tx, comment.PostID)
+	})
+	if err != nil {
+		abortWithError(c, internalError("comment.update_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": T(c, "comment.updated"),
+		"comment": gin.H{
+			"id":         comment.ID,
+			"content":    comment.Content,
+			"status":     comment.Status,
+			"updated_at": comment.UpdatedAt,
+		},
+	})
+}
+
+// DeleteCommentHandler 删除评论（作者本人或管理员）
+// 评论下面还有审核通过的回复时，评论树中保留一个不带内容的占位节点，回复仍然可以访问
+func DeleteCommentHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("删除评论请求: 评论ID=%s, 用户ID=%v", c.Param("id"), userID)
+
+	var comment Comment
+	if !findComment(c, DB, &comment) {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		return touchPost(tx, comment.PostID)
+	})
+	if err != nil {
+		abortWithError(c, internalError("comment.delete_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "comment.deleted")})
+}
+
+// ModerateCommentHandler 设置评论的审核状态（版主或管理员）
+// 隐藏或拒绝一条评论时，默认只处理这一条，它下面审核通过的回复保留在占位节点之下；
+// cascade 为 true 时同时隐藏或拒绝下面所有审核通过的回复
+func ModerateCommentHandler(c *gin.Context) {
+	userIDVal, _ := c.Get("userID")
+	log.Printf("审核评论请求: 评论ID=%s, 用户ID=%v", c.Param("id"), userIDVal)
+
+	var req struct {
+		Status  string `json:"status" binding:"required"`
+		Cascade bool   `json:"cascade"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	if !validModerationStatus(req.Status) {
+		abortWithError(c, errInvalidModerationStatus)
+		return
+	}
+
+	var comment Comment
+	if !findComment(c, DB, &comment) {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"status":       req.Status,
+		"moderated_by": userIDVal.(uint),
+		"moderated_at": time.Now(),
+	}
+	affected := int64(1)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&comment).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := touchPost(tx, comment.PostID); err != nil {
+			return err
+		}
+		if !req.Cascade || req.Status == CommentStatusApproved {
+			return nil
+		}
+		ids, err := commentDescendants(tx, comment.ID)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		// 已经被单独隐藏或拒绝的回复保持原来的状态和审核记录
+		result := tx.Model(&Comment{}).Where("id IN ? AND status = ?", ids, CommentStatusApproved).Updates(updates)
+		affected += result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		abortWithError(c, internalError("comment.moderate_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    T(c, "comment.moderated"),
+		"comment_id": comment.ID,
+		"status":     req.Status,
+		"affected":   affected,
+	})
+}
+
+// ModerationQueueItem 审核队列中的一条评论
+type ModerationQueueItem struct {
+	ID        uint      `json:"id"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	PostID    uint      `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetModerationQueueHandler 获取审核队列（版主或管理员），默认列出待审核的评论
+// 用法：GET /moderation/comments?status=pending&limit=20&cursor=...
+func GetModerationQueueHandler(c *gin.Context) {
+	status := c.DefaultQuery("status", CommentStatusPending)
+	log.Printf("获取审核队列: status=%s", status)
+	if status != CommentStatusPending && !validModerationStatus(status) {
+		abortWithError(c, errInvalidModerationStatus)
+		return
+	}
+
+	pageReq, err := parsePageRequest(c, 20)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	query := DB.Model(&Comment{}).
+		Select("comments.id, comments.content, comments.status, comments.user_id, users.username, comments.post_id, posts.title AS post_title, comments.created_at").
+		Joins("LEFT JOIN users ON users.id = comments.user_id").
+		Joins("LEFT JOIN posts ON posts.id = comments.post_id").
+		Where("comments.status = ?", status)
+	items, pageInfo, err := paginate(query, pageReq, "comments", false, func(item ModerationQueueItem) Cursor {
+		return Cursor{CreatedAt: item.CreatedAt, ID: item.ID}
+	})
+	if err != nil {
+		abortWithError(c, internalError("comment.queue_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comments":   items,
+		"pagination": pageInfo,
+	})
+}
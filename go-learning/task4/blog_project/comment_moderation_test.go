@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCommentPermissions(t *testing.T) {
+	r := setupTestApp(t)
+	owner := createTestUser(t, "owner", RoleUser)
+	tokens := map[string]string{
+		"owner":     testToken(t, owner),
+		"other":     testToken(t, createTestUser(t, "other", RoleUser)),
+		"moderator": testToken(t, createTestUser(t, "mod", RoleModerator)),
+		"admin":     testToken(t, createTestUser(t, "admin", RoleAdmin)),
+	}
+	post := createTestPost(t, owner, "title", "content")
+
+	tests := []struct {
+		name   string
+		actor  string
+		method string
+		suffix string
+		body   map[string]interface{}
+		want   int
+	}{
+		{"他人不能编辑", "other", http.MethodPut, "", map[string]interface{}{"content": "x"}, http.StatusForbidden},
+		{"版主不能编辑他人评论", "moderator", http.MethodPut, "", map[string]interface{}{"content": "x"}, http.StatusForbidden},
+		{"作者可以编辑", "owner", http.MethodPut, "", map[string]interface{}{"content": "edited"}, http.StatusOK},
+		{"普通用户不能审核", "owner", http.MethodPut, "/moderation", map[string]interface{}{"status": CommentStatusHidden}, http.StatusForbidden},
+		{"版主可以审核", "moderator", http.MethodPut, "/moderation", map[string]interface{}{"status": CommentStatusHidden}, http.StatusOK},
+		{"他人不能删除", "other", http.MethodDelete, "", nil, http.StatusForbidden},
+		{"版主不能删除", "moderator", http.MethodDelete, "", nil, http.StatusForbidden},
+		{"管理员可以删除任意评论", "admin", http.MethodDelete, "", nil, http.StatusOK},
+		{"作者可以删除", "owner", http.MethodDelete, "", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := postComment(t, r, tokens["owner"], post.ID, 0, "comment")
+			w := doJSON(r, tt.method, fmt.Sprintf("/api/v1/comments/%d%s", id, tt.suffix), tokens[tt.actor], tt.body)
+			if w.Code != tt.want {
+				t.Errorf("期望 %d，得到 %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// 非数字 ID 在查询数据库之前被拒绝
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPut, "/api/v1/comments/1%20OR%201=1"},
+		{http.MethodDelete, "/api/v1/comments/1%20OR%201=1"},
+		{http.MethodPut, "/api/v1/comments/abc/moderation"},
+	} {
+		w := doJSON(r, req.method, req.path, tokens["admin"], map[string]interface{}{"content": "x", "status": CommentStatusHidden})
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidRequest {
+			t.Errorf("%s %s 应返回 400: %d %s", req.method, req.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCommentModerationTransitions(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+	modToken := testToken(t, createTestUser(t, "mod", RoleModerator))
+	post := createTestPost(t, author, "title", "content")
+	DB.Model(&post).Update("comments_need_approval", true)
+
+	id := postComment(t, r, token, post.ID, 0, "needs review")
+	status := func() string {
+		t.Helper()
+		var cmt Comment
+		DB.First(&cmt, id)
+		return cmt.Status
+	}
+	moderate := func(s string) int {
+		t.Helper()
+		w := doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/comments/%d/moderation", id), modToken, map[string]interface{}{"status": s})
+		return w.Code
+	}
+	edit := func(content string) int {
+		t.Helper()
+		return doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/comments/%d", id), token, map[string]interface{}{"content": content}).Code
+	}
+
+	if got := status(); got != CommentStatusPending {
+		t.Fatalf("需要审核的文章下新评论应为 pending，得到 %s", got)
+	}
+	w := doJSON(r, http.MethodGet, "/api/v1/moderation/comments", modToken, nil)
+	if queue := decodeJSON(t, w)["comments"].([]interface{}); len(queue) != 1 {
+		t.Fatalf("审核队列应有 1 条评论: %s", w.Body.String())
+	}
+	if code := moderate(CommentStatusPending); code != http.StatusBadRequest {
+		t.Errorf("不能把评论设回 pending，得到 %d", code)
+	}
+	if code := moderate(CommentStatusApproved); code != http.StatusOK || status() != CommentStatusApproved {
+		t.Fatalf("审核通过失败: %d %s", code, status())
+	}
+	// 编辑后重新进入审核队列
+	if code := edit("edited"); code != http.StatusOK || status() != CommentStatusPending {
+		t.Errorf("编辑后应重新待审核: %d %s", code, status())
+	}
+
+	for _, locked := range []string{CommentStatusHidden, CommentStatusRejected} {
+		if code := moderate(locked); code != http.StatusOK {
+			t.Fatalf("设置为 %s 失败: %d", locked, code)
+		}
+		w := doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/comments/%d", id), token, map[string]interface{}{"content": "sneaky"})
+		if w.Code != http.StatusForbidden || errorCode(t, w) != CodeCommentLocked {
+			t.Errorf("%s 的评论不能编辑: %d %s", locked, w.Code, w.Body.String())
+		}
+	}
+	var cmt Comment
+	DB.First(&cmt, id)
+	if cmt.ModeratedBy == nil || cmt.ModeratedAt == nil {
+		t.Errorf("审核后应记录审核人和时间: %+v", cmt)
+	}
+}
+
+func TestModerateCommentSubtree(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+	modToken := testToken(t, createTestUser(t, "mod", RoleModerator))
+	post := createTestPost(t, author, "title", "content")
+
+	root := postComment(t, r, token, post.ID, 0, "root")
+	reply := postComment(t, r, token, post.ID, root, "reply")
+	nested := postComment(t, r, token, post.ID, reply, "nested")
+	rejected := postComment(t, r, token, post.ID, root, "rejected")
+	doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/comments/%d/moderation", rejected), modToken,
+		map[string]interface{}{"status": CommentStatusRejected})
+
+	moderate := func(body map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		w := doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/comments/%d/moderation", root), modToken, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("审核失败: %d %s", w.Code, w.Body.String())
+		}
+		return decodeJSON(t, w)
+	}
+	tree := func() []interface{} {
+		t.Helper()
+		w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/posts/%d/comments", post.ID), "", nil)
+		return decodeJSON(t, w)["comments"].([]interface{})
+	}
+
+	// 默认只隐藏父评论，回复保留在占位节点下
+	if resp := moderate(map[string]interface{}{"status": CommentStatusHidden}); resp["affected"] != float64(1) {
+		t.Errorf("不级联时只应影响 1 条评论: %v", resp)
+	}
+	comments := tree()
+	if len(comments) != 1 {
+		t.Fatalf("隐藏的父评论应作为占位节点返回: %v", comments)
+	}
+	node := comments[0].(map[string]interface{})
+	if node["removed"] != true || len(node["replies"].([]interface{})) != 1 {
+		t.Errorf("占位节点下应保留审核通过的回复: %v", node)
+	}
+
+	// 级联时隐藏整棵子树，已被拒绝的回复保持原状态
+	if resp := moderate(map[string]interface{}{"status": CommentStatusHidden, "cascade": true}); resp["affected"] != float64(3) {
+		t.Errorf("级联应影响父评论和 2 条审核通过的回复: %v", resp)
+	}
+	if comments := tree(); len(comments) != 0 {
+		t.Errorf("整棵子树隐藏后不应返回任何评论: %v", comments)
+	}
+	want := map[uint]string{reply: CommentStatusHidden, nested: CommentStatusHidden, rejected: CommentStatusRejected}
+	for id, status := range want {
+		var cmt Comment
+		DB.First(&cmt, id)
+		if cmt.Status != status {
+			t.Errorf("评论 %d 状态应为 %s，得到 %s", id, status, cmt.Status)
+		}
+	}
+
+	// 重新通过只恢复父评论本身，不会连带恢复回复
+	if resp := moderate(map[string]interface{}{"status": CommentStatusApproved, "cascade": true}); resp["affected"] != float64(1) {
+		t.Errorf("审核通过不级联: %v", resp)
+	}
+}
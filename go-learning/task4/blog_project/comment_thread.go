@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CommentNode 评论树中的一个节点
+type CommentNode struct {
+	CommentResponse
+	ParentID       *uint          `json:"parent_id"`
+	Depth          int            `json:"depth"`
+	Removed        bool           `json:"removed,omitempty"` // 已被隐藏、拒绝或删除，只作为回复的占位节点，不返回内容和作者
+	ReplyCount     int64          `json:"reply_count"`
+	HasMoreReplies bool           `json:"has_more_replies"`
+	Replies        []*CommentNode `json:"replies"`
+}
+
+// visibleComments 只返回审核通过的评论
+func visibleComments(db *gorm.DB) *gorm.DB {
+	return db.Where("comments.status = ?", CommentStatusApproved)
+}
+
+// threadComments 返回一个查询作用域：文章评论树中要展示的评论
+// 除了审核通过的评论，被隐藏、拒绝或删除但下面还有审核通过的回复的评论也要展示（作为占位节点），
+// 否则删除或隐藏一条评论会让它下面的整棵回复树都无法访问
+// 已删除的评论也可能作为占位节点，查询需要配合 Unscoped 使用
+func threadComments(postID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// 从审核通过的评论出发，沿 parent_id 向上找到所有祖先
+		return db.Where(`comments.id IN (
+			WITH RECURSIVE thread (id, parent_id) AS (
+				SELECT id, parent_id FROM comments WHERE post_id = ? AND status = ? AND deleted_at IS NULL
+				UNION
+				SELECT p.id, p.parent_id FROM comments p JOIN thread ON p.id = thread.parent_id
+			)
+			SELECT id FROM thread)`, postID, CommentStatusApproved)
+	}
+}
+
+// parseTreeDepth 解析 ?depth= 参数，表示在当前层级之下还要展开多少层回复，最多为 comments.max_depth
+func parseTreeDepth(c *gin.Context) int {
+	maxDepth := currentConfig().Comments.MaxDepth
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", strconv.Itoa(maxDepth)))
+	if err != nil || depth < 0 || depth > maxDepth {
+		depth = maxDepth
+	}
+	return depth
+}
+
+// loadUsernames 批量查询用户名
+func loadUsernames(userIDs []uint) map[uint]string {
+	userMap := make(map[uint]string)
+	if len(userIDs) == 0 {
+		return userMap
+	}
+	var users []User
+	DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users)
+	for _, u := range users {
+		userMap[u.ID] = u.Username
+	}
+	return userMap
+}
+
+// loadReplies 为文章 postID 的评论 nodes 逐层加载回复，每条评论最多加载 perParent 条回复，共展开 levels 层
+// 最后一层不再展开，但仍然统计回复数量，方便客户端继续加载
+func loadReplies(nodes []*CommentNode, postID uint, levels, perParent int) error {
+	for level := 0; len(nodes) > 0; level++ {
+		parentIDs := make([]uint, 0, len(nodes))
+		byID := make(map[uint]*CommentNode, len(nodes))
+		for _, n := range nodes {
+			parentIDs = append(parentIDs, n.ID)
+			byID[n.ID] = n
+		}
+
+		// 统计每条评论的回复总数
+		var counts []struct {
+			ParentID uint
+			Total    int64
+		}
+		if err := DB.Unscoped().Model(&Comment{}).Scopes(threadComments(postID)).
+			Select("parent_id, COUNT(*) AS total").
+			Where("parent_id IN ?", parentIDs).
+			Group("parent_id").Scan(&counts).Error; err != nil {
+			return err
+		}
+		for _, cnt := range counts {
+			byID[cnt.ParentID].ReplyCount = cnt.Total
+		}
+		if level == levels {
+			for _, n := range nodes {
+				n.HasMoreReplies = n.ReplyCount > 0
+			}
+			break
+		}
+
+		// 每条评论只取最早的 perParent 条回复
+		var replies []Comment
+		ranked := DB.Unscoped().Model(&Comment{}).Scopes(threadComments(postID)).
+			Select("comments.*, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at, id) AS rn").
+			Where("parent_id IN ?", parentIDs)
+		if err := DB.Unscoped().Table("(?) AS ranked", ranked).
+			Where("rn <= ?", perParent).
+			Order("created_at, id").
+			Find(&replies).Error; err != nil {
+			return err
+		}
+
+		next := make([]*CommentNode, 0, len(replies))
+		for _, r := range replies {
+			node := newCommentNode(r)
+			parent := byID[*r.ParentID]
+			parent.Replies = append(parent.Replies, node)
+			next = append(next, node)
+		}
+		for _, n := range nodes {
+			n.HasMoreReplies = int64(len(n.Replies)) < n.ReplyCount
+		}
+		nodes = next
+	}
+	return nil
+}
+
+// newCommentNode 把评论模型转换为树节点，用户名稍后统一填充
+// 隐藏、拒绝或删除的评论只保留位置，不返回内容和作者
+func newCommentNode(cmt Comment) *CommentNode {
+	node := &CommentNode{
+		CommentResponse: CommentResponse{
+			ID:        cmt.ID,
+			Content:   cmt.Content,
+			UserID:    cmt.UserID,
+			CreatedAt: cmt.CreatedAt,
+		},
+		ParentID: cmt.ParentID,
+		Depth:    cmt.Depth,
+		Replies:  []*CommentNode{},
+	}
+	if cmt.Status != CommentStatusApproved || cmt.DeletedAt.Valid {
+		node.Removed = true
+		node.Content, node.UserID = "", 0
+	}
+	return node
+}
+
+// fillUsernames 为整棵评论树填充用户名
+func fillUsernames(nodes []*CommentNode) {
+	var userIDs []uint
+	var walk func([]*CommentNode)
+	walk = func(ns []*CommentNode) {
+		for _, n := range ns {
+			userIDs = append(userIDs, n.UserID)
+			walk(n.Replies)
+		}
+	}
+	walk(nodes)
+
+	userMap := loadUsernames(userIDs)
+	var fill func([]*CommentNode)
+	fill = func(ns []*CommentNode) {
+		for _, n := range ns {
+			n.Username = userMap[n.UserID]
+			fill(n.Replies)
+		}
+	}
+	fill(nodes)
+}
+
+// buildCommentTree 分页查询 query 对应的文章 postID 的评论，并展开 levels 层回复
+func buildCommentTree(query *gorm.DB, postID uint, pageReq PageRequest, levels int) ([]*CommentNode, PageInfo, error) {
+	comments, pageInfo, err := paginate(query, pageReq, "comments", false, commentCursor)
+	if err != nil {
+		return nil, pageInfo, err
+	}
+
+	nodes := make([]*CommentNode, 0, len(comments))
+	for _, cmt := range comments {
+		nodes = append(nodes, newCommentNode(cmt))
+	}
+	// 每条评论默认展示 comments.replies_per_level 条回复，更多回复通过 /comments/:id/replies 分页获取
+	if err := loadReplies(nodes, postID, levels, currentConfig().Comments.RepliesPerLevel); err != nil {
+		return nil, pageInfo, err
+	}
+	fillUsernames(nodes)
+	return nodes, pageInfo, nil
+}
+
+// GetCommentRepliesHandler 分页获取某条评论的回复（公开接口）
+func GetCommentRepliesHandler(c *gin.Context) {
+	log.Printf("获取评论回复: 评论ID=%s", c.Param("id"))
+	parentID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	// 父评论被隐藏或删除后，只要下面还有可见的回复就仍然可以获取
+	var parent Comment
+	err := DB.Unscoped().Select("id", "post_id").First(&parent, parentID).Error
+	if err == nil {
+		err = DB.Unscoped().Scopes(threadComments(parent.PostID)).First(&parent, parentID).Error
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errCommentNotFound)
+		} else {
+			abortWithError(c, internalError("comment.get_failed", err))
+		}
+		return
+	}
+
+	// 评论所属的文章对当前用户不可见时，回复同样不可见
+	var post Post
+	if err := DB.Scopes(visiblePosts(c)).Select("id").First(&post, parent.PostID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errCommentNotFound)
+		} else {
+			abortWithError(c, internalError("post.get_failed", err))
+		}
+		return
+	}
+
+	pageReq, err := parsePageRequest(c, currentConfig().Comments.RepliesPerLevel)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	query := DB.Unscoped().Model(&Comment{}).Scopes(threadComments(parent.PostID)).Where("parent_id = ?", parent.ID)
+	replies, pageInfo, err := buildCommentTree(query, parent.PostID, pageReq, parseTreeDepth(c))
+	if err != nil {
+		abortWithError(c, internalError("comment.replies_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"parent_id":  parent.ID,
+		"replies":    replies,
+		"pagination": pageInfo,
+	})
+}
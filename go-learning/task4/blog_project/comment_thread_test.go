@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// postComment 通过接口发表评论，parentID 为 0 表示顶层评论
+func postComment(t *testing.T, r http.Handler, token string, postID, parentID uint, content string) uint {
+	t.Helper()
+	body := map[string]interface{}{"content": content}
+	if parentID != 0 {
+		body["parent_id"] = parentID
+	}
+	w := doJSON(r, http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/comments", postID), token, body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("发表评论返回 %d: %s", w.Code, w.Body.String())
+	}
+	return uint(decodeJSON(t, w)["comment"].(map[string]interface{})["id"].(float64))
+}
+
+func TestCommentReplyDepthLimit(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) { cfg.Comments.MaxDepth = 2 })
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	post := createTestPost(t, user, "title", "content")
+
+	parent := postComment(t, r, token, post.ID, 0, "depth 0")
+	for depth := 1; depth <= 2; depth++ {
+		parent = postComment(t, r, token, post.ID, parent, fmt.Sprintf("depth %d", depth))
+	}
+	w := doJSON(r, http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/comments", post.ID), token,
+		map[string]interface{}{"content": "depth 3", "parent_id": parent})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeCommentDepthExceeded {
+		t.Fatalf("超过最大深度应返回 400 %s: %d %s", CodeCommentDepthExceeded, w.Code, w.Body.String())
+	}
+
+	// 父评论必须属于同一篇文章
+	other := createTestPost(t, user, "other", "content")
+	w = doJSON(r, http.MethodPost, fmt.Sprintf("/api/v1/posts/%d/comments", other.ID), token,
+		map[string]interface{}{"content": "cross post", "parent_id": parent})
+	if w.Code != http.StatusNotFound || errorCode(t, w) != CodeCommentNotFound {
+		t.Errorf("回复其他文章的评论应返回 404: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCommentRepliesPagination(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	post := createTestPost(t, user, "title", "content")
+	root := postComment(t, r, token, post.ID, 0, "root")
+	for i := 1; i <= 5; i++ {
+		postComment(t, r, token, post.ID, root, fmt.Sprintf("reply %d", i))
+	}
+
+	var got []string
+	path := fmt.Sprintf("/api/v1/comments/%d/replies?limit=2", root)
+	for page := 0; page < 5; page++ {
+		w := doJSON(r, http.MethodGet, path, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("获取回复返回 %d: %s", w.Code, w.Body.String())
+		}
+		body := decodeJSON(t, w)
+		for _, item := range body["replies"].([]interface{}) {
+			got = append(got, item.(map[string]interface{})["content"].(string))
+		}
+		next, _ := body["pagination"].(map[string]interface{})["next_cursor"].(string)
+		if next == "" {
+			break
+		}
+		path = fmt.Sprintf("/api/v1/comments/%d/replies?limit=2&cursor=%s", root, url.QueryEscape(next))
+	}
+	if fmt.Sprint(got) != "[reply 1 reply 2 reply 3 reply 4 reply 5]" {
+		t.Errorf("分页获取的回复为 %v", got)
+	}
+}
+
+func TestCommentRepliesNotFound(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	post := createTestPost(t, user, "title", "content")
+	root := postComment(t, r, testToken(t, user), post.ID, 0, "root")
+
+	if w := doJSON(r, http.MethodGet, "/api/v1/comments/"+url.PathEscape("1) OR (1=1")+"/replies", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("非数字的评论 ID 应返回 400: %d", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, "/api/v1/comments/999999/replies", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的评论应返回 404: %d", w.Code)
+	}
+	// 文章转为草稿后，它的评论对其他人不可见
+	DB.Model(&post).Update("status", PostStatusDraft)
+	if w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/comments/%d/replies", root), "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("草稿的评论应返回 404: %d", w.Code)
+	}
+}
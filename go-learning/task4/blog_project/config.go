@@ -0,0 +1,505 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultJWTSecret 是示例密钥，生产环境下禁止使用
+const defaultJWTSecret = "your_secret_key"
+
+// Duration 支持在配置文件和环境变量中使用 "15m"、"168h" 这样的写法
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText 供 TOML 和环境变量使用
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// UnmarshalYAML 供 YAML 使用
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.UnmarshalText([]byte(value.Value))
+}
+
+// Config 应用配置
+// 标记为“可热加载”的配置项在收到 SIGHUP 时会重新读取，其余配置项修改后需要重启
+type Config struct {
+	Env string `yaml:"env" toml:"env"` // development / production
+
+	Server struct {
+		Addr           string   `yaml:"addr" toml:"addr"`
+		TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"` // 可信的反向代理，只有来自这些地址的 X-Forwarded-For 才会被采信
+	} `yaml:"server" toml:"server"`
+
+	Database struct {
+		Driver          string   `yaml:"driver" toml:"driver"` // sqlite / postgres / mysql
+		DSN             string   `yaml:"dsn" toml:"dsn"`
+		MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"` // 0 表示不限制
+		MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+		ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	} `yaml:"database" toml:"database"`
+
+	Auth struct {
+		JWTSecret       string   `yaml:"jwt_secret" toml:"jwt_secret"`
+		AccessTokenTTL  Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`   // 可热加载
+		RefreshTokenTTL Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"` // 可热加载
+		BcryptCost      int      `yaml:"bcrypt_cost" toml:"bcrypt_cost"`             // 可热加载
+		BootstrapAdmin  string   `yaml:"bootstrap_admin" toml:"bootstrap_admin"`
+
+		EmailVerificationTTL Duration `yaml:"email_verification_ttl" toml:"email_verification_ttl"` // 可热加载
+		PasswordResetTTL     Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"`         // 可热加载
+		MFAPendingTTL        Duration `yaml:"mfa_pending_ttl" toml:"mfa_pending_ttl"`               // 输入两步验证码的时限，可热加载
+		MFAIssuer            string   `yaml:"mfa_issuer" toml:"mfa_issuer"`                         // 身份验证器中显示的服务名
+
+		LockoutThreshold int      `yaml:"lockout_threshold" toml:"lockout_threshold"` // 连续输错密码多少次后锁定账号，0 表示不锁定，可热加载
+		LockoutWindow    Duration `yaml:"lockout_window" toml:"lockout_window"`       // 统计连续失败次数的时间窗口，可热加载
+		LockoutDuration  Duration `yaml:"lockout_duration" toml:"lockout_duration"`   // 锁定时长，可热加载
+	} `yaml:"auth" toml:"auth"`
+
+	Mail struct {
+		Driver  string `yaml:"driver" toml:"driver"`     // log / file / smtp
+		From    string `yaml:"from" toml:"from"`         // 发件人
+		Dir     string `yaml:"dir" toml:"dir"`           // file 驱动保存邮件的目录
+		BaseURL string `yaml:"base_url" toml:"base_url"` // 邮件中链接的前缀
+		SMTP    struct {
+			Host     string `yaml:"host" toml:"host"`
+			Port     int    `yaml:"port" toml:"port"`
+			Username string `yaml:"username" toml:"username"`
+			Password string `yaml:"password" toml:"password"`
+		} `yaml:"smtp" toml:"smtp"`
+	} `yaml:"mail" toml:"mail"`
+
+	Storage struct {
+		Driver string `yaml:"driver" toml:"driver"` // local / s3
+		Dir    string `yaml:"dir" toml:"dir"`       // local 驱动保存文件的目录
+		S3     struct {
+			Endpoint  string `yaml:"endpoint" toml:"endpoint"` // 例如 https://s3.us-east-1.amazonaws.com，本地 MinIO 为 http://localhost:9000
+			Region    string `yaml:"region" toml:"region"`
+			Bucket    string `yaml:"bucket" toml:"bucket"`
+			AccessKey string `yaml:"access_key" toml:"access_key"`
+			SecretKey string `yaml:"secret_key" toml:"secret_key"`
+			PathStyle bool   `yaml:"path_style" toml:"path_style"` // 使用 endpoint/bucket/key 形式的地址，MinIO 等兼容服务一般需要开启
+		} `yaml:"s3" toml:"s3"`
+	} `yaml:"storage" toml:"storage"`
+
+	Uploads struct {
+		MaxSize       int      `yaml:"max_size" toml:"max_size"`             // 单个文件的最大字节数，可热加载
+		AllowedTypes  []string `yaml:"allowed_types" toml:"allowed_types"`   // 允许上传的 MIME 类型，按文件内容识别，可热加载
+		ThumbnailSize int      `yaml:"thumbnail_size" toml:"thumbnail_size"` // 缩略图最长边的像素数，可热加载
+	} `yaml:"uploads" toml:"uploads"`
+
+	RateLimit struct {
+		Enabled  bool          `yaml:"enabled" toml:"enabled"`
+		Login    RateLimitRule `yaml:"login" toml:"login"`       // 登录，按 IP
+		Register RateLimitRule `yaml:"register" toml:"register"` // 注册，按 IP
+		Auth     RateLimitRule `yaml:"auth" toml:"auth"`         // 两步验证、找回密码、钱包登录等其他认证接口，按 IP
+		Public   RateLimitRule `yaml:"public" toml:"public"`     // 其余公开接口，按 IP
+		User     RateLimitRule `yaml:"user" toml:"user"`         // 需要登录的接口，按用户
+	} `yaml:"rate_limit" toml:"rate_limit"` // 可热加载
+
+	SIWE struct {
+		Domain   string   `yaml:"domain" toml:"domain"`       // 签名消息中的 domain 必须与之一致，一般为前端的 host[:port]，可热加载
+		URI      string   `yaml:"uri" toml:"uri"`             // 不为空时签名消息中的 URI 必须以它开头，可热加载
+		ChainID  int      `yaml:"chain_id" toml:"chain_id"`   // 允许的链 ID，0 表示不限制，可热加载
+		NonceTTL Duration `yaml:"nonce_ttl" toml:"nonce_ttl"` // nonce 的有效期，可热加载
+	} `yaml:"siwe" toml:"siwe"`
+
+	Posts struct {
+		PublishInterval  Duration `yaml:"publish_interval" toml:"publish_interval"`     // 定时发布的检查间隔
+		MaxContentLength int      `yaml:"max_content_length" toml:"max_content_length"` // 正文最大字符数，可热加载
+		RenderCacheSize  int      `yaml:"render_cache_size" toml:"render_cache_size"`   // 渲染结果缓存的文章数，0 表示不缓存
+	} `yaml:"posts" toml:"posts"`
+
+	Feeds struct {
+		Title       string `yaml:"title" toml:"title"`             // 订阅源标题，可热加载
+		Description string `yaml:"description" toml:"description"` // 订阅源描述，可热加载
+		SiteURL     string `yaml:"site_url" toml:"site_url"`       // 文章链接的前缀，为空时使用 mail.base_url，可热加载
+		Items       int    `yaml:"items" toml:"items"`             // 订阅源中的文章数，可热加载
+	} `yaml:"feeds" toml:"feeds"`
+
+	Pagination struct {
+		DefaultPageSize int `yaml:"default_page_size" toml:"default_page_size"` // 可热加载
+		MaxPageSize     int `yaml:"max_page_size" toml:"max_page_size"`         // 可热加载
+	} `yaml:"pagination" toml:"pagination"`
+
+	Comments struct {
+		MaxDepth         int `yaml:"max_depth" toml:"max_depth"`                   // 可热加载
+		RepliesPerLevel  int `yaml:"replies_per_level" toml:"replies_per_level"`   // 可热加载
+		MaxContentLength int `yaml:"max_content_length" toml:"max_content_length"` // 评论最大字符数，可热加载
+	} `yaml:"comments" toml:"comments"`
+}
+
+// appConfig 当前生效的配置，热加载时整体替换
+var appConfig atomic.Pointer[Config]
+
+// currentConfig 返回当前生效的配置，调用方不应修改返回值
+func currentConfig() *Config {
+	return appConfig.Load()
+}
+
+// defaultConfig 返回默认配置，与之前写死在代码中的值保持一致
+func defaultConfig() *Config {
+	cfg := &Config{Env: "development"}
+	cfg.Server.Addr = ":8080"
+	cfg.Database.Driver = DriverSQLite
+	cfg.Database.DSN = "blog.db"
+	cfg.Auth.JWTSecret = defaultJWTSecret
+	cfg.Auth.AccessTokenTTL = Duration{15 * time.Minute}
+	cfg.Auth.RefreshTokenTTL = Duration{7 * 24 * time.Hour}
+	cfg.Auth.BcryptCost = 14
+	cfg.Auth.EmailVerificationTTL = Duration{48 * time.Hour}
+	cfg.Auth.PasswordResetTTL = Duration{time.Hour}
+	cfg.Auth.MFAPendingTTL = Duration{5 * time.Minute}
+	cfg.Auth.MFAIssuer = "Blog"
+	cfg.Mail.Driver = MailDriverLog
+	cfg.Mail.From = "Blog <no-reply@localhost>"
+	cfg.Mail.Dir = "mail"
+	cfg.Mail.BaseURL = "http://localhost:8080"
+	cfg.Mail.SMTP.Port = 587
+	cfg.Storage.Driver = StorageDriverLocal
+	cfg.Storage.Dir = "uploads"
+	cfg.Storage.S3.Region = "us-east-1"
+	cfg.Uploads.MaxSize = 10 << 20
+	cfg.Uploads.AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf", "text/plain"}
+	cfg.Uploads.ThumbnailSize = 320
+	cfg.Auth.LockoutThreshold = 5
+	cfg.Auth.LockoutWindow = Duration{15 * time.Minute}
+	cfg.Auth.LockoutDuration = Duration{15 * time.Minute}
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Login = RateLimitRule{Requests: 5, Per: Duration{time.Minute}, Burst: 5}
+	cfg.RateLimit.Register = RateLimitRule{Requests: 5, Per: Duration{time.Hour}, Burst: 3}
+	cfg.RateLimit.Auth = RateLimitRule{Requests: 10, Per: Duration{time.Minute}, Burst: 10}
+	cfg.RateLimit.Public = RateLimitRule{Requests: 120, Per: Duration{time.Minute}, Burst: 60}
+	cfg.RateLimit.User = RateLimitRule{Requests: 300, Per: Duration{time.Minute}, Burst: 100}
+	cfg.SIWE.Domain = "localhost:8080"
+	cfg.SIWE.ChainID = 1
+	cfg.SIWE.NonceTTL = Duration{10 * time.Minute}
+	cfg.Posts.PublishInterval = Duration{30 * time.Second}
+	cfg.Posts.MaxContentLength = 100000
+	cfg.Posts.RenderCacheSize = 500
+	cfg.Feeds.Title = "博客"
+	cfg.Feeds.Description = "最新文章"
+	cfg.Feeds.Items = 20
+	cfg.Pagination.DefaultPageSize = 10
+	cfg.Pagination.MaxPageSize = 100
+	cfg.Comments.MaxDepth = 5
+	cfg.Comments.RepliesPerLevel = 5
+	cfg.Comments.MaxContentLength = 5000
+	return cfg
+}
+
+// configPath 返回配置文件路径：优先使用 BLOG_CONFIG，否则尝试当前目录下的 config.yaml
+func configPath() string {
+	if p := os.Getenv("BLOG_CONFIG"); p != "" {
+		return p
+	}
+	if _, err := os.Stat("config.yaml"); err == nil {
+		return "config.yaml"
+	}
+	return ""
+}
+
+// loadConfig 按 默认值 -> 配置文件 -> 环境变量 的顺序加载配置并校验
+func loadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, cfg)
+		case ".toml":
+			err = toml.Unmarshal(data, cfg)
+		default:
+			err = fmt.Errorf("不支持的配置文件格式: %s", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	}
+
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnvOverrides 使用 BLOG_ 开头的环境变量覆盖配置
+func applyEnvOverrides(cfg *Config) error {
+	strs := map[string]*string{
+		"BLOG_ENV":             &cfg.Env,
+		"BLOG_SERVER_ADDR":     &cfg.Server.Addr,
+		"BLOG_DATABASE_DRIVER": &cfg.Database.Driver,
+		"BLOG_DATABASE_DSN":    &cfg.Database.DSN,
+		"BLOG_JWT_SECRET":      &cfg.Auth.JWTSecret,
+		"BLOG_BOOTSTRAP_ADMIN": &cfg.Auth.BootstrapAdmin,
+		"BLOG_MFA_ISSUER":      &cfg.Auth.MFAIssuer,
+		"BLOG_MAIL_DRIVER":     &cfg.Mail.Driver,
+		"BLOG_MAIL_FROM":       &cfg.Mail.From,
+		"BLOG_MAIL_DIR":        &cfg.Mail.Dir,
+		"BLOG_MAIL_BASE_URL":   &cfg.Mail.BaseURL,
+		"BLOG_FEEDS_TITLE":     &cfg.Feeds.Title,
+		"BLOG_FEEDS_SITE_URL":  &cfg.Feeds.SiteURL,
+		"BLOG_SMTP_HOST":       &cfg.Mail.SMTP.Host,
+		"BLOG_SMTP_USERNAME":   &cfg.Mail.SMTP.Username,
+		"BLOG_SMTP_PASSWORD":   &cfg.Mail.SMTP.Password,
+		"BLOG_SIWE_DOMAIN":     &cfg.SIWE.Domain,
+		"BLOG_SIWE_URI":        &cfg.SIWE.URI,
+		"BLOG_STORAGE_DRIVER":  &cfg.Storage.Driver,
+		"BLOG_STORAGE_DIR":     &cfg.Storage.Dir,
+		"BLOG_S3_ENDPOINT":     &cfg.Storage.S3.Endpoint,
+		"BLOG_S3_REGION":       &cfg.Storage.S3.Region,
+		"BLOG_S3_BUCKET":       &cfg.Storage.S3.Bucket,
+		"BLOG_S3_ACCESS_KEY":   &cfg.Storage.S3.AccessKey,
+		"BLOG_S3_SECRET_KEY":   &cfg.Storage.S3.SecretKey,
+	}
+	for name, ptr := range strs {
+		if v, ok := os.LookupEnv(name); ok {
+			*ptr = v
+		}
+	}
+
+	ints := map[string]*int{
+		"BLOG_BCRYPT_COST":                  &cfg.Auth.BcryptCost,
+		"BLOG_PAGINATION_DEFAULT_PAGE_SIZE": &cfg.Pagination.DefaultPageSize,
+		"BLOG_PAGINATION_MAX_PAGE_SIZE":     &cfg.Pagination.MaxPageSize,
+		"BLOG_COMMENTS_MAX_DEPTH":           &cfg.Comments.MaxDepth,
+		"BLOG_COMMENTS_REPLIES_PER_LEVEL":   &cfg.Comments.RepliesPerLevel,
+		"BLOG_POSTS_MAX_CONTENT_LENGTH":     &cfg.Posts.MaxContentLength,
+		"BLOG_POSTS_RENDER_CACHE_SIZE":      &cfg.Posts.RenderCacheSize,
+		"BLOG_FEEDS_ITEMS":                  &cfg.Feeds.Items,
+		"BLOG_COMMENTS_MAX_CONTENT_LENGTH":  &cfg.Comments.MaxContentLength,
+		"BLOG_DATABASE_MAX_OPEN_CONNS":      &cfg.Database.MaxOpenConns,
+		"BLOG_DATABASE_MAX_IDLE_CONNS":      &cfg.Database.MaxIdleConns,
+		"BLOG_SMTP_PORT":                    &cfg.Mail.SMTP.Port,
+		"BLOG_SIWE_CHAIN_ID":                &cfg.SIWE.ChainID,
+		"BLOG_LOCKOUT_THRESHOLD":            &cfg.Auth.LockoutThreshold,
+		"BLOG_UPLOADS_MAX_SIZE":             &cfg.Uploads.MaxSize,
+	}
+	for name, ptr := range ints {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是有效的整数: %q", name, v)
+			}
+			*ptr = n
+		}
+	}
+
+	durations := map[string]*Duration{
+		"BLOG_ACCESS_TOKEN_TTL":           &cfg.Auth.AccessTokenTTL,
+		"BLOG_REFRESH_TOKEN_TTL":          &cfg.Auth.RefreshTokenTTL,
+		"BLOG_POSTS_PUBLISH_INTERVAL":     &cfg.Posts.PublishInterval,
+		"BLOG_DATABASE_CONN_MAX_LIFETIME": &cfg.Database.ConnMaxLifetime,
+		"BLOG_EMAIL_VERIFICATION_TTL":     &cfg.Auth.EmailVerificationTTL,
+		"BLOG_PASSWORD_RESET_TTL":         &cfg.Auth.PasswordResetTTL,
+		"BLOG_MFA_PENDING_TTL":            &cfg.Auth.MFAPendingTTL,
+		"BLOG_SIWE_NONCE_TTL":             &cfg.SIWE.NonceTTL,
+		"BLOG_LOCKOUT_WINDOW":             &cfg.Auth.LockoutWindow,
+		"BLOG_LOCKOUT_DURATION":           &cfg.Auth.LockoutDuration,
+	}
+	for name, ptr := range durations {
+		if v, ok := os.LookupEnv(name); ok {
+			if err := ptr.UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("环境变量 %s 不是有效的时长: %q", name, v)
+			}
+		}
+	}
+
+	if v, ok := os.LookupEnv("BLOG_RATE_LIMIT_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 BLOG_RATE_LIMIT_ENABLED 不是有效的布尔值: %q", v)
+		}
+		cfg.RateLimit.Enabled = enabled
+	}
+	if v, ok := os.LookupEnv("BLOG_S3_PATH_STYLE"); ok {
+		pathStyle, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("环境变量 BLOG_S3_PATH_STYLE 不是有效的布尔值: %q", v)
+		}
+		cfg.Storage.S3.PathStyle = pathStyle
+	}
+	// 多个代理用逗号分隔
+	if v, ok := os.LookupEnv("BLOG_TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = nil
+		for _, proxy := range strings.Split(v, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, proxy)
+			}
+		}
+	}
+	return nil
+}
+
+// validate 校验配置，生产环境下拒绝使用默认密钥
+func (cfg *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Env == "development" || cfg.Env == "production", "env 只能是 development 或 production，当前为 %q", cfg.Env)
+	check(cfg.Server.Addr != "", "server.addr 不能为空")
+	check(cfg.Database.Driver == DriverSQLite || cfg.Database.Driver == DriverPostgres || cfg.Database.Driver == DriverMySQL,
+		"database.driver 只能是 sqlite、postgres 或 mysql，当前为 %q", cfg.Database.Driver)
+	check(cfg.Database.DSN != "", "database.dsn 不能为空")
+	// 不加 parseTime 时 go-sql-driver/mysql 无法把 DATETIME 扫描到 time.Time
+	check(cfg.Database.Driver != DriverMySQL || strings.Contains(strings.ToLower(cfg.Database.DSN), "parsetime=true"),
+		"使用 mysql 时 database.dsn 必须包含 parseTime=True")
+	check(cfg.Database.MaxOpenConns >= 0 && cfg.Database.MaxIdleConns >= 0, "database 连接池大小不能为负数")
+	check(cfg.Auth.JWTSecret != "", "auth.jwt_secret 不能为空")
+	if cfg.Env == "production" {
+		check(cfg.Auth.JWTSecret != defaultJWTSecret, "生产环境不能使用默认的 auth.jwt_secret")
+		check(len(cfg.Auth.JWTSecret) >= 32, "生产环境的 auth.jwt_secret 至少需要 32 个字符")
+	}
+	check(cfg.Auth.AccessTokenTTL.Duration > 0, "auth.access_token_ttl 必须大于 0")
+	check(cfg.Auth.RefreshTokenTTL.Duration > cfg.Auth.AccessTokenTTL.Duration, "auth.refresh_token_ttl 必须大于 access_token_ttl")
+	check(cfg.Auth.BcryptCost >= bcrypt.MinCost && cfg.Auth.BcryptCost <= bcrypt.MaxCost,
+		"auth.bcrypt_cost 必须在 %d 到 %d 之间", bcrypt.MinCost, bcrypt.MaxCost)
+	check(cfg.Auth.EmailVerificationTTL.Duration > 0, "auth.email_verification_ttl 必须大于 0")
+	check(cfg.Auth.PasswordResetTTL.Duration > 0, "auth.password_reset_ttl 必须大于 0")
+	check(cfg.Auth.MFAPendingTTL.Duration > 0, "auth.mfa_pending_ttl 必须大于 0")
+	check(cfg.Auth.MFAIssuer != "", "auth.mfa_issuer 不能为空")
+	check(cfg.Mail.Driver == MailDriverLog || cfg.Mail.Driver == MailDriverFile || cfg.Mail.Driver == MailDriverSMTP,
+		"mail.driver 只能是 log、file 或 smtp，当前为 %q", cfg.Mail.Driver)
+	check(cfg.Mail.From != "", "mail.from 不能为空")
+	check(cfg.Mail.Driver != MailDriverFile || cfg.Mail.Dir != "", "使用 file 驱动时 mail.dir 不能为空")
+	check(cfg.Mail.Driver != MailDriverSMTP || (cfg.Mail.SMTP.Host != "" && cfg.Mail.SMTP.Port > 0),
+		"使用 smtp 驱动时必须设置 mail.smtp.host 和 mail.smtp.port")
+	check(cfg.Auth.LockoutThreshold >= 0, "auth.lockout_threshold 不能为负数")
+	check(cfg.Auth.LockoutThreshold == 0 || (cfg.Auth.LockoutWindow.Duration > 0 && cfg.Auth.LockoutDuration.Duration > 0),
+		"开启账号锁定时 auth.lockout_window 和 auth.lockout_duration 必须大于 0")
+	for _, r := range []struct {
+		name string
+		rule RateLimitRule
+	}{
+		{"login", cfg.RateLimit.Login},
+		{"register", cfg.RateLimit.Register},
+		{"auth", cfg.RateLimit.Auth},
+		{"public", cfg.RateLimit.Public},
+		{"user", cfg.RateLimit.User},
+	} {
+		check(r.rule.Requests >= 0 && r.rule.Burst >= 0, "rate_limit.%s 的 requests 和 burst 不能为负数", r.name)
+		check(r.rule.Requests == 0 || r.rule.Per.Duration > 0, "rate_limit.%s.per 必须大于 0", r.name)
+	}
+	check(cfg.Storage.Driver == StorageDriverLocal || cfg.Storage.Driver == StorageDriverS3,
+		"storage.driver 只能是 local 或 s3，当前为 %q", cfg.Storage.Driver)
+	check(cfg.Storage.Driver != StorageDriverLocal || cfg.Storage.Dir != "", "使用 local 驱动时 storage.dir 不能为空")
+	check(cfg.Storage.Driver != StorageDriverS3 ||
+		(cfg.Storage.S3.Endpoint != "" && cfg.Storage.S3.Region != "" && cfg.Storage.S3.Bucket != "" &&
+			cfg.Storage.S3.AccessKey != "" && cfg.Storage.S3.SecretKey != ""),
+		"使用 s3 驱动时必须设置 storage.s3 的 endpoint、region、bucket、access_key 和 secret_key")
+	check(cfg.Uploads.MaxSize >= 1, "uploads.max_size 必须大于 0")
+	check(len(cfg.Uploads.AllowedTypes) > 0, "uploads.allowed_types 不能为空")
+	check(cfg.Uploads.ThumbnailSize >= 16, "uploads.thumbnail_size 不能小于 16")
+	check(cfg.SIWE.Domain != "", "siwe.domain 不能为空")
+	check(cfg.SIWE.ChainID >= 0, "siwe.chain_id 不能为负数")
+	check(cfg.SIWE.NonceTTL.Duration > 0, "siwe.nonce_ttl 必须大于 0")
+	check(cfg.Posts.PublishInterval.Duration >= time.Second, "posts.publish_interval 不能小于 1s")
+	check(cfg.Pagination.MaxPageSize >= 1, "pagination.max_page_size 必须大于 0")
+	check(cfg.Pagination.DefaultPageSize >= 1 && cfg.Pagination.DefaultPageSize <= cfg.Pagination.MaxPageSize,
+		"pagination.default_page_size 必须在 1 到 max_page_size 之间")
+	check(cfg.Comments.MaxDepth >= 0, "comments.max_depth 不能为负数")
+	check(cfg.Comments.RepliesPerLevel >= 1, "comments.replies_per_level 必须大于 0")
+	check(cfg.Posts.MaxContentLength >= 1, "posts.max_content_length 必须大于 0")
+	check(cfg.Posts.RenderCacheSize >= 0, "posts.render_cache_size 不能为负数")
+	check(cfg.Feeds.Title != "", "feeds.title 不能为空")
+	check(cfg.Feeds.Items >= 1 && cfg.Feeds.Items <= 100, "feeds.items 必须在 1 到 100 之间")
+	check(cfg.Comments.MaxContentLength >= 1, "comments.max_content_length 必须大于 0")
+
+	return errors.Join(errs...)
+}
+
+// withReloadable 返回一份新配置：只从 next 中取可热加载的配置项，其余保持不变
+func (cfg *Config) withReloadable(next *Config) *Config {
+	merged := *cfg
+	merged.Auth.AccessTokenTTL = next.Auth.AccessTokenTTL
+	merged.Auth.RefreshTokenTTL = next.Auth.RefreshTokenTTL
+	merged.Auth.BcryptCost = next.Auth.BcryptCost
+	merged.Auth.EmailVerificationTTL = next.Auth.EmailVerificationTTL
+	merged.Auth.PasswordResetTTL = next.Auth.PasswordResetTTL
+	merged.Auth.MFAPendingTTL = next.Auth.MFAPendingTTL
+	merged.Pagination.DefaultPageSize = next.Pagination.DefaultPageSize
+	merged.Pagination.MaxPageSize = next.Pagination.MaxPageSize
+	merged.Comments.MaxDepth = next.Comments.MaxDepth
+	merged.Comments.RepliesPerLevel = next.Comments.RepliesPerLevel
+	merged.Posts.MaxContentLength = next.Posts.MaxContentLength
+	merged.Comments.MaxContentLength = next.Comments.MaxContentLength
+	merged.SIWE = next.SIWE
+	merged.RateLimit = next.RateLimit
+	merged.Uploads = next.Uploads
+	merged.Feeds = next.Feeds
+	merged.Auth.LockoutThreshold = next.Auth.LockoutThreshold
+	merged.Auth.LockoutWindow = next.Auth.LockoutWindow
+	merged.Auth.LockoutDuration = next.Auth.LockoutDuration
+
+	if merged.Env != next.Env || !reflect.DeepEqual(merged.Server, next.Server) || merged.Database != next.Database ||
+		merged.Auth.JWTSecret != next.Auth.JWTSecret || merged.Auth.BootstrapAdmin != next.Auth.BootstrapAdmin ||
+		merged.Auth.MFAIssuer != next.Auth.MFAIssuer ||
+		merged.Posts.PublishInterval != next.Posts.PublishInterval || merged.Mail != next.Mail ||
+		merged.Posts.RenderCacheSize != next.Posts.RenderCacheSize ||
+		merged.Storage != next.Storage {
+		log.Println("配置文件中有不支持热加载的配置项被修改，需要重启服务才能生效")
+	}
+	return &merged
+}
+
+// initConfig 加载启动配置，失败时直接退出
+func initConfig() string {
+	path := configPath()
+	cfg, err := loadConfig(path)
+	if err != nil {
+		log.Fatalf("配置无效: %v", err)
+	}
+	appConfig.Store(cfg)
+	jwtKey = []byte(cfg.Auth.JWTSecret)
+	if path != "" {
+		log.Printf("已加载配置文件 %s（%s 环境）", path, cfg.Env)
+	}
+	return path
+}
+
+// watchConfigReload 收到 SIGHUP 时重新加载配置中可以安全热更新的部分
+func watchConfigReload(path string) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		next, err := loadConfig(path)
+		if err != nil {
+			log.Printf("重新加载配置失败，继续使用原配置: %v", err)
+			continue
+		}
+		appConfig.Store(currentConfig().withReloadable(next))
+		log.Println("配置已重新加载")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile 把配置写入临时目录下的 name 文件并返回路径
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+server:
+  addr: ":9090"
+auth:
+  access_token_ttl: 30m
+  bcrypt_cost: 10
+pagination:
+  max_page_size: 40
+`,
+		"config.toml": `
+[server]
+addr = ":9090"
+
+[auth]
+access_token_ttl = "30m"
+bcrypt_cost = 10
+
+[pagination]
+max_page_size = 40
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := loadConfig(writeConfigFile(t, name, content))
+			if err != nil {
+				t.Fatalf("加载配置失败: %v", err)
+			}
+			if cfg.Server.Addr != ":9090" || cfg.Auth.AccessTokenTTL.Duration != 30*time.Minute ||
+				cfg.Auth.BcryptCost != 10 || cfg.Pagination.MaxPageSize != 40 {
+				t.Errorf("配置文件中的值没有生效: %+v", cfg)
+			}
+			// 文件中没有出现的配置项保留默认值
+			if cfg.Database.Driver != DriverSQLite || cfg.Auth.RefreshTokenTTL.Duration != 7*24*time.Hour {
+				t.Errorf("未配置的项应保留默认值: %+v", cfg)
+			}
+		})
+	}
+
+	if _, err := loadConfig(writeConfigFile(t, "config.json", "{}")); err == nil {
+		t.Error("不支持的扩展名应返回错误")
+	}
+	if _, err := loadConfig(writeConfigFile(t, "config.yaml", "server: [")); err == nil {
+		t.Error("格式错误的配置文件应返回错误")
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("配置文件不存在应返回错误")
+	}
+}
+
+func TestConfigEnvOverrides(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "server:\n  addr: \":9090\"\npagination:\n  max_page_size: 40\n")
+	t.Setenv("BLOG_SERVER_ADDR", ":7070")
+	t.Setenv("BLOG_PAGINATION_MAX_PAGE_SIZE", "25")
+	t.Setenv("BLOG_ACCESS_TOKEN_TTL", "5m")
+	t.Setenv("BLOG_RATE_LIMIT_ENABLED", "false")
+	t.Setenv("BLOG_TRUSTED_PROXIES", " 10.0.0.1 ,, 10.0.0.2")
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	// 环境变量优先于配置文件
+	if cfg.Server.Addr != ":7070" || cfg.Pagination.MaxPageSize != 25 {
+		t.Errorf("环境变量应覆盖配置文件: addr=%q max_page_size=%d", cfg.Server.Addr, cfg.Pagination.MaxPageSize)
+	}
+	if cfg.Auth.AccessTokenTTL.Duration != 5*time.Minute || cfg.RateLimit.Enabled {
+		t.Errorf("时长和布尔值覆盖没有生效: %+v", cfg.Auth)
+	}
+	if got := strings.Join(cfg.Server.TrustedProxies, ","); got != "10.0.0.1,10.0.0.2" {
+		t.Errorf("代理列表应去掉空白和空项，得到 %q", got)
+	}
+
+	for name, value := range map[string]string{
+		"BLOG_BCRYPT_COST":        "ten",
+		"BLOG_REFRESH_TOKEN_TTL":  "a week",
+		"BLOG_RATE_LIMIT_ENABLED": "maybe",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := loadConfig(""); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("无效的 %s 应返回包含变量名的错误，得到 %v", name, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := defaultConfig().validate(); err != nil {
+		t.Fatalf("默认配置应通过校验: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{"未知环境", func(c *Config) { c.Env = "staging" }, "env 只能是"},
+		{"生产环境默认密钥", func(c *Config) { c.Env = "production" }, "默认的 auth.jwt_secret"},
+		{"生产环境短密钥", func(c *Config) { c.Env = "production"; c.Auth.JWTSecret = "short" }, "至少需要 32 个字符"},
+		{"mysql 缺少 parseTime", func(c *Config) { c.Database.Driver = DriverMySQL; c.Database.DSN = "u:p@/blog" }, "parseTime=True"},
+		{"refresh 短于 access", func(c *Config) { c.Auth.RefreshTokenTTL = Duration{time.Minute} }, "refresh_token_ttl"},
+		{"bcrypt 超出范围", func(c *Config) { c.Auth.BcryptCost = 99 }, "auth.bcrypt_cost"},
+		{"smtp 缺少 host", func(c *Config) { c.Mail.Driver = MailDriverSMTP }, "mail.smtp.host"},
+		{"s3 缺少 bucket", func(c *Config) { c.Storage.Driver = StorageDriverS3 }, "storage.s3"},
+		{"默认分页大于最大值", func(c *Config) { c.Pagination.DefaultPageSize = 200 }, "default_page_size"},
+		{"限流缺少周期", func(c *Config) { c.RateLimit.Login.Per = Duration{} }, "rate_limit.login.per"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tt.mutate(cfg)
+			if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("期望包含 %q 的错误，得到 %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// 所有错误一起返回，而不是只报第一个
+	cfg := defaultConfig()
+	cfg.Server.Addr = ""
+	cfg.Feeds.Items = 0
+	err := cfg.validate()
+	if err == nil || !strings.Contains(err.Error(), "server.addr") || !strings.Contains(err.Error(), "feeds.items") {
+		t.Errorf("应同时返回多个校验错误，得到 %v", err)
+	}
+}
+
+func TestConfigWithReloadable(t *testing.T) {
+	current := defaultConfig()
+	next := defaultConfig()
+	next.Pagination.MaxPageSize = 20
+	next.Auth.LockoutThreshold = 3
+	next.Feeds.Title = "新标题"
+	next.Server.Addr = ":9999"
+	next.Auth.JWTSecret = "another-secret"
+	next.Database.DSN = "other.db"
+
+	merged := current.withReloadable(next)
+	if merged.Pagination.MaxPageSize != 20 || merged.Auth.LockoutThreshold != 3 || merged.Feeds.Title != "新标题" {
+		t.Errorf("可热加载的配置项应更新: %+v", merged)
+	}
+	if merged.Server.Addr != ":8080" || merged.Auth.JWTSecret != defaultJWTSecret || merged.Database.DSN != "blog.db" {
+		t.Errorf("需要重启的配置项不应被热加载修改: %+v", merged)
+	}
+	if current.Pagination.MaxPageSize != 100 {
+		t.Error("withReloadable 不应修改原配置")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// dialectorFor 根据 database.driver 选择 GORM 方言
+// DSN 示例：
+//
+//	sqlite:   blog.db
+//	postgres: host=localhost user=blog password=secret dbname=blog port=5432 sslmode=disable
+//	mysql:    blog:secret@tcp(127.0.0.1:3306)/blog?charset=utf8mb4&parseTime=True&loc=Local
+func dialectorFor(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case DriverSQLite:
+		return sqlite.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
+
+// openDatabase 按配置打开数据库并设置连接池
+func openDatabase() (*gorm.DB, error) {
+	cfg := currentConfig().Database
+	dialector, err := dialectorFor(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	// TranslateError 把各数据库的唯一约束、外键错误统一转换为 gorm.ErrDuplicatedKey 等，便于映射错误码
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime.Duration > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+	}
+	return db, nil
+}
+
+// isSQLite 判断当前连接是否为 SQLite，部分功能（如 FTS5 全文索引）只在 SQLite 上可用
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == DriverSQLite
+}
@@ -0,0 +1,12 @@
+//go:build integration
+
+package main
+
+import "os"
+
+func init() {
+	testDatabases = append(testDatabases,
+		testDatabase{Name: DriverPostgres, Driver: DriverPostgres, DSN: os.Getenv("BLOG_TEST_POSTGRES_DSN")},
+		testDatabase{Name: DriverMySQL, Driver: DriverMySQL, DSN: os.Getenv("BLOG_TEST_MYSQL_DSN")},
+	)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 依赖具体数据库方言的查询（窗口函数、LIKE ... ESCAPE、按时间戳做条件更新）在每种数据库上都要验证。
+// 默认只测试 SQLite；使用 integration 构建标签并设置 DSN 后同时测试 PostgreSQL 和 MySQL：
+//
+//	BLOG_TEST_POSTGRES_DSN="host=localhost user=blog password=secret dbname=blog_test sslmode=disable" \
+//	BLOG_TEST_MYSQL_DSN="blog:secret@tcp(127.0.0.1:3306)/blog_test?charset=utf8mb4&parseTime=True&loc=Local" \
+//	go test -tags integration ./...
+//
+// 测试会回滚并重建测试库中的全部表，不要指向有数据的数据库。
+
+// testDatabase 数据库测试矩阵中的一项，DSN 为空时使用临时的 SQLite 文件
+type testDatabase struct {
+	Name   string
+	Driver string
+	DSN    string
+}
+
+// testDatabases 测试矩阵，integration 构建标签会加入 PostgreSQL 和 MySQL，见 dbmatrix_integration_test.go
+var testDatabases = []testDatabase{{Name: DriverSQLite, Driver: DriverSQLite}}
+
+// forEachDatabase 在矩阵中的每个数据库上运行 fn，没有配置 DSN 的外部数据库跳过
+func forEachDatabase(t *testing.T, fn func(t *testing.T, r *gin.Engine)) {
+	for _, db := range testDatabases {
+		db := db
+		t.Run(db.Name, func(t *testing.T) {
+			if db.Driver != DriverSQLite && db.DSN == "" {
+				t.Skipf("未设置 %s 的测试 DSN", db.Name)
+			}
+			r := setupTestApp(t, func(cfg *Config) {
+				if db.DSN != "" {
+					cfg.Database.Driver = db.Driver
+					cfg.Database.DSN = db.DSN
+				}
+			})
+			fn(t, r)
+		})
+	}
+}
+
+// createTestPost 直接在数据库中创建一篇已发布的文章
+func createTestPost(t *testing.T, author User, title, content string) Post {
+	t.Helper()
+	post := Post{Title: title, Content: content, ContentFormat: ContentFormatPlain, Status: PostStatusPublished, UserID: author.ID}
+	if err := DB.Create(&post).Error; err != nil {
+		t.Fatalf("创建文章失败: %v", err)
+	}
+	return post
+}
+
+// 评论树每层回复用 ROW_NUMBER() 窗口函数取每条父评论最早的几条
+func TestDBCommentThread(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, r *gin.Engine) {
+		author := createTestUser(t, "author", RoleUser)
+		post := createTestPost(t, author, "thread", "content")
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+		newComment := func(content string, parent *Comment, offset int) Comment {
+			cmt := Comment{Content: content, PostID: post.ID, UserID: author.ID, Status: CommentStatusApproved}
+			cmt.CreatedAt = base.Add(time.Duration(offset) * time.Second)
+			if parent != nil {
+				cmt.ParentID = &parent.ID
+				cmt.Depth = parent.Depth + 1
+			}
+			if err := DB.Create(&cmt).Error; err != nil {
+				t.Fatalf("创建评论失败: %v", err)
+			}
+			return cmt
+		}
+		first := newComment("first", nil, 0)
+		second := newComment("second", nil, 1)
+		// 倒序插入，结果仍应按时间排列
+		for i := 7; i >= 1; i-- {
+			newComment(fmt.Sprintf("reply %d", i), &first, 10+i)
+		}
+		nested := newComment("reply to second", &second, 20)
+		newComment("nested", &nested, 21)
+		hidden := newComment("hidden", &second, 22)
+		DB.Model(&hidden).Update("status", CommentStatusHidden)
+
+		w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/posts/%d/comments", post.ID), "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("获取评论失败: %d %s", w.Code, w.Body.String())
+		}
+		comments := decodeJSON(t, w)["comments"].([]interface{})
+		if len(comments) != 2 {
+			t.Fatalf("应有 2 条顶层评论，得到 %d 条", len(comments))
+		}
+
+		top := comments[0].(map[string]interface{})
+		replies := top["replies"].([]interface{})
+		perParent := currentConfig().Comments.RepliesPerLevel
+		if len(replies) != perParent || top["reply_count"].(float64) != 7 || top["has_more_replies"] != true {
+			t.Fatalf("第一条评论应展开 %d/7 条回复且还有更多: %v", perParent, top)
+		}
+		for i, item := range replies {
+			if got, want := item.(map[string]interface{})["content"], fmt.Sprintf("reply %d", i+1); got != want {
+				t.Errorf("第 %d 条回复为 %v，期望 %s", i, got, want)
+			}
+		}
+
+		other := comments[1].(map[string]interface{})
+		replies = other["replies"].([]interface{})
+		if len(replies) != 1 || other["reply_count"].(float64) != 1 {
+			t.Fatalf("隐藏的回复不应返回: %v", other)
+		}
+		if deeper := replies[0].(map[string]interface{})["replies"].([]interface{}); len(deeper) != 1 {
+			t.Fatalf("应继续展开下一层回复: %v", replies[0])
+		}
+	})
+}
+
+// 被隐藏、删除的评论下面还有可见回复时作为占位节点返回，用递归 CTE 查找可见回复的祖先
+func TestDBCommentTombstones(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, r *gin.Engine) {
+		author := createTestUser(t, "author", RoleUser)
+		post := createTestPost(t, author, "thread", "content")
+		newComment := func(content string, parent *Comment) Comment {
+			cmt := Comment{Content: content, PostID: post.ID, UserID: author.ID, Status: CommentStatusApproved}
+			if parent != nil {
+				cmt.ParentID = &parent.ID
+				cmt.Depth = parent.Depth + 1
+			}
+			if err := DB.Create(&cmt).Error; err != nil {
+				t.Fatalf("创建评论失败: %v", err)
+			}
+			return cmt
+		}
+		// deleted → hidden → reply：两层占位节点之下的回复仍然可见
+		deleted := newComment("deleted", nil)
+		hidden := newComment("hidden", &deleted)
+		newComment("reply", &hidden)
+		// 没有可见回复的隐藏评论不返回
+		alone := newComment("alone", nil)
+		newComment("rejected reply", &alone)
+		DB.Model(&hidden).Update("status", CommentStatusHidden)
+		DB.Model(&Comment{}).Where("parent_id = ?", alone.ID).Update("status", CommentStatusRejected)
+		DB.Model(&alone).Update("status", CommentStatusHidden)
+		DB.Delete(&deleted)
+
+		w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/posts/%d/comments", post.ID), "", nil)
+		comments := decodeJSON(t, w)["comments"].([]interface{})
+		if len(comments) != 1 {
+			t.Fatalf("应只有 1 条顶层评论，得到 %d 条: %s", len(comments), w.Body.String())
+		}
+		firstReply := func(node map[string]interface{}) map[string]interface{} {
+			t.Helper()
+			replies := node["replies"].([]interface{})
+			if len(replies) != 1 {
+				t.Fatalf("应有 1 条回复: %v", node)
+			}
+			return replies[0].(map[string]interface{})
+		}
+		deletedNode := comments[0].(map[string]interface{})
+		hiddenNode := firstReply(deletedNode)
+		for _, node := range []map[string]interface{}{deletedNode, hiddenNode} {
+			if node["removed"] != true || node["content"] != "" || node["user_id"] != float64(0) || node["username"] != "" {
+				t.Errorf("占位节点不应返回内容和作者: %v", node)
+			}
+		}
+		if reply := firstReply(hiddenNode); reply["content"] != "reply" || reply["removed"] != nil {
+			t.Errorf("占位节点下的回复应正常返回: %v", reply)
+		}
+
+		w = doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/comments/%d/replies", hidden.ID), "", nil)
+		if w.Code != http.StatusOK || len(decodeJSON(t, w)["replies"].([]interface{})) != 1 {
+			t.Errorf("隐藏评论的回复仍应可以获取: %d %s", w.Code, w.Body.String())
+		}
+		if w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/comments/%d/replies", alone.ID), "", nil); w.Code != http.StatusNotFound {
+			t.Errorf("没有可见回复的隐藏评论应返回 404: %d", w.Code)
+		}
+	})
+}
+
+// LIKE 搜索要转义关键词中的 %、_ 和转义字符本身
+func TestDBLikeSearchEscape(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, r *gin.Engine) {
+		// 在 SQLite 上也强制使用 LIKE 匹配
+		searchEnabled = false
+		author := createTestUser(t, "author", RoleUser)
+		createTestPost(t, author, "100% done", "literal percent")
+		createTestPost(t, author, "1000 items", "no percent here")
+		createTestPost(t, author, "snake a_b case", "underscore")
+		createTestPost(t, author, "snake axb case", "any character")
+		createTestPost(t, author, "wow!! bang", "escape character")
+		createTestPost(t, author, "Mixed CASE Title", "case")
+
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{"100%", []string{"100% done"}},
+			{"a_b", []string{"snake a_b case"}},
+			{"w!!", []string{"wow!! bang"}},
+			{"mixed case", []string{"Mixed CASE Title"}},
+			{"snake case", []string{"snake a_b case", "snake axb case"}},
+		}
+		for _, tt := range tests {
+			w := doJSON(r, http.MethodGet, "/api/v1/search?q="+url.QueryEscape(tt.query), "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("搜索 %q 失败: %d %s", tt.query, w.Code, w.Body.String())
+			}
+			results := decodeJSON(t, w)["results"].([]interface{})
+			got := make(map[string]bool)
+			for _, item := range results {
+				var post Post
+				DB.First(&post, uint(item.(map[string]interface{})["id"].(float64)))
+				got[post.Title] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("搜索 %q 得到 %v，期望 %v", tt.query, got, tt.want)
+				continue
+			}
+			for _, title := range tt.want {
+				if !got[title] {
+					t.Errorf("搜索 %q 得到 %v，期望 %v", tt.query, got, tt.want)
+				}
+			}
+		}
+	})
+}
+
+// 更新文章时按读出的 updated_at 做条件更新，各数据库的时间精度不同，读出的值必须能原样匹配
+func TestDBPostUpdateCompareAndSwap(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, r *gin.Engine) {
+		author := createTestUser(t, "author", RoleUser)
+		token := testToken(t, author)
+		post := createTestPost(t, author, "v0", "content")
+		path := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+
+		w := doJSON(r, http.MethodGet, path, "", nil)
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("获取文章失败: %d %s", w.Code, w.Body.String())
+		}
+		stale := etag
+		for i := 1; i <= 3; i++ {
+			body := map[string]string{"title": fmt.Sprintf("v%d", i), "content": "content"}
+			w = doJSON(r, http.MethodPut, path, token, body, "If-Match", etag)
+			if w.Code != http.StatusOK {
+				t.Fatalf("第 %d 次更新失败: %d %s", i, w.Code, w.Body.String())
+			}
+			etag = w.Header().Get("ETag")
+		}
+
+		w = doJSON(r, http.MethodPut, path, token, map[string]string{"title": "stale", "content": "content"}, "If-Match", stale)
+		if w.Code != http.StatusPreconditionFailed || errorCode(t, w) != CodePostVersionConflict {
+			t.Fatalf("过期的 If-Match 应返回 412: %d %s", w.Code, w.Body.String())
+		}
+
+		// If-Match 校验之后、保存之前文章被修改时，条件更新不会命中任何行
+		var current Post
+		DB.First(&current, post.ID)
+		result := DB.Model(&Post{}).Where("id = ? AND updated_at = ?", current.ID, current.UpdatedAt).
+			UpdateColumn("updated_at", time.Now())
+		if result.Error != nil || result.RowsAffected != 1 {
+			t.Fatalf("按读出的 updated_at 更新应命中 1 行: %v %d", result.Error, result.RowsAffected)
+		}
+		result = DB.Model(&Post{}).Where("id = ? AND updated_at = ?", current.ID, current.UpdatedAt).
+			UpdateColumn("updated_at", time.Now())
+		if result.Error != nil || result.RowsAffected != 0 {
+			t.Fatalf("updated_at 已变化时不应命中: %v %d", result.Error, result.RowsAffected)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// 错误码：客户端应根据 code 判断错误类型，message 只用于展示，可能会调整
+// code 一经发布不要修改，新增错误时按 "模块.错误" 的格式命名
+const (
+	CodeInvalidRequest   = "request.invalid"
+	CodeValidationFailed = "request.validation_failed"
+	CodeRouteNotFound    = "request.route_not_found"
+	CodeRateLimited      = "request.rate_limited"
+
+	CodeUnauthorized        = "auth.unauthorized"
+	CodeTokenExpired        = "auth.token_expired"
+	CodeTokenRevoked        = "auth.token_revoked"
+	CodeInvalidCredentials  = "auth.invalid_credentials"
+	CodeRefreshTokenInvalid = "auth.refresh_token_invalid"
+	CodeRefreshTokenReused  = "auth.refresh_token_reused"
+	CodeForbidden           = "auth.forbidden"
+	CodeAccountLocked       = "auth.account_locked"
+	CodeAPIKeyInvalid       = "auth.api_key_invalid"
+	CodeAPIKeyExpired       = "auth.api_key_expired"
+	CodeAPIKeyNotAllowed    = "auth.api_key_not_allowed"
+	CodeInsufficientScope   = "auth.insufficient_scope"
+	CodeEmailNotVerified    = "auth.email_not_verified"
+	CodeResetTokenInvalid   = "auth.reset_token_invalid"
+
+	CodeMFATokenInvalid       = "auth.mfa_token_invalid"
+	CodeMFAInvalidCode        = "auth.mfa_invalid_code"
+	CodeMFAEnrollmentRequired = "auth.mfa_enrollment_required"
+	CodeMFAAlreadyEnabled     = "auth.mfa_already_enabled"
+	CodeMFANotEnabled         = "auth.mfa_not_enabled"
+	CodeMFASetupRequired      = "auth.mfa_setup_required"
+	CodeMFARequiredByRole     = "auth.mfa_required_by_role"
+
+	CodeSIWEInvalidMessage   = "auth.siwe_invalid_message"
+	CodeSIWEInvalidSignature = "auth.siwe_invalid_signature"
+	CodeSIWENonceInvalid     = "auth.siwe_nonce_invalid"
+
+	CodeUsernameTaken = "user.username_taken"
+	CodeEmailTaken    = "user.email_taken"
+	CodeUserNotFound  = "user.not_found"
+	CodeInvalidRole   = "user.invalid_role"
+	CodeInvalidLocale = "user.invalid_locale"
+
+	CodeEmailAlreadyVerified     = "user.email_already_verified"
+	CodeVerificationTokenInvalid = "user.verification_token_invalid"
+	CodeEmailMissing             = "user.email_missing"
+
+	CodePasswordNotSet     = "user.password_not_set"
+	CodeDeleteConfirmation = "user.delete_confirmation_mismatch"
+
+	CodeWalletTaken     = "user.wallet_taken"
+	CodeWalletNotLinked = "user.wallet_not_linked"
+	CodeWalletRequired  = "user.wallet_required"
+
+	CodePostNotFound           = "post.not_found"
+	CodeInvalidPostStatus      = "post.invalid_status"
+	CodeInvalidPublishAt       = "post.invalid_publish_at"
+	CodePostVersionConflict    = "post.version_conflict"
+	CodeRevisionNotFound       = "revision.not_found"
+	CodeInvalidRevisionVersion = "revision.invalid_version"
+
+	CodeCommentNotFound         = "comment.not_found"
+	CodeCommentDepthExceeded    = "comment.depth_exceeded"
+	CodeCommentLocked           = "comment.locked"
+	CodeInvalidModerationStatus = "comment.invalid_moderation_status"
+
+	CodeAttachmentNotFound     = "attachment.not_found"
+	CodeAttachmentFileRequired = "attachment.file_required"
+	CodeFileTooLarge           = "attachment.too_large"
+	CodeUnsupportedMediaType   = "attachment.unsupported_type"
+	CodeInvalidImage           = "attachment.invalid_image"
+
+	CodeAPIKeyNotFound      = "api_key.not_found"
+	CodeInvalidAPIKeyExpiry = "api_key.invalid_expires_at"
+
+	CodeInvalidCursor   = "pagination.invalid_cursor"
+	CodeInvalidPageSize = "pagination.invalid_page_size"
+	CodePageUnsupported = "pagination.page_unsupported"
+	CodeSearchQuery     = "search.invalid_query"
+
+	CodeNotFound = "resource.not_found"
+	CodeConflict = "resource.conflict"
+	CodeInternal = "server.internal_error"
+)
+
+// APIError 统一的错误响应，序列化为 {"error": {...}}
+// Message 在输出前按请求的语言由 MessageID 翻译得到，创建时先填入默认语言的文本用于日志
+type APIError struct {
+	Status    int           `json:"-"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Details   interface{}   `json:"details,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	MessageID string        `json:"-"`
+	Args      []interface{} `json:"-"` // 消息模板参数
+	Err       error         `json:"-"` // 原始错误，只写入日志，不返回给客户端
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError 创建一个错误响应，messageID 为消息目录中的 ID
+func newAPIError(status int, code, messageID string, args ...interface{}) *APIError {
+	return &APIError{
+		Status:    status,
+		Code:      code,
+		Message:   translate(supportedLocales[0], messageID, args...),
+		MessageID: messageID,
+		Args:      args,
+	}
+}
+
+// WithDetails 返回附带详细信息的副本，预定义的错误变量可以放心使用
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+// Wrap 返回记录了原始错误的副本
+func (e *APIError) Wrap(err error) *APIError {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+// internalError 服务端错误，messageID 说明哪一步失败，原始错误只写日志
+func internalError(messageID string, err error) *APIError {
+	return newAPIError(http.StatusInternalServerError, CodeInternal, messageID).Wrap(err)
+}
+
+// 常用的错误
+var (
+	errAuthRequired        = newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.unauthenticated")
+	errInvalidCredentials  = newAPIError(http.StatusUnauthorized, CodeInvalidCredentials, "auth.invalid_credentials")
+	errPostNotFound        = newAPIError(http.StatusNotFound, CodePostNotFound, "post.not_found")
+	errPostVersionConflict = newAPIError(http.StatusPreconditionFailed, CodePostVersionConflict, "post.version_conflict")
+	errCommentNotFound     = newAPIError(http.StatusNotFound, CodeCommentNotFound, "comment.not_found")
+	errInvalidPostID       = newAPIError(http.StatusBadRequest, CodeInvalidRequest, "post.invalid_id")
+	errInvalidID           = newAPIError(http.StatusBadRequest, CodeInvalidRequest, "request.invalid_id")
+)
+
+// FieldError 校验失败的字段，Message 为按请求语言翻译的说明
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	fe validator.FieldError
+}
+
+// badRequest 把请求绑定错误转换为错误响应：字段校验失败时列出具体字段
+func badRequest(err error) *APIError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			fields = append(fields, FieldError{Field: fe.Field(), Rule: fe.Tag(), Param: fe.Param(), fe: fe})
+		}
+		return newAPIError(http.StatusBadRequest, CodeValidationFailed, "request.validation_failed").WithDetails(fields).Wrap(err)
+	}
+	return newAPIError(http.StatusBadRequest, CodeInvalidRequest, "request.invalid").Wrap(err)
+}
+
+// toAPIError 把任意错误映射为错误响应
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		cp := *apiErr
+		return &cp
+	}
+
+	var (
+		verrs     validator.ValidationErrors
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &verrs), errors.As(err, &syntaxErr), errors.As(err, &typeErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest(err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return newAPIError(http.StatusNotFound, CodeNotFound, "resource.not_found").Wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return newAPIError(http.StatusConflict, CodeConflict, "resource.conflict").Wrap(err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return newAPIError(http.StatusConflict, CodeConflict, "resource.fk_conflict").Wrap(err)
+	}
+	return internalError("server.internal_error", err)
+}
+
+// abortWithError 记录错误并中止请求，响应由 ErrorMiddleware 统一输出
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// writeAPIError 输出错误响应并写日志；日志使用默认语言，响应按请求的语言翻译
+func writeAPIError(c *gin.Context, apiErr *APIError) {
+	apiErr.RequestID = c.GetString("requestID")
+	if apiErr.Err != nil {
+		log.Printf("错误: [%s] %s %s -> %d %s: %s, 详情: %v",
+			apiErr.RequestID, c.Request.Method, c.Request.URL.Path, apiErr.Status, apiErr.Code, apiErr.Message, apiErr.Err)
+	} else {
+		log.Printf("错误: [%s] %s %s -> %d %s: %s",
+			apiErr.RequestID, c.Request.Method, c.Request.URL.Path, apiErr.Status, apiErr.Code, apiErr.Message)
+	}
+
+	locale := localeOf(c)
+	if apiErr.MessageID != "" {
+		apiErr.Message = translate(locale, apiErr.MessageID, apiErr.Args...)
+	}
+	if fields, ok := apiErr.Details.([]FieldError); ok {
+		translateFieldErrors(locale, fields)
+	}
+	c.AbortWithStatusJSON(apiErr.Status, gin.H{"error": apiErr})
+}
+
+// ErrorMiddleware 在处理函数返回后，把 c.Errors 中的最后一个错误转换为统一的错误响应
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		writeAPIError(c, toAPIError(c.Errors.Last().Err))
+	}
+}
+
+// recoverWithAPIError 处理函数 panic 时返回统一的 500 响应
+func recoverWithAPIError(c *gin.Context, recovered interface{}) {
+	writeAPIError(c, internalError("server.internal_error", fmt.Errorf("panic: %v", recovered)))
+}
+
+// requestIDPattern 允许客户端传入的请求 ID 格式
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware 为每个请求分配 ID（或沿用合法的 X-Request-ID），写入响应头和错误响应
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id, _ = randomToken(8)
+		}
+		c.Set("requestID", id)
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// NoRouteHandler 未匹配到路由时返回统一的 404 响应
+func NoRouteHandler(c *gin.Context) {
+	abortWithError(c, newAPIError(http.StatusNotFound, CodeRouteNotFound, "request.route_not_found"))
+}
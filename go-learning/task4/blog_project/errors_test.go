@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestToAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"APIError 原样返回", errPostNotFound, http.StatusNotFound, CodePostNotFound},
+		{"包装过的 APIError", fmt.Errorf("查询文章: %w", errPostNotFound), http.StatusNotFound, CodePostNotFound},
+		{"记录不存在", gorm.ErrRecordNotFound, http.StatusNotFound, CodeNotFound},
+		{"唯一键冲突", gorm.ErrDuplicatedKey, http.StatusConflict, CodeConflict},
+		{"外键冲突", gorm.ErrForeignKeyViolated, http.StatusConflict, CodeConflict},
+		{"JSON 语法错误", &json.SyntaxError{}, http.StatusBadRequest, CodeInvalidRequest},
+		{"JSON 类型错误", &json.UnmarshalTypeError{}, http.StatusBadRequest, CodeInvalidRequest},
+		{"空请求体", io.EOF, http.StatusBadRequest, CodeInvalidRequest},
+		{"其他错误", errors.New("boom"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toAPIError(tt.err)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode {
+				t.Errorf("toAPIError(%v) = %d %s，期望 %d %s", tt.err, got.Status, got.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+
+	// 返回的是副本，写入 RequestID 等字段不会污染预定义的错误变量
+	toAPIError(errPostNotFound).RequestID = "req-1"
+	errPostNotFound.WithDetails("details")
+	errPostNotFound.Wrap(errors.New("cause"))
+	if errPostNotFound.RequestID != "" || errPostNotFound.Details != nil || errPostNotFound.Err != nil {
+		t.Errorf("预定义的错误变量被修改: %+v", errPostNotFound)
+	}
+}
+
+// newErrorTestEngine 只挂载错误处理相关中间件的路由，不需要数据库
+func newErrorTestEngine() *gin.Engine {
+	initValidator()
+	r := gin.New()
+	r.Use(gin.CustomRecovery(recoverWithAPIError), RequestIDMiddleware(), ErrorMiddleware())
+	r.NoRoute(NoRouteHandler)
+	r.GET("/internal", func(c *gin.Context) {
+		abortWithError(c, errors.New("连接数据库失败: password=hunter2"))
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("unexpected")
+	})
+	r.POST("/bind", func(c *gin.Context) {
+		var req struct {
+			Title string `json:"title" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, badRequest(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	r := newErrorTestEngine()
+	do := func(method, path, body string, headers ...string) (*httptest.ResponseRecorder, APIError) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var envelope struct {
+			Error APIError `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("响应不是错误信封: %s", w.Body.String())
+		}
+		return w, envelope.Error
+	}
+
+	w, apiErr := do(http.MethodGet, "/internal", "", "X-Request-ID", "trace-123")
+	if w.Code != http.StatusInternalServerError || apiErr.Code != CodeInternal {
+		t.Errorf("应返回 500 %s: %d %+v", CodeInternal, w.Code, apiErr)
+	}
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Errorf("原始错误不应返回给客户端: %s", w.Body.String())
+	}
+	if apiErr.RequestID != "trace-123" || w.Header().Get("X-Request-ID") != "trace-123" {
+		t.Errorf("合法的 X-Request-ID 应原样沿用: %q %q", apiErr.RequestID, w.Header().Get("X-Request-ID"))
+	}
+
+	// 不合法的请求 ID 会被替换为随机值
+	w, apiErr = do(http.MethodGet, "/panic", "", "X-Request-ID", "bad id\n")
+	if w.Code != http.StatusInternalServerError || apiErr.Code != CodeInternal {
+		t.Errorf("panic 应返回统一的 500 响应: %d %+v", w.Code, apiErr)
+	}
+	if id := w.Header().Get("X-Request-ID"); id == "" || id == "bad id\n" || apiErr.RequestID != id {
+		t.Errorf("应生成新的请求 ID: header=%q body=%q", id, apiErr.RequestID)
+	}
+
+	w, apiErr = do(http.MethodGet, "/nowhere", "", "Accept-Language", "en-US")
+	if w.Code != http.StatusNotFound || apiErr.Code != CodeRouteNotFound || apiErr.Message != "Endpoint not found" {
+		t.Errorf("未知路由应返回英文的 404: %d %+v", w.Code, apiErr)
+	}
+
+	w, apiErr = do(http.MethodPost, "/bind", "{}", "Accept-Language", "en")
+	if w.Code != http.StatusBadRequest || apiErr.Code != CodeValidationFailed {
+		t.Fatalf("校验失败应返回 400 %s: %d %+v", CodeValidationFailed, w.Code, apiErr)
+	}
+	details, _ := json.Marshal(apiErr.Details)
+	var fields []FieldError
+	if err := json.Unmarshal(details, &fields); err != nil || len(fields) != 1 {
+		t.Fatalf("details 应列出校验失败的字段: %s", details)
+	}
+	if fields[0].Field != "title" || fields[0].Rule != "required" || !strings.Contains(fields[0].Message, "required") {
+		t.Errorf("字段错误应使用 JSON 字段名并按请求语言翻译: %+v", fields[0])
+	}
+
+	if w, apiErr = do(http.MethodPost, "/bind", "{"); w.Code != http.StatusBadRequest || apiErr.Code != CodeInvalidRequest {
+		t.Errorf("JSON 格式错误应返回 400 %s: %d %+v", CodeInvalidRequest, w.Code, apiErr)
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 订阅源：RSS 2.0、Atom 1.0 和 JSON Feed 1.1
+// 全站：/feed.rss；按作者：/users/:username/feed.rss；按标签：/tags/:tag/feed.rss
+// 文章来自与文章列表相同的查询，也支持 ?tag= 和 ?category= 筛选
+
+// 支持的订阅源格式，也是路由的扩展名
+const (
+	FeedFormatRSS  = "rss"
+	FeedFormatAtom = "atom"
+	FeedFormatJSON = "json"
+)
+
+var feedContentTypes = map[string]string{
+	FeedFormatRSS:  "application/rss+xml; charset=utf-8",
+	FeedFormatAtom: "application/atom+xml; charset=utf-8",
+	FeedFormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// feedChannel 与格式无关的订阅源内容
+type feedChannel struct {
+	Title       string
+	Description string
+	HomeURL     string // 订阅源对应的网页
+	SelfURL     string // 订阅源自身的地址
+	SiteURL     string
+	Updated     time.Time
+	Posts       []Post
+}
+
+// postURL 文章在前端的地址
+func (f *feedChannel) postURL(p Post) string {
+	return f.SiteURL + "/posts/" + strconv.FormatUint(uint64(p.ID), 10)
+}
+
+// authorURL 作者主页的地址
+func (f *feedChannel) authorURL(u User) string {
+	return f.SiteURL + "/users/" + u.Username
+}
+
+// publishedAt 文章的发布时间，旧数据没有发布时间时使用创建时间
+func publishedAt(p Post) time.Time {
+	if p.PublishAt != nil {
+		return *p.PublishAt
+	}
+	return p.CreatedAt
+}
+
+// feedSiteURL 文章链接的前缀，未配置时使用邮件中链接的前缀
+func feedSiteURL(cfg *Config) string {
+	site := cfg.Feeds.SiteURL
+	if site == "" {
+		site = cfg.Mail.BaseURL
+	}
+	return strings.TrimSuffix(site, "/")
+}
+
+// FeedHandler 返回指定格式的订阅源处理函数
+func FeedHandler(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Printf("获取订阅源: %s", c.Request.URL.RequestURI())
+		cfg := currentConfig()
+		site := feedSiteURL(cfg)
+		feed := &feedChannel{
+			Title:       cfg.Feeds.Title,
+			Description: cfg.Feeds.Description,
+			HomeURL:     site + "/",
+			SelfURL:     site + c.Request.URL.RequestURI(),
+			SiteURL:     site,
+		}
+
+		query := postListQuery(c).Preload("User", withDeletedUsers)
+		var authorID uint
+		if username := c.Param("username"); username != "" {
+			var author User
+			if err := DB.Where("username = ?", username).First(&author).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					abortWithError(c, newAPIError(http.StatusNotFound, CodeUserNotFound, "user.not_found"))
+				} else {
+					abortWithError(c, internalError("feed.failed", err))
+				}
+				return
+			}
+			authorID = author.ID
+			query = query.Where("posts.user_id = ?", author.ID)
+			feed.Title += " - " + displayName(author)
+			feed.HomeURL = feed.authorURL(author)
+		}
+		if tag := strings.ToLower(strings.TrimSpace(c.Param("tag"))); tag != "" {
+			feed.Title += " - #" + tag
+			feed.HomeURL = site + "/tags/" + url.PathEscape(tag)
+		}
+
+		if err := query.Order("posts.created_at desc").Order("posts.id desc").
+			Limit(cfg.Feeds.Items).Find(&feed.Posts).Error; err != nil {
+			abortWithError(c, internalError("feed.failed", err))
+			return
+		}
+
+		// 文章的增删改、归档以及文章移出最近的 N 篇都会改变 ETag；订阅源的内容不随语言变化
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", format, feed.Title, feed.Description, feed.SelfURL)
+		for _, p := range feed.Posts {
+			fmt.Fprintf(h, "%d:%d:%s\n", p.ID, p.UpdatedAt.UnixNano(), displayName(p.User))
+			if p.UpdatedAt.After(feed.Updated) {
+				feed.Updated = p.UpdatedAt
+			}
+		}
+		etag := `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+		lastModified, err := feedLastModified(authorID, feed.Posts)
+		if err != nil {
+			abortWithError(c, internalError("feed.failed", err))
+			return
+		}
+		c.Header("Cache-Control", "public, no-cache")
+		if checkNotModified(c, etag, lastModified) {
+			return
+		}
+
+		var body []byte
+		switch format {
+		case FeedFormatRSS:
+			body, err = feed.rss()
+		case FeedFormatAtom:
+			body, err = feed.atom()
+		default:
+			body, err = feed.jsonFeed()
+		}
+		if err != nil {
+			abortWithError(c, internalError("feed.failed", err))
+			return
+		}
+		c.Data(http.StatusOK, feedContentTypes[format], body)
+	}
+}
+
+// feedLastModified 订阅源的最近一次修改时间
+// 删除、归档的文章以及被新文章挤出最近 N 篇的文章不在列表中，但同样会改变订阅源，
+// 所以取范围内所有文章（包括已删除的）最近一次修改和删除的时间，再加上列出的文章作者最近一次修改资料的时间
+func feedLastModified(authorID uint, posts []Post) (time.Time, error) {
+	scope := func(db *gorm.DB) *gorm.DB {
+		db = db.Unscoped().Model(&Post{})
+		if authorID != 0 {
+			db = db.Where("user_id = ?", authorID)
+		}
+		return db
+	}
+
+	var updated, deleted Post
+	if err := DB.Scopes(scope).Select("updated_at").Order("updated_at desc").Limit(1).Find(&updated).Error; err != nil {
+		return time.Time{}, err
+	}
+	if err := DB.Scopes(scope).Select("deleted_at").Where("deleted_at IS NOT NULL").
+		Order("deleted_at desc").Limit(1).Find(&deleted).Error; err != nil {
+		return time.Time{}, err
+	}
+	lastModified := updated.UpdatedAt
+	if deleted.DeletedAt.Valid && deleted.DeletedAt.Time.After(lastModified) {
+		lastModified = deleted.DeletedAt.Time
+	}
+
+	var authorIDs []uint
+	if authorID != 0 {
+		authorIDs = append(authorIDs, authorID)
+	}
+	for _, p := range posts {
+		authorIDs = append(authorIDs, p.UserID)
+	}
+	var author User
+	if err := DB.Unscoped().Model(&User{}).Select("updated_at").Where("id IN ?", authorIDs).
+		Order("updated_at desc").Limit(1).Find(&author).Error; err != nil {
+		return time.Time{}, err
+	}
+	if author.UpdatedAt.After(lastModified) {
+		lastModified = author.UpdatedAt
+	}
+	return lastModified, nil
+}
+
+// RSS 2.0，作者使用 dc:creator（RSS 的 author 元素要求是邮箱地址）
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	SelfLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (f *feedChannel) rss() ([]byte, error) {
+	doc := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			SelfLink:    rssAtomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, p := range f.Posts {
+		item := rssItem{
+			Title:       p.Title,
+			Link:        f.postURL(p),
+			GUID:        rssGUID{IsPermaLink: true, Value: f.postURL(p)},
+			PubDate:     publishedAt(p).UTC().Format(time.RFC1123Z),
+			Creator:     displayName(p.User),
+			Description: renderPostContent(p.ContentFormat, p.Content).HTML,
+		}
+		for _, tag := range p.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return marshalXMLDocument(doc)
+}
+
+// Atom 1.0
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func (f *feedChannel) atom() ([]byte, error) {
+	// Atom 要求 updated 必填，没有文章时使用 Unix 纪元，保证同样的内容得到同样的响应
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	doc := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.SelfURL,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, p := range f.Posts {
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        f.postURL(p),
+			Link:      atomLink{Href: f.postURL(p), Rel: "alternate", Type: "text/html"},
+			Published: publishedAt(p).UTC().Format(time.RFC3339),
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: displayName(p.User), URI: f.authorURL(p.User)},
+			Content:   atomContent{Type: "html", Body: renderPostContent(p.ContentFormat, p.Content).HTML},
+		}
+		for _, tag := range p.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag.Name})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXMLDocument(doc)
+}
+
+// marshalXMLDocument 输出带 XML 声明的文档
+func marshalXMLDocument(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// JSON Feed 1.1：https://www.jsonfeed.org/version/1.1/
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+func (f *feedChannel) jsonFeed() ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.SelfURL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, p := range f.Posts {
+		item := jsonFeedItem{
+			ID:            strconv.FormatUint(uint64(p.ID), 10),
+			URL:           f.postURL(p),
+			Title:         p.Title,
+			ContentHTML:   renderPostContent(p.ContentFormat, p.Content).HTML,
+			DatePublished: publishedAt(p).UTC().Format(time.RFC3339),
+			DateModified:  p.UpdatedAt.UTC().Format(time.RFC3339),
+			Authors:       []jsonFeedAuthor{{Name: displayName(p.User), URL: f.authorURL(p.User)}},
+		}
+		for _, tag := range p.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 删除文章、文章移出最近的 N 篇时 ETag 和 Last-Modified 都会改变
+func TestFeedConditionalGet(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) { cfg.Feeds.Items = 2 })
+	author := createTestUser(t, "author", RoleUser)
+	createTestPost(t, author, "first", "content")
+	createTestPost(t, author, "second", "content")
+	// 最近一次修改在当前这一秒内时不发送 Last-Modified，先把时间往前调
+	hourAgo := time.Now().Add(-time.Hour)
+	DB.Model(&Post{}).Where("1 = 1").UpdateColumn("updated_at", hourAgo)
+	DB.Model(&User{}).Where("1 = 1").UpdateColumn("updated_at", hourAgo.Add(-time.Minute))
+
+	w := doJSON(r, http.MethodGet, "/feed.rss", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取订阅源返回 %d: %s", w.Code, w.Body.String())
+	}
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" {
+		t.Fatal("缺少 ETag")
+	}
+	if lastModified != hourAgo.UTC().Format(http.TimeFormat) {
+		t.Errorf("Last-Modified 应为最近一次修改文章的时间，得到 %q", lastModified)
+	}
+	if vary := w.Header().Get("Vary"); vary != "" {
+		t.Errorf("订阅源不随语言变化，不应发送 Vary: %q", vary)
+	}
+
+	if w := doJSON(r, http.MethodGet, "/feed.rss", "", nil, "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("ETag 匹配时返回 %d，期望 304", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, "/feed.rss", "", nil, "If-Modified-Since", lastModified); w.Code != http.StatusNotModified {
+		t.Errorf("没有修改过时 If-Modified-Since 返回 %d，期望 304", w.Code)
+	}
+	future := time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)
+	if w := doJSON(r, http.MethodGet, "/feed.rss", "", nil, "If-Modified-Since", future); w.Code != http.StatusOK {
+		t.Errorf("晚于当前时间的 If-Modified-Since 应被忽略，返回 %d", w.Code)
+	}
+	en := doJSON(r, http.MethodGet, "/feed.rss", "", nil, "Accept-Language", "en-US", "If-None-Match", etag)
+	if en.Code != http.StatusNotModified {
+		t.Errorf("订阅源的内容不随语言变化，不同语言应使用相同的 ETag: %d", en.Code)
+	}
+
+	// 新文章把 first 挤出订阅源；删除新文章后 first 重新出现，内容与最初相同
+	third := createTestPost(t, author, "third", "content")
+	w = doJSON(r, http.MethodGet, "/feed.rss", "", nil, "If-None-Match", etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("新增文章后返回 %d，期望 200", w.Code)
+	}
+	withThird := w.Header().Get("ETag")
+
+	if w := doJSON(r, http.MethodDelete, fmt.Sprintf("/api/v1/posts/%d", third.ID), testToken(t, author), nil); w.Code != http.StatusOK {
+		t.Fatalf("删除文章返回 %d: %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodGet, "/feed.rss", "", nil, "If-None-Match", withThird)
+	if w.Code != http.StatusOK {
+		t.Errorf("删除文章后返回 %d，期望 200", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("删除文章后订阅源与最初相同，ETag 应该恢复为 %s，得到 %s", etag, got)
+	}
+}
+
+// 已删除的文章和作者资料的修改也计入订阅源的修改时间
+func TestFeedLastModified(t *testing.T) {
+	setupTestApp(t)
+	alice := createTestUser(t, "alice", RoleUser)
+	bob := createTestUser(t, "bob", RoleUser)
+	post := createTestPost(t, alice, "alice", "content")
+	deleted := createTestPost(t, bob, "bob", "content")
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	DB.Model(&User{}).Where("1 = 1").UpdateColumn("updated_at", at(0))
+	DB.Model(&Post{}).Where("id = ?", post.ID).UpdateColumn("updated_at", at(1))
+	DB.Model(&Post{}).Where("id = ?", deleted.ID).UpdateColumns(map[string]interface{}{"updated_at": at(2), "deleted_at": at(3)})
+	DB.First(&post, post.ID)
+
+	check := func(name string, authorID uint, want time.Time) {
+		t.Helper()
+		got, err := feedLastModified(authorID, []Post{post})
+		if err != nil || !got.Equal(want) {
+			t.Errorf("%s: 期望 %v，得到 %v %v", name, want, got, err)
+		}
+	}
+	check("删除文章的时间", 0, at(3))
+	check("只看该作者的文章", alice.ID, at(1))
+
+	DB.Model(&User{}).Where("id = ?", alice.ID).UpdateColumn("updated_at", at(4))
+	check("作者修改资料", 0, at(4))
+	// 没有列出的作者修改资料不影响订阅源
+	DB.Model(&User{}).Where("id = ?", bob.ID).UpdateColumn("updated_at", at(5))
+	check("其他作者修改资料", alice.ID, at(4))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 代码块语法高亮：按语言做简单的词法切分，给关键字、字符串、注释和数字加上 <span class="hl-xxx">
+// 样式由前端的 CSS 决定；不认识的语言只做 HTML 转义
+
+// highlightLang 一种语言的词法规则
+type highlightLang struct {
+	keywords     map[string]bool
+	lineComments []string  // 单行注释的开始标记
+	blockComment [2]string // 块注释的开始和结束标记
+	quotes       string    // 字符串的引号
+	multiline    string    // 可以跨行的引号（例如 Go 和 JS 的反引号）
+}
+
+func keywordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	hlGo = &highlightLang{
+		keywords: keywordSet(`break case chan const continue default defer else fallthrough for func go goto if import
+			interface map package range return select struct switch type var true false nil iota`),
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`", multiline: "`",
+	}
+	hlJS = &highlightLang{
+		keywords: keywordSet(`async await break case catch class const continue debugger default delete do else export
+			extends finally for from function if import in instanceof let new of return static super switch this throw
+			try typeof var void while yield true false null undefined interface type enum implements readonly`),
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'`", multiline: "`",
+	}
+	hlPython = &highlightLang{
+		keywords: keywordSet(`and as assert async await break class continue def del elif else except finally for from
+			global if import in is lambda nonlocal not or pass raise return try while with yield True False None`),
+		lineComments: []string{"#"}, quotes: "\"'",
+	}
+	hlRust = &highlightLang{
+		keywords: keywordSet(`as async await break const continue crate dyn else enum extern fn for if impl in let loop
+			match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false`),
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"",
+	}
+	hlC = &highlightLang{
+		keywords: keywordSet(`auto break case catch char class const continue default delete do double else enum extern
+			final finally float for goto if implements import int long namespace new package private protected public
+			return short signed sizeof static struct switch template this throw throws try typedef union unsigned void
+			volatile while bool boolean true false null nullptr`),
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'",
+	}
+	hlSolidity = &highlightLang{
+		keywords: keywordSet(`pragma solidity contract interface library function modifier event emit struct enum
+			mapping address bool string bytes uint int uint256 int256 bytes32 public private internal external view pure
+			payable returns return if else for while do break continue new delete memory storage calldata constant
+			immutable override virtual require revert assert import is using true false`),
+		lineComments: []string{"//"}, blockComment: [2]string{"/*", "*/"}, quotes: "\"'",
+	}
+	hlShell = &highlightLang{
+		keywords: keywordSet(`if then else elif fi for while until do done case esac in function return export local
+			echo exit set unset readonly source`),
+		lineComments: []string{"#"}, quotes: "\"'",
+	}
+	hlSQL = &highlightLang{
+		keywords: keywordSet(`select from where and or not insert into values update set delete create table index drop
+			alter add column primary key foreign references join left right inner outer on as group by order having
+			limit offset distinct union all null is in like between exists case when then else end begin commit
+			rollback default unique SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE
+			INDEX DROP ALTER ADD COLUMN PRIMARY KEY FOREIGN REFERENCES JOIN LEFT RIGHT INNER OUTER ON AS GROUP BY
+			ORDER HAVING LIMIT OFFSET DISTINCT UNION ALL NULL IS IN LIKE BETWEEN EXISTS CASE WHEN THEN ELSE END BEGIN
+			COMMIT ROLLBACK DEFAULT UNIQUE`),
+		lineComments: []string{"--"}, blockComment: [2]string{"/*", "*/"}, quotes: "'\"",
+	}
+	hlJSON = &highlightLang{
+		keywords: keywordSet(`true false null`),
+		quotes:   "\"",
+	}
+)
+
+// highlightLangs 代码块语言名（含常用别名）到词法规则的映射
+var highlightLangs = map[string]*highlightLang{
+	"go": hlGo, "golang": hlGo,
+	"js": hlJS, "javascript": hlJS, "ts": hlJS, "typescript": hlJS, "jsx": hlJS, "tsx": hlJS,
+	"py": hlPython, "python": hlPython,
+	"rs": hlRust, "rust": hlRust,
+	"c": hlC, "h": hlC, "cpp": hlC, "c++": hlC, "java": hlC, "cs": hlC, "csharp": hlC,
+	"sol": hlSolidity, "solidity": hlSolidity,
+	"sh": hlShell, "bash": hlShell, "shell": hlShell, "zsh": hlShell,
+	"sql":  hlSQL,
+	"json": hlJSON,
+}
+
+// highlightCode 返回转义并加上高亮标记的代码
+func highlightCode(lang, code string) string {
+	rules := highlightLangs[lang]
+	if rules == nil {
+		return html.EscapeString(code)
+	}
+
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if prefix, ok := hasAnyPrefix(rest, rules.lineComments); ok {
+			end := strings.IndexByte(rest[len(prefix):], '\n')
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(prefix)
+			}
+			span("comment", rest[:end])
+			i += end
+			continue
+		}
+		if open := rules.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := strings.Index(rest[len(open):], rules.blockComment[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(open) + len(rules.blockComment[1])
+			}
+			span("comment", rest[:end])
+			i += end
+			continue
+		}
+
+		c := code[i]
+		switch {
+		case strings.IndexByte(rules.quotes, c) >= 0:
+			end := stringLiteralEnd(rest, rules == hlPython, strings.IndexByte(rules.multiline, c) >= 0)
+			span("string", rest[:end])
+			i += end
+
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && (isWordByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("number", rest[:end])
+			i += end
+
+		case isWordByte(c) || c >= utf8.RuneSelf:
+			end := 0
+			for end < len(rest) {
+				r, size := utf8.DecodeRuneInString(rest[end:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+			if end == 0 {
+				_, end = utf8.DecodeRuneInString(rest)
+				b.WriteString(html.EscapeString(rest[:end]))
+			} else if rules.keywords[rest[:end]] {
+				span("keyword", rest[:end])
+			} else {
+				b.WriteString(html.EscapeString(rest[:end]))
+			}
+			i += end
+
+		default:
+			b.WriteString(html.EscapeString(rest[:1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func hasAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// stringLiteralEnd 返回字符串字面量的结束位置（包含结束引号）
+// tripleQuotes 支持 Python 的三引号字符串；multiline 为 false 时字符串在行尾结束
+func stringLiteralEnd(s string, tripleQuotes, multiline bool) int {
+	quote := s[:1]
+	if tripleQuotes && len(s) >= 3 && s[:3] == strings.Repeat(quote, 3) {
+		if end := strings.Index(s[3:], s[:3]); end >= 0 {
+			return end + 6
+		}
+		return len(s)
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != "`" {
+				i++
+			}
+		case '\n':
+			if !multiline {
+				return i
+			}
+		case quote[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
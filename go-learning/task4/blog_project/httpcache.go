@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// HTTP 条件请求：客户端带上次响应的 ETag 或 Last-Modified 重新请求，内容没有变化时返回 304，不再传输响应体
+
+// checkNotModified 设置 ETag 和 Last-Modified 响应头，客户端缓存仍然有效时返回 304 并返回 true
+// 按 RFC 9110 的规定，请求带了 If-None-Match 时忽略 If-Modified-Since
+func checkNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	lastModified = setValidators(c, etag, lastModified)
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		notModified = etagMatches(inm, etag, true)
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		// 晚于服务器当前时间的日期无效，按没有带这个请求头处理
+		if t, err := http.ParseTime(ims); err == nil && !t.After(time.Now()) && !lastModified.After(t) {
+			notModified = true
+		}
+	}
+	if notModified {
+		c.AbortWithStatus(http.StatusNotModified)
+	}
+	return notModified
+}
+
+// setValidators 设置 ETag 和 Last-Modified 响应头，返回实际发送的 Last-Modified（按秒截断）
+// HTTP 日期只精确到秒：最近一次修改还在当前这一秒内时不发送 Last-Modified，
+// 否则同一秒内之后的修改无法通过 If-Modified-Since 发现
+func setValidators(c *gin.Context, etag string, lastModified time.Time) time.Time {
+	c.Header("ETag", etag)
+	lastModified = lastModified.Truncate(time.Second)
+	if lastModified.IsZero() || !lastModified.Before(time.Now().Truncate(time.Second)) {
+		c.Writer.Header().Del("Last-Modified")
+		return time.Time{}
+	}
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	return lastModified
+}
+
+// touchPost 把文章的 updated_at 更新为当前时间
+// 文章详情包含已审核的评论和附件，评论的增删改、审核以及附件的上传、删除都要在同一个事务中调用，
+// 让 updated_at 成为文章详情的修改时间；使用 UpdateColumn，不会重建全文索引
+func touchPost(tx *gorm.DB, postID uint) error {
+	return tx.Model(&Post{}).Where("id = ?", postID).UpdateColumn("updated_at", time.Now()).Error
+}
+
+// etagMatches 判断 If-None-Match / If-Match 请求头中的 ETag 列表是否包含 etag
+// weak 为 true 时使用弱比较（忽略 W/ 前缀），用于 If-None-Match；If-Match 必须使用强比较
+func etagMatches(header, etag string, weak bool) bool {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// postVersion 文章详情的版本水位
+// 评论和附件的变化都会更新文章的 UpdatedAt（见 touchPost）；文章详情中还有作者和评论者的资料，
+// 修改资料会更新 users.updated_at，所以再加上这些用户中最近一次修改的时间
+type postVersion struct {
+	PostID         uint
+	UpdatedAt      time.Time
+	UsersUpdatedAt time.Time
+}
+
+// loadPostVersion 查询文章详情的版本水位
+// 只需要一个简单的查询，不用预加载作者、评论等关联数据就能判断客户端的缓存是否有效
+func loadPostVersion(post *Post) (postVersion, error) {
+	v := postVersion{PostID: post.ID, UpdatedAt: post.UpdatedAt}
+
+	// 包含已注销的账号：文章详情中仍然显示为 deleted-<id>
+	var latest User
+	commenters := DB.Model(&Comment{}).Select("user_id").Where("post_id = ? AND status = ?", post.ID, CommentStatusApproved)
+	if err := DB.Unscoped().Model(&User{}).Select("updated_at").Where("id = ? OR id IN (?)", post.UserID, commenters).
+		Order("updated_at desc").Limit(1).Find(&latest).Error; err != nil {
+		return v, err
+	}
+	v.UsersUpdatedAt = latest.UpdatedAt
+	return v, nil
+}
+
+// lastModified 文章详情的最近一次修改时间
+func (v postVersion) lastModified() time.Time {
+	if v.UsersUpdatedAt.After(v.UpdatedAt) {
+		return v.UsersUpdatedAt
+	}
+	return v.UpdatedAt
+}
+
+// etag 返回指定语言的响应的强 ETag；提示消息按语言翻译，不同语言的响应体不同，ETag 也不同
+func (v postVersion) etag(locale string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%d:%d:%d", locale, v.PostID, v.UpdatedAt.UnixNano(), v.UsersUpdatedAt.UnixNano())
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// matchesAnyLocale 判断 If-Match 是否与任一语言的 ETag 匹配（强比较）
+// 客户端切换语言后用之前拿到的 ETag 更新，只要版本相同就不算冲突
+func (v postVersion) matchesAnyLocale(ifMatch string) bool {
+	for _, locale := range supportedLocales {
+		if etagMatches(ifMatch, v.etag(locale), false) {
+			return true
+		}
+	}
+	return false
+}
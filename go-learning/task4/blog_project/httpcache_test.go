@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEtagMatches(t *testing.T) {
+	cases := []struct {
+		header, etag string
+		weak, want   bool
+	}{
+		{`"a"`, `"a"`, false, true},
+		{`"b", "a"`, `"a"`, false, true},
+		{`*`, `"a"`, false, true},
+		{`W/"a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, false},
+		{`"b"`, `"a"`, true, false},
+	}
+	for _, tc := range cases {
+		if got := etagMatches(tc.header, tc.etag, tc.weak); got != tc.want {
+			t.Errorf("etagMatches(%q, %q, %v) = %v", tc.header, tc.etag, tc.weak, got)
+		}
+	}
+}
+
+// 评论、附件和作者资料的变化都会让 ETag 和 Last-Modified 失效
+func TestPostConditionalGet(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	moderator := createTestUser(t, "moderator", RoleModerator)
+	post := createTestPost(t, author, "title", "content")
+	comments := []Comment{
+		{Content: "first", UserID: author.ID, PostID: post.ID, Status: CommentStatusApproved},
+		{Content: "second", UserID: author.ID, PostID: post.ID, Status: CommentStatusApproved},
+		{Content: "third", UserID: author.ID, PostID: post.ID, Status: CommentStatusApproved},
+	}
+	if err := DB.Create(&comments).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 最近一次修改在当前这一秒内时不发送 Last-Modified，先把时间往前调
+	hourAgo := time.Now().Add(-time.Hour)
+	DB.Model(&Post{}).Where("id = ?", post.ID).UpdateColumn("updated_at", hourAgo)
+	DB.Model(&User{}).Where("1 = 1").UpdateColumn("updated_at", hourAgo)
+	path := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+
+	w := doJSON(r, http.MethodGet, path, "", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("获取文章返回 %d，ETag %q", w.Code, etag)
+	}
+	if lastModified != hourAgo.UTC().Format(http.TimeFormat) {
+		t.Fatalf("Last-Modified 应为文章的修改时间，得到 %q", lastModified)
+	}
+	if w := doJSON(r, http.MethodGet, path, "", nil, "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("ETag 匹配时返回 %d，期望 304", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, path, "", nil, "If-Modified-Since", lastModified); w.Code != http.StatusNotModified {
+		t.Errorf("没有修改过时 If-Modified-Since 返回 %d，期望 304", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, path, "", nil, "If-None-Match", `"stale"`, "If-Modified-Since", lastModified); w.Code != http.StatusOK {
+		t.Errorf("带了 If-None-Match 时应忽略 If-Modified-Since，返回 %d", w.Code)
+	}
+	future := time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat)
+	if w := doJSON(r, http.MethodGet, path, "", nil, "If-Modified-Since", future); w.Code != http.StatusOK {
+		t.Errorf("晚于当前时间的 If-Modified-Since 应被忽略，返回 %d", w.Code)
+	}
+
+	en := doJSON(r, http.MethodGet, path, "", nil, "Accept-Language", "en-US")
+	if en.Header().Get("ETag") == etag {
+		t.Error("中英文响应使用了相同的 ETag")
+	}
+	if w := doJSON(r, http.MethodGet, path, "", nil, "Accept-Language", "en-US", "If-None-Match", etag); w.Code != http.StatusOK {
+		t.Errorf("中文的 ETag 不应该匹配英文响应，返回 %d", w.Code)
+	}
+
+	authorToken, moderatorToken := testToken(t, author), testToken(t, moderator)
+	steps := []struct {
+		name                string
+		method, path, token string
+		body                interface{}
+	}{
+		{"删除评论", http.MethodDelete, fmt.Sprintf("/api/v1/comments/%d", comments[0].ID), authorToken, nil},
+		{"隐藏评论", http.MethodPut, fmt.Sprintf("/api/v1/comments/%d/moderation", comments[1].ID), moderatorToken,
+			map[string]string{"status": CommentStatusHidden}},
+		{"拒绝评论", http.MethodPut, fmt.Sprintf("/api/v1/comments/%d/moderation", comments[2].ID), moderatorToken,
+			map[string]string{"status": CommentStatusRejected}},
+		{"发表评论", http.MethodPost, path + "/comments", moderatorToken, map[string]string{"content": "new"}},
+		{"评论者修改昵称", http.MethodPut, "/api/v1/profile", moderatorToken, map[string]string{"display_name": "Mod"}},
+		{"作者修改昵称", http.MethodPut, "/api/v1/profile", authorToken, map[string]string{"display_name": "Author"}},
+	}
+	for _, step := range steps {
+		if w := doJSON(r, step.method, step.path, step.token, step.body); w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			t.Fatalf("%s返回 %d: %s", step.name, w.Code, w.Body.String())
+		}
+		if w := doJSON(r, http.MethodGet, path, "", nil, "If-None-Match", etag); w.Code != http.StatusOK {
+			t.Fatalf("%s后 If-None-Match 返回 %d，期望 200", step.name, w.Code)
+		} else {
+			etag = w.Header().Get("ETag")
+		}
+		if w := doJSON(r, http.MethodGet, path, "", nil, "If-Modified-Since", lastModified); w.Code != http.StatusOK {
+			t.Fatalf("%s后 If-Modified-Since 返回 %d，期望 200", step.name, w.Code)
+		}
+	}
+}
+
+// 上传和删除附件都会更新文章的修改时间
+func TestAttachmentTouchesPost(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+	post := createTestPost(t, author, "title", "content")
+
+	updatedAt := func() time.Time {
+		t.Helper()
+		var p Post
+		DB.First(&p, post.ID)
+		return p.UpdatedAt
+	}
+	rewind := func() time.Time {
+		t.Helper()
+		DB.Model(&Post{}).Where("id = ?", post.ID).UpdateColumn("updated_at", time.Now().Add(-time.Hour))
+		return updatedAt()
+	}
+
+	before := rewind()
+	w := uploadFile(r, fmt.Sprintf("/api/v1/posts/%d/attachments", post.ID), token, "a.png", "image/png", testPNG(t, 4, 4))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("上传附件失败: %d %s", w.Code, w.Body.String())
+	}
+	if !updatedAt().After(before) {
+		t.Error("上传附件后文章的修改时间应更新")
+	}
+
+	before = rewind()
+	id := uint(decodeJSON(t, w)["attachment"].(map[string]interface{})["ID"].(float64))
+	if w := doJSON(r, http.MethodDelete, fmt.Sprintf("/api/v1/attachments/%d", id), token, nil); w.Code != http.StatusOK {
+		t.Fatalf("删除附件失败: %d %s", w.Code, w.Body.String())
+	}
+	if !updatedAt().After(before) {
+		t.Error("删除附件后文章的修改时间应更新")
+	}
+}
+
+func TestSetValidators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	past := time.Date(2024, 5, 1, 8, 30, 15, 500, time.UTC)
+	if got := setValidators(c, `"a"`, past); !got.Equal(past.Truncate(time.Second)) ||
+		c.Writer.Header().Get("Last-Modified") != "Wed, 01 May 2024 08:30:15 GMT" {
+		t.Errorf("Last-Modified 应按秒截断: %v %q", got, c.Writer.Header().Get("Last-Modified"))
+	}
+	// 当前这一秒内的修改不发送 Last-Modified，之前设置的也要去掉
+	if got := setValidators(c, `"b"`, time.Now()); !got.IsZero() || c.Writer.Header().Get("Last-Modified") != "" {
+		t.Errorf("当前这一秒内的修改不应发送 Last-Modified: %v %q", got, c.Writer.Header().Get("Last-Modified"))
+	}
+	if c.Writer.Header().Get("ETag") != `"b"` {
+		t.Errorf("ETag 应总是设置: %q", c.Writer.Header().Get("ETag"))
+	}
+}
+
+// If-Match 接受任一语言的 ETag，更新后返回的 ETag 与之后同语言的 GET 一致
+func TestPostIfMatchLocale(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	post := createTestPost(t, author, "title", "content")
+	path := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+	token := testToken(t, author)
+
+	enETag := doJSON(r, http.MethodGet, path, "", nil, "Accept-Language", "en-US").Header().Get("ETag")
+	w := doJSON(r, http.MethodPut, path, token, map[string]string{"title": "new", "content": "content"}, "If-Match", enETag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("使用英文响应的 ETag 更新返回 %d: %s", w.Code, w.Body.String())
+	}
+	if got := doJSON(r, http.MethodGet, path, "", nil).Header().Get("ETag"); got != w.Header().Get("ETag") {
+		t.Errorf("更新返回的 ETag %s 与 GET 的 %s 不一致", w.Header().Get("ETag"), got)
+	}
+
+	w = doJSON(r, http.MethodPut, path, token, map[string]string{"title": "stale", "content": "content"}, "If-Match", enETag)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("过期的 If-Match 返回 %d，期望 412", w.Code)
+	}
+}
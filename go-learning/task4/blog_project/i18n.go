@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+// 支持的语言，第一个为默认语言
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEnUS = "en-US"
+)
+
+var supportedLocales = []string{LocaleZhCN, LocaleEnUS}
+
+// localeMatcher 按 Accept-Language 匹配支持的语言，例如 zh-TW、zh 会匹配到 zh-CN，en-GB 会匹配到 en-US
+var localeMatcher = language.NewMatcher([]language.Tag{
+	language.MustParse(LocaleZhCN),
+	language.MustParse(LocaleEnUS),
+})
+
+// validTranslators 各语言的校验错误翻译器，由 initValidator 初始化
+var validTranslators = map[string]ut.Translator{}
+
+// validLocale 判断是否为支持的语言
+func validLocale(locale string) bool {
+	for _, l := range supportedLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLocale 从 Accept-Language 头中选出最合适的语言，无法匹配时使用默认语言
+func matchLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return supportedLocales[0]
+	}
+	_, index, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return supportedLocales[0]
+	}
+	return supportedLocales[index]
+}
+
+// localeOf 返回当前请求使用的语言：用户设置的语言偏好优先，其次是 Accept-Language
+func localeOf(c *gin.Context) string {
+	if v, ok := c.Get("claims"); ok {
+		if claims, ok := v.(*Claims); ok && validLocale(claims.Locale) {
+			return claims.Locale
+		}
+	}
+	return matchLocale(c.GetHeader("Accept-Language"))
+}
+
+// translate 按消息 ID 取出对应语言的消息；缺少翻译时退回默认语言，仍然没有时返回消息 ID 本身
+func translate(locale, id string, args ...interface{}) string {
+	msg, ok := messages[locale][id]
+	if !ok {
+		if msg, ok = messages[supportedLocales[0]][id]; !ok {
+			return id
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// T 按当前请求的语言翻译消息
+func T(c *gin.Context, id string, args ...interface{}) string {
+	return translate(localeOf(c), id, args...)
+}
+
+// initValidator 让 gin 的校验错误使用 JSON 字段名，注册中英文翻译和自定义校验规则
+func initValidator() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	uni := ut.New(zh.New(), zh.New(), en.New())
+	zhT, _ := uni.GetTranslator("zh")
+	enT, _ := uni.GetTranslator("en")
+	if err := zhTranslations.RegisterDefaultTranslations(v, zhT); err != nil {
+		log.Printf("注册中文校验消息失败: %v", err)
+	}
+	if err := enTranslations.RegisterDefaultTranslations(v, enT); err != nil {
+		log.Printf("注册英文校验消息失败: %v", err)
+	}
+	validTranslators[LocaleZhCN] = zhT
+	validTranslators[LocaleEnUS] = enT
+
+	if err := registerCustomValidators(v); err != nil {
+		log.Fatalf("注册自定义校验规则失败: %v", err)
+	}
+}
+
+// translateFieldErrors 把校验失败的字段说明翻译为指定语言
+func translateFieldErrors(locale string, fields []FieldError) {
+	trans, ok := validTranslators[locale]
+	for i := range fields {
+		if fields[i].fe == nil {
+			continue
+		}
+		if ok {
+			fields[i].Message = fields[i].fe.Translate(trans)
+		} else {
+			fields[i].Message = fields[i].fe.Error()
+		}
+	}
+}
+
+// UpdateLocaleHandler 修改当前用户的语言偏好，locale 为空表示清除偏好、改为按 Accept-Language 协商
+// 访问 token 中带有语言偏好，所以新的设置在重新登录或刷新 token 后生效；本次响应已经使用新语言
+func UpdateLocaleHandler(c *gin.Context) {
+	var req struct {
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	if req.Locale != "" && !validLocale(req.Locale) {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidLocale, "user.invalid_locale").
+			WithDetails(gin.H{"supported": supportedLocales}))
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	if err := DB.Model(&User{}).Where("id = ?", userID).Update("locale", req.Locale).Error; err != nil {
+		abortWithError(c, internalError("user.locale_update_failed", err))
+		return
+	}
+
+	locale := req.Locale
+	if locale == "" {
+		locale = matchLocale(c.GetHeader("Accept-Language"))
+	}
+	c.JSON(http.StatusOK, gin.H{"message": translate(locale, "user.locale_updated"), "locale": req.Locale})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                             LocaleZhCN,
+		"en-US":                        LocaleEnUS,
+		"en-GB,en;q=0.9":               LocaleEnUS,
+		"zh-TW":                        LocaleZhCN,
+		"fr-FR":                        LocaleZhCN,
+		"fr-FR,en;q=0.5":               LocaleEnUS,
+		"zh;q=0.3,en-US;q=0.8":         LocaleEnUS,
+		"not a language header;;q=abc": LocaleZhCN,
+	}
+	for header, want := range tests {
+		if got := matchLocale(header); got != want {
+			t.Errorf("matchLocale(%q) = %s，期望 %s", header, got, want)
+		}
+	}
+}
+
+func TestTranslateFallback(t *testing.T) {
+	if got := translate(LocaleEnUS, "post.listed"); got != "Posts retrieved" {
+		t.Errorf("英文翻译不对: %q", got)
+	}
+	// 不支持的语言退回默认语言，未知的消息 ID 原样返回
+	if got := translate("fr-FR", "post.listed"); got != messages[LocaleZhCN]["post.listed"] {
+		t.Errorf("不支持的语言应退回默认语言: %q", got)
+	}
+	if got := translate(LocaleEnUS, "no.such.message"); got != "no.such.message" {
+		t.Errorf("未知的消息 ID 应原样返回: %q", got)
+	}
+}
+
+// fmtVerbs 匹配消息模板中的格式化占位符
+var fmtVerbs = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z%]`)
+
+func TestMessageCataloguesComplete(t *testing.T) {
+	base := messages[supportedLocales[0]]
+	for _, locale := range supportedLocales[1:] {
+		catalogue := messages[locale]
+		for id, msg := range base {
+			other, ok := catalogue[id]
+			if !ok {
+				t.Errorf("%s 缺少消息 %s", locale, id)
+				continue
+			}
+			// 参数按位置传入，两种语言的占位符必须一致
+			if a, b := fmtVerbs.FindAllString(msg, -1), fmtVerbs.FindAllString(other, -1); strings.Join(a, " ") != strings.Join(b, " ") {
+				t.Errorf("消息 %s 的占位符不一致: %v / %v", id, a, b)
+			}
+		}
+		for id := range catalogue {
+			if _, ok := base[id]; !ok {
+				t.Errorf("%s 中的消息 %s 在默认语言中不存在", locale, id)
+			}
+		}
+	}
+}
+
+// messageIDRef 匹配代码中以字符串字面量引用消息 ID 的常见写法
+var messageIDRef = regexp.MustCompile(`(?:\bT\(c, |newAPIError\([^,()]+, [^,()]+, |internalError\(|translate\([^,()]+, )"([a-z0-9_]+(?:\.[a-z0-9_]+)+)"`)
+
+func TestMessageIDsReferencedInCodeExist(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := map[string]bool{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range messageIDRef.FindAllStringSubmatch(string(src), -1) {
+			if _, ok := messages[supportedLocales[0]][m[1]]; !ok {
+				missing[file+": "+m[1]] = true
+			}
+		}
+	}
+	var list []string
+	for ref := range missing {
+		list = append(list, ref)
+	}
+	sort.Strings(list)
+	for _, ref := range list {
+		t.Errorf("消息目录中没有 %s", ref)
+	}
+}
+
+func TestLocalePreference(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+
+	w := doJSON(r, http.MethodPut, "/api/v1/profile/locale", token, map[string]string{"locale": "fr-FR"}, "Accept-Language", "en")
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidLocale {
+		t.Fatalf("不支持的语言应返回 400: %d %s", w.Code, w.Body.String())
+	}
+	// 本次响应已经使用新设置的语言
+	w = doJSON(r, http.MethodPut, "/api/v1/profile/locale", token, map[string]string{"locale": LocaleEnUS}, "Accept-Language", "zh-CN")
+	if w.Code != http.StatusOK || decodeJSON(t, w)["message"] != messages[LocaleEnUS]["user.locale_updated"] {
+		t.Fatalf("设置语言偏好失败: %d %s", w.Code, w.Body.String())
+	}
+
+	// 新签发的 token 带上语言偏好，优先于 Accept-Language
+	DB.First(&user, user.ID)
+	w = doJSON(r, http.MethodGet, "/api/v1/posts", testToken(t, user), nil, "Accept-Language", "zh-CN")
+	if msg := decodeJSON(t, w)["message"]; msg != "Posts retrieved" {
+		t.Errorf("用户的语言偏好应优先于 Accept-Language: %v", msg)
+	}
+	// 未登录时按 Accept-Language 协商
+	w = doJSON(r, http.MethodGet, "/api/v1/posts", "", nil, "Accept-Language", "en-GB")
+	if msg := decodeJSON(t, w)["message"]; msg != "Posts retrieved" {
+		t.Errorf("应按 Accept-Language 返回英文: %v", msg)
+	}
+
+	// 清除偏好后回到按 Accept-Language 协商
+	doJSON(r, http.MethodPut, "/api/v1/profile/locale", token, map[string]string{"locale": ""})
+	DB.First(&user, user.ID)
+	w = doJSON(r, http.MethodGet, "/api/v1/posts", testToken(t, user), nil, "Accept-Language", "zh-CN")
+	if msg := decodeJSON(t, w)["message"]; msg != messages[LocaleZhCN]["post.listed"] {
+		t.Errorf("清除偏好后应按 Accept-Language 返回中文: %v", msg)
+	}
+}
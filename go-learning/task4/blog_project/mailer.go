@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的邮件驱动
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+// Email 一封纯文本邮件
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件的接口，开发和测试环境使用 log 或 file 驱动，不需要网络
+type Mailer interface {
+	Send(msg Email) error
+}
+
+// mailer 当前使用的邮件驱动，由 initMailer 初始化
+var mailer Mailer = LogMailer{}
+
+// LogMailer 只把邮件内容写入日志
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Email) error {
+	log.Printf("邮件: To=%s Subject=%s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer 把每封邮件保存为 Dir 下的一个 .eml 文件
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(msg Email) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	suffix, err := randomToken(4)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000"), suffix)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage(m.From, msg), 0o644)
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件，服务器支持 STARTTLS 时自动启用
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(msg Email) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("无效的发件人地址: %w", err)
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, buildMessage(m.From, msg))
+}
+
+// buildMessage 生成 RFC 5322 格式的邮件，主题按 RFC 2047 编码以支持中文
+func buildMessage(from string, msg Email) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// newMailer 按配置创建邮件驱动
+func newMailer(cfg *Config) Mailer {
+	switch cfg.Mail.Driver {
+	case MailDriverFile:
+		return FileMailer{Dir: cfg.Mail.Dir, From: cfg.Mail.From}
+	case MailDriverSMTP:
+		return SMTPMailer{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Username,
+			Password: cfg.Mail.SMTP.Password,
+			From:     cfg.Mail.From,
+		}
+	default:
+		return LogMailer{}
+	}
+}
+
+// initMailer 初始化邮件驱动
+func initMailer() {
+	mailer = newMailer(currentConfig())
+	log.Printf("邮件驱动: %s", currentConfig().Mail.Driver)
+}
+
+// sendMailAsync 在后台发送邮件，失败时只记录日志，避免 SMTP 变慢拖住请求
+func sendMailAsync(msg Email) {
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("发送邮件到 %s 失败: %v", msg.To, err)
+		}
+	}()
+}
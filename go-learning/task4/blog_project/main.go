@@ -12,46 +12,57 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var (
 	DB *gorm.DB
-	// JWT 密钥。在生产环境中，这应该从环境变量或安全配置中读取。
-	jwtKey = []byte("your_secret_key") // 请务必替换为一个更安全的密钥
+	// JWT 密钥，启动时从配置中读取（auth.jwt_secret 或 BLOG_JWT_SECRET）
+	jwtKey []byte
 )
 
 // Claims 定义了 JWT 中存储的数据
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID        uint   `json:"user_id"`
+	Username      string `json:"username"`
+	Role          string `json:"role"`
+	Locale        string `json:"locale,omitempty"` // 用户的语言偏好
+	EmailVerified bool   `json:"email_verified"`   // 签发时邮箱是否已验证
+	MFA           bool   `json:"mfa,omitempty"`    // 签发时是否已开启两步验证（开启后登录必须通过两步验证）
+	TokenVersion  int    `json:"token_version"`    // 签发时用户的 token 版本，吊销全部会话后旧 token 失效
+
+	// 使用 API Key 认证时由服务端填写，不会出现在 JWT 中
+	APIKeyID uint     `json:"-"`
+	Scopes   []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
-// 初始化数据库连接
-func InitDatabase() {
+// connectDatabase 只建立数据库连接，不检查迁移，供 migrate 子命令使用
+func connectDatabase() {
 	var err error
-	// 为了简单起见，我们使用 SQLite。文件名可以自定义。
-	DB, err = gorm.Open(sqlite.Open("blog.db"), &gorm.Config{})
+	// 默认使用 SQLite，可以通过 database.driver 切换到 PostgreSQL 或 MySQL
+	DB, err = openDatabase()
 	if err != nil {
-		respondWithError(nil, http.StatusInternalServerError, "无法连接到数据库", err)
-		os.Exit(1)
+		log.Fatalf("无法连接到数据库: %v", err)
 	}
+}
 
-	// 自动迁移数据库表结构
-	// 这会根据你的模型创建或更新表
-	err = DB.AutoMigrate(&User{}, &Post{}, &Comment{})
-	if err != nil {
-		respondWithError(nil, http.StatusInternalServerError, "数据库迁移失败", err)
-		os.Exit(1)
+// 初始化数据库连接
+// 表结构由 migrate 子命令维护，存在未执行的迁移时拒绝启动
+func InitDatabase() {
+	connectDatabase()
+	if err := checkPendingMigrations(DB); err != nil {
+		log.Fatalf("数据库迁移检查失败: %v", err)
 	}
-	log.Println("数据库连接成功并已迁移。")
+	initSearchIndex(DB)
+	log.Println("数据库连接成功，表结构已是最新版本。")
+
+	bootstrapAdmin()
 }
 
 // HashPassword 使用 bcrypt 对密码进行哈希处理
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14) // 14 是 cost 参数
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), currentConfig().Auth.BcryptCost)
 	return string(bytes), err
 }
 
@@ -61,13 +72,23 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateJWT 生成一个新的 JWT
+// GenerateJWT 生成一个新的短期访问 JWT，每个 token 带有唯一的 jti 以便服务端吊销
 func GenerateJWT(user User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token 有效期 24 小时
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	expirationTime := time.Now().Add(currentConfig().Auth.AccessTokenTTL.Duration)
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		Locale:        user.Locale,
+		EmailVerified: accountVerified(user),
+		MFA:           user.TOTPEnabledAt != nil,
+		TokenVersion:  user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "your_blog_project", // 可选，签发者
@@ -82,277 +103,505 @@ func GenerateJWT(user User) (string, error) {
 	return tokenString, nil
 }
 
-// 统一错误响应函数
-func respondWithError(c *gin.Context, code int, message string, err error) {
-	if err != nil {
-		log.Printf("错误: %s, 详情: %v", message, err)
-	} else {
-		log.Printf("错误: %s", message)
-	}
-	c.JSON(code, gin.H{"error": message})
+// RegisterRequest 用户注册的请求体
+type RegisterRequest struct {
+	Username string `json:"username" binding:"required,username"`
+	Password string `json:"password" binding:"required,password"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Locale   string `json:"locale" binding:"omitempty,locale"` // 为空时按 Accept-Language 协商
+}
+
+// LoginRequest 用户登录的请求体；这里不校验用户名格式，以免规则调整后老用户无法登录
+type LoginRequest struct {
+	Username string `json:"username" binding:"required,max=100"`
+	Password string `json:"password" binding:"required,max=72"`
 }
 
 // RegisterHandler 处理用户注册请求
 func RegisterHandler(c *gin.Context) {
-	log.Printf("用户注册请求: 用户名=%s, 邮箱=%s", c.PostForm("username"), c.PostForm("email"))
-	var newUser User
-	if err := c.ShouldBindJSON(&newUser); err != nil {
-		respondWithError(c, http.StatusBadRequest, "无效的请求数据: "+err.Error(), err)
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
 		return
 	}
+	log.Printf("用户注册请求: 用户名=%s, 邮箱=%s", req.Username, req.Email)
+	newUser := User{Username: req.Username, Email: req.Email, Locale: req.Locale}
 
 	// 检查用户名是否已存在
 	var existingUser User
 	if err := DB.Where("username = ?", newUser.Username).First(&existingUser).Error; err == nil {
-		respondWithError(c, http.StatusConflict, "用户名已存在", nil)
+		abortWithError(c, newAPIError(http.StatusConflict, CodeUsernameTaken, "user.username_taken"))
 		return
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		respondWithError(c, http.StatusInternalServerError, "数据库查询错误: "+err.Error(), err)
+		abortWithError(c, internalError("db.query_failed", err))
 		return
 	}
 
 	// 检查邮箱是否已存在
 	if err := DB.Where("email = ?", newUser.Email).First(&existingUser).Error; err == nil {
-		respondWithError(c, http.StatusConflict, "邮箱已被注册", nil)
+		abortWithError(c, newAPIError(http.StatusConflict, CodeEmailTaken, "user.email_taken"))
 		return
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		respondWithError(c, http.StatusInternalServerError, "数据库查询错误: "+err.Error(), err)
+		abortWithError(c, internalError("db.query_failed", err))
 		return
 	}
 
-	hashedPassword, err := HashPassword(newUser.Password)
+	hashedPassword, err := HashPassword(req.Password)
 	if err != nil {
-		respondWithError(c, http.StatusInternalServerError, "密码加密失败: "+err.Error(), err)
+		abortWithError(c, internalError("user.password_hash_failed", err))
 		return
 	}
 	newUser.Password = hashedPassword
+	// 角色只能由管理员分配
+	newUser.Role = RoleUser
 
 	if result := DB.Create(&newUser); result.Error != nil {
-		respondWithError(c, http.StatusInternalServerError, "用户创建失败: "+result.Error.Error(), result.Error)
+		abortWithError(c, internalError("user.create_failed", result.Error))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "用户注册成功", "user_id": newUser.ID, "username": newUser.Username})
+	// 验证邮件发送失败时不影响注册，用户可以稍后重新发送
+	if err := sendVerificationEmail(c, newUser); err != nil {
+		log.Printf("发送验证邮件失败: 用户=%s, 错误=%v", newUser.Username, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": T(c, "user.registered"), "user_id": newUser.ID, "username": newUser.Username})
 }
 
 // LoginHandler 处理用户登录请求
 func LoginHandler(c *gin.Context) {
 	log.Printf("用户登录请求")
-	var loginDetails struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var loginDetails LoginRequest
 
 	if err := c.ShouldBindJSON(&loginDetails); err != nil {
-		respondWithError(c, http.StatusBadRequest, "无效的请求数据: "+err.Error(), err)
+		abortWithError(c, badRequest(err))
 		return
 	}
 
 	var user User
 	if err := DB.Where("username = ?", loginDetails.Username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			respondWithError(c, http.StatusUnauthorized, "用户名或密码错误", nil)
+			abortWithError(c, errInvalidCredentials)
 		} else {
-			respondWithError(c, http.StatusInternalServerError, "数据库查询错误: "+err.Error(), err)
+			abortWithError(c, internalError("db.query_failed", err))
 		}
 		return
 	}
 
-	if !CheckPasswordHash(loginDetails.Password, user.Password) {
-		respondWithError(c, http.StatusUnauthorized, "用户名或密码错误", nil)
+	// 锁定期间不再校验密码，也避免白白消耗一次 bcrypt 计算
+	// 返回与密码错误相同的响应，否则只有存在的用户名才会被锁定，可以用来探测用户名
+	if accountLocked(user, time.Now()) {
+		abortWithError(c, errInvalidCredentials)
 		return
 	}
 
-	tokenString, err := GenerateJWT(user)
-	if err != nil {
-		respondWithError(c, http.StatusInternalServerError, "生成 token 失败: "+err.Error(), err)
+	if !CheckPasswordHash(loginDetails.Password, user.Password) {
+		lockedUntil, err := recordLoginFailure(user)
+		if err != nil {
+			log.Printf("记录登录失败次数失败: %v", err)
+		}
+		if lockedUntil != nil {
+			log.Printf("用户 %s 连续输错密码，账号已锁定到 %s", user.Username, lockedUntil.Format(time.RFC3339))
+		}
+		abortWithError(c, errInvalidCredentials)
 		return
 	}
+	if err := resetLoginFailures(DB, user); err != nil {
+		log.Printf("清除登录失败次数失败: %v", err)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "登录成功", "token": tokenString})
+	// 开启了两步验证时先返回 mfa_token，由 /login/mfa 完成登录
+	if user.TOTPEnabledAt != nil {
+		respondMFAChallenge(c, user)
+		return
+	}
+	respondWithTokens(c, user)
 }
 
-// AuthMiddleware 是一个 Gin 中间件，用于验证 JWT
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "请求未包含授权 token"})
-			c.Abort()
-			return
+// authenticate 从 Authorization 头中解析并校验访问 token
+func authenticate(c *gin.Context) (*Claims, *APIError) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.token_missing")
+	}
+
+	// Token 通常以 "Bearer <token>" 的形式提供，脚本和 CI 使用 "ApiKey <key>"
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 {
+		return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.token_malformed")
+	}
+	switch strings.ToLower(parts[0]) {
+	case "bearer":
+	case "apikey":
+		return authenticateAPIKey(parts[1])
+	default:
+		return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.token_malformed")
+	}
+	tokenString := parts[1]
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// 确保 token 的签名算法是我们期望的
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("非预期的签名算法")
 		}
+		return jwtKey, nil
+	})
 
-		// Token 通常以 "Bearer <token>" 的形式提供
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "授权 token 格式错误"})
-			c.Abort()
-			return
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, newAPIError(http.StatusUnauthorized, CodeTokenExpired, "auth.token_expired")
 		}
-		tokenString := parts[1]
+		return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.token_invalid").Wrap(err)
+	}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// 确保 token 的签名算法是我们期望的
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("非预期的签名算法")
-			}
-			return jwtKey, nil
-		})
+	if !token.Valid {
+		return nil, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "auth.token_invalid")
+	}
 
-		if err != nil {
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token 已过期"})
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的 Token: " + err.Error()})
-			}
-			c.Abort()
-			return
+	// 检查 token 是否已被吊销（例如用户已登出）
+	revoked, err := isTokenRevoked(claims.ID)
+	if err != nil {
+		return nil, internalError("auth.token_check_failed", err)
+	}
+	if revoked {
+		return nil, newAPIError(http.StatusUnauthorized, CodeTokenRevoked, "auth.token_revoked")
+	}
+
+	// 修改、重置密码或注销账号后，其他设备上尚未过期的访问 token 也立即失效
+	var user User
+	if err := DB.Select("id", "token_version").First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, newAPIError(http.StatusUnauthorized, CodeTokenRevoked, "auth.token_revoked")
 		}
+		return nil, internalError("auth.token_check_failed", err)
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, newAPIError(http.StatusUnauthorized, CodeTokenRevoked, "auth.token_revoked")
+	}
+
+	return claims, nil
+}
 
-		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的 Token"})
-			c.Abort()
+// setAuthContext 将用户信息存储在 Gin 的 Context 中，以便后续处理函数使用
+func setAuthContext(c *gin.Context, claims *Claims) {
+	c.Set("userID", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("claims", claims)
+}
+
+// AuthMiddleware 是一个 Gin 中间件，用于验证 JWT
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, apiErr := authenticate(c)
+		if apiErr != nil {
+			abortWithError(c, apiErr)
 			return
 		}
 
-		// 将用户信息存储在 Gin 的 Context 中，以便后续处理函数使用
-		c.Set("userID", claims.UserID)
-		c.Set("username", claims.Username)
-
+		setAuthContext(c, claims)
 		c.Next() // 继续处理请求
 	}
 }
 
+// OptionalAuthMiddleware 用于公开接口：携带有效 token 时识别用户身份，否则按匿名用户处理
+// 没有 read 权限范围的 API Key 也按匿名用户处理
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			if claims, apiErr := authenticate(c); apiErr == nil && hasScope(claims, ScopeRead) {
+				setAuthContext(c, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// PostCreateRequest 用于创建文章的请求体
+type PostCreateRequest struct {
+	Title         string     `json:"title" binding:"required,max=255"`
+	Content       string     `json:"content" binding:"required,maxcontent=post"`
+	ContentFormat string     `json:"content_format" binding:"omitempty,oneof=plain markdown html"` // 内容格式，默认为 markdown
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at"`
+	Tags          []string   `json:"tags" binding:"max=20,dive,required,max=50"`       // 标签名，不存在的标签会自动创建
+	Categories    []string   `json:"categories" binding:"max=10,dive,required,max=50"` // 分类名，不存在的分类会自动创建
+
+	CommentsNeedApproval bool `json:"comments_need_approval"` // 新评论是否需要审核
+}
+
 // CreatePostHandler 处理创建文章的请求
 func CreatePostHandler(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	log.Printf("创建文章请求: 用户ID=%v", userID)
-	userID, _ := c.Get("userID")
 
-	var newPost Post
-	if err := c.ShouldBindJSON(&newPost); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+	var req PostCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
 		return
 	}
 
 	// 设置文章作者为当前登录用户
-	newPost.UserID = userID.(uint)
+	if req.ContentFormat == "" {
+		req.ContentFormat = ContentFormatMarkdown
+	}
+	newPost := Post{
+		Title:                req.Title,
+		Content:              req.Content,
+		ContentFormat:        req.ContentFormat,
+		UserID:               userID.(uint),
+		CommentsNeedApproval: req.CommentsNeedApproval,
+	}
 
-	// 保存文章到数据库
-	if result := DB.Create(&newPost); result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "文章创建失败: " + result.Error.Error()})
+	// 校验文章状态，未指定时直接发布
+	if err := applyPostStatus(&newPost, req.Status, req.PublishAt); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// 保存文章到数据库，同时写入标签、分类和第一个修订版本
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newPost).Error; err != nil {
+			return err
+		}
+		if err := setPostTaxonomy(tx, &newPost, &req.Tags, &req.Categories); err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, &newPost, newPost.UserID)
+		return err
+	})
+	if err != nil {
+		abortWithError(c, internalError("post.create_failed", err))
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "文章创建成功",
-		"post_id": newPost.ID,
-		"title":   newPost.Title,
+		"message":        T(c, "post.created"),
+		"post_id":        newPost.ID,
+		"title":          newPost.Title,
+		"content_format": newPost.ContentFormat,
+		"status":         newPost.Status,
+		"tags":           newPost.Tags,
+		"categories":     newPost.Categories,
 	})
 }
 
+// postListQuery 文章列表的查询：当前用户可见的文章，按标签、分类筛选
+// 文章列表和订阅源共用，保证两边看到的文章一致
+func postListQuery(c *gin.Context) *gorm.DB {
+	return DB.Model(&Post{}).Scopes(visiblePosts(c), filterByTaxonomy(c)).Preload("Tags").Preload("Categories")
+}
+
 // GetAllPostsHandler 获取所有文章列表
+// 使用游标分页：GET /posts?limit=10&cursor=...&with_total=true
 func GetAllPostsHandler(c *gin.Context) {
 	log.Printf("获取所有文章列表")
-	var posts []Post
-
-	// 可以添加分页功能
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("page_size", "10")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 {
-		pageSize = 10 // 默认页面大小
+	pageReq, err := parsePageRequest(c, currentConfig().Pagination.DefaultPageSize)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
 
-	offset := (page - 1) * pageSize
-
 	// 查询文章列表，按创建时间倒序排列
-	if err := DB.Order("created_at desc").Limit(pageSize).Offset(offset).Find(&posts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文章列表失败: " + err.Error()})
+	posts, pageInfo, err := paginate(postListQuery(c), pageReq, "posts", true, postCursor)
+	if err != nil {
+		abortWithError(c, internalError("post.list_failed", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "获取文章列表成功",
-		"posts":   posts,
+		"message":    T(c, "post.listed"),
+		"posts":      posts,
+		"pagination": pageInfo,
 	})
 }
 
+// parseID 解析路径参数中的 ID，只接受正整数
+// 路径参数不能原样传给 First/Find：GORM 会把不是数字的字符串当作 SQL 条件拼接到查询中
+func parseID(s string) (uint, bool) {
+	id, err := strconv.ParseUint(s, 10, strconv.IntSize)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
 // GetPostByIDHandler 获取单个文章详情
 func GetPostByIDHandler(c *gin.Context) {
 	log.Printf("获取文章详情: 文章ID=%s", c.Param("id"))
-	postID := c.Param("id")
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidPostID)
+		return
+	}
 
 	var post Post
-	// 预加载文章作者和评论信息
-	if err := DB.Preload("User").Preload("Comments").Preload("Comments.User").First(&post, postID).Error; err != nil {
+	if err := DB.Scopes(visiblePosts(c)).First(&post, postID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			abortWithError(c, errPostNotFound)
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文章详情失败: " + err.Error()})
+			abortWithError(c, internalError("post.detail_failed", err))
 		}
 		return
 	}
 
+	// 客户端缓存的版本仍然有效时直接返回 304，不再预加载关联数据
+	// 草稿只有作者本人能看到，响应不能被共享缓存；提示消息按请求语言翻译
+	version, err := loadPostVersion(&post)
+	if err != nil {
+		abortWithError(c, internalError("post.detail_failed", err))
+		return
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Vary", "Authorization, Accept-Language")
+	if checkNotModified(c, version.etag(localeOf(c)), version.lastModified()) {
+		return
+	}
+
+	// 预加载文章作者和评论信息
+	updatedAt := post.UpdatedAt
+	if err := DB.Preload("User", withDeletedUsers).Preload("Comments", "status = ?", CommentStatusApproved).Preload("Comments.User", withDeletedUsers).Preload("Tags").Preload("Categories").Preload("Attachments").First(&post, post.ID).Error; err != nil {
+		abortWithError(c, internalError("post.detail_failed", err))
+		return
+	}
+	// 两次查询之间文章被修改时，按新的内容重新计算 ETag
+	if !post.UpdatedAt.Equal(updatedAt) {
+		if version, err = loadPostVersion(&post); err != nil {
+			abortWithError(c, internalError("post.detail_failed", err))
+			return
+		}
+		setValidators(c, version.etag(localeOf(c)), version.lastModified())
+	}
+
+	// 按内容格式渲染为过滤后的 HTML，同时生成目录
+	rendered := renderPostContent(post.ContentFormat, post.Content)
+	post.ContentHTML, post.TOC = rendered.HTML, rendered.TOC
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "获取文章详情成功",
+		"message": T(c, "post.fetched"),
 		"post":    post,
 	})
 }
 
+// PostUpdateRequest 用于更新文章的请求体，标题和正文整体替换
+type PostUpdateRequest struct {
+	Title         string     `json:"title" binding:"required,max=255"`
+	Content       string     `json:"content" binding:"required,maxcontent=post"`
+	ContentFormat string     `json:"content_format" binding:"omitempty,oneof=plain markdown html"` // 为空表示不修改内容格式
+	Status        string     `json:"status"`
+	PublishAt     *time.Time `json:"publish_at"`
+	Tags          *[]string  `json:"tags" binding:"omitempty,max=20,dive,required,max=50"`       // 为空表示不修改标签
+	Categories    *[]string  `json:"categories" binding:"omitempty,max=10,dive,required,max=50"` // 为空表示不修改分类
+
+	CommentsNeedApproval *bool `json:"comments_need_approval"` // 为空表示不修改
+}
+
 // UpdatePostHandler 更新文章
 func UpdatePostHandler(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	log.Printf("更新文章请求: 文章ID=%s, 用户ID=%v", c.Param("id"), userID)
-	postID := c.Param("id")
-	userID, _ := c.Get("userID")
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidPostID)
+		return
+	}
 
-	// 检查文章是否存在，以及当前用户是否为文章作者
+	// 检查文章是否存在（作者或管理员权限已由路由策略校验）
 	var post Post
-	if err := DB.First(&post, postID).Error; err != nil {
+	if err := DB.Preload("Tags").Preload("Categories").First(&post, postID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			abortWithError(c, errPostNotFound)
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文章失败: " + err.Error()})
+			abortWithError(c, internalError("post.get_failed", err))
 		}
 		return
 	}
 
-	// 检查当前用户是否为文章作者
-	if post.UserID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "您没有权限更新此文章"})
-		return
+	// 带 If-Match 时只有客户端看到的还是最新版本才允许更新，避免覆盖别人的修改（乐观并发控制）
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch != "" {
+		version, err := loadPostVersion(&post)
+		if err != nil {
+			abortWithError(c, internalError("post.get_failed", err))
+			return
+		}
+		if !version.matchesAnyLocale(ifMatch) {
+			c.Header("ETag", version.etag(localeOf(c)))
+			abortWithError(c, errPostVersionConflict)
+			return
+		}
 	}
 
 	// 绑定更新数据
-	var updateData struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
-	}
-
+	var updateData PostUpdateRequest
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		abortWithError(c, badRequest(err))
 		return
 	}
 
 	// 更新文章
+	original := post
 	post.Title = updateData.Title
 	post.Content = updateData.Content
+	if updateData.ContentFormat != "" {
+		post.ContentFormat = updateData.ContentFormat
+	}
+	if updateData.CommentsNeedApproval != nil {
+		post.CommentsNeedApproval = *updateData.CommentsNeedApproval
+	}
+	if updateData.Status != "" || updateData.PublishAt != nil {
+		status := updateData.Status
+		if status == "" {
+			status = post.Status
+		}
+		if err := applyPostStatus(&post, status, updateData.PublishAt); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
 
-	if err := DB.Save(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新文章失败: " + err.Error()})
+	// 保存文章并记录修订版本
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseRevision(tx, &original); err != nil {
+			return err
+		}
+		// 校验 If-Match 之后文章可能又被别人修改，保存时再按 UpdatedAt 比较一次
+		if ifMatch != "" {
+			result := tx.Model(&Post{}).Where("id = ? AND updated_at = ?", original.ID, original.UpdatedAt).
+				UpdateColumn("updated_at", time.Now())
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errPostVersionConflict
+			}
+		}
+		if err := tx.Omit("Tags", "Categories").Save(&post).Error; err != nil {
+			return err
+		}
+		if err := setPostTaxonomy(tx, &post, updateData.Tags, updateData.Categories); err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, &post, userID.(uint))
+		return err
+	})
+	if errors.Is(err, errPostVersionConflict) {
+		abortWithError(c, errPostVersionConflict)
+		return
+	}
+	if err != nil {
+		abortWithError(c, internalError("post.update_failed", err))
 		return
 	}
 
+	// 返回新的 ETag，客户端下次更新时可以直接用作 If-Match
+	if version, err := loadPostVersion(&post); err == nil {
+		c.Header("ETag", version.etag(localeOf(c)))
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "文章更新成功",
+		"message": T(c, "post.updated"),
 		"post":    post,
 	})
 }
@@ -361,40 +610,38 @@ func UpdatePostHandler(c *gin.Context) {
 func DeletePostHandler(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	log.Printf("删除文章请求: 文章ID=%s, 用户ID=%v", c.Param("id"), userID)
-	postID := c.Param("id")
-	userID, _ := c.Get("userID")
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidPostID)
+		return
+	}
 
-	// 检查文章是否存在，以及当前用户是否为文章作者
+	// 检查文章是否存在（作者或管理员权限已由路由策略校验）
 	var post Post
 	if err := DB.First(&post, postID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+			abortWithError(c, errPostNotFound)
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取文章失败: " + err.Error()})
+			abortWithError(c, internalError("post.get_failed", err))
 		}
 		return
 	}
 
-	// 检查当前用户是否为文章作者
-	if post.UserID != userID.(uint) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "您没有权限删除此文章"})
-		return
-	}
-
 	// 删除文章（GORM 的软删除，实际上是设置 deleted_at 字段）
 	if err := DB.Delete(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除文章失败: " + err.Error()})
+		abortWithError(c, internalError("post.delete_failed", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "文章删除成功",
+		"message": T(c, "post.deleted"),
 	})
 }
 
 // CommentCreateRequest 用于创建评论的请求体
 type CommentCreateRequest struct {
-	Content string `json:"content" binding:"required"`
+	Content  string `json:"content" binding:"required,maxcontent=comment"`
+	ParentID *uint  `json:"parent_id"` // 回复某条评论时填写
 }
 
 // CommentResponse 用于返回评论信息
@@ -413,27 +660,31 @@ func CreateCommentHandler(c *gin.Context) {
 	postIDStr := c.Param("id")
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文章ID"})
+		abortWithError(c, errInvalidPostID)
 		return
 	}
 
 	var req CommentCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		abortWithError(c, badRequest(err))
 		return
 	}
 
 	userIDVal, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未认证"})
+		abortWithError(c, errAuthRequired)
 		return
 	}
 	userID := userIDVal.(uint)
 
-	// 检查文章是否存在
+	// 检查文章是否存在，且只有已发布的文章可以评论
 	var post Post
-	if err := DB.First(&post, postID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
+	if err := DB.Where("status = ?", PostStatusPublished).First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errPostNotFound)
+		} else {
+			abortWithError(c, internalError("post.get_failed", err))
+		}
 		return
 	}
 
@@ -442,9 +693,32 @@ func CreateCommentHandler(c *gin.Context) {
 		Content: req.Content,
 		PostID:  uint(postID),
 		UserID:  userID,
+		Status:  initialCommentStatus(&post),
 	}
-	if err := DB.Create(&comment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "评论创建失败: " + err.Error()})
+
+	// 回复评论：父评论必须属于同一篇文章，且不能超过最大嵌套深度
+	if req.ParentID != nil {
+		var parent Comment
+		if err := DB.Scopes(visibleComments).Where("post_id = ?", postID).First(&parent, *req.ParentID).Error; err != nil {
+			abortWithError(c, newAPIError(http.StatusNotFound, CodeCommentNotFound, "comment.parent_not_found"))
+			return
+		}
+		if maxDepth := currentConfig().Comments.MaxDepth; parent.Depth+1 > maxDepth {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeCommentDepthExceeded, "comment.depth_exceeded", maxDepth).
+				WithDetails(gin.H{"max_depth": maxDepth}))
+			return
+		}
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return touchPost(tx, comment.PostID)
+	})
+	if err != nil {
+		abortWithError(c, internalError("comment.create_failed", err))
 		return
 	}
 
@@ -452,106 +726,266 @@ func CreateCommentHandler(c *gin.Context) {
 	var user User
 	DB.First(&user, userID)
 
+	message := "comment.created"
+	if comment.Status == CommentStatusPending {
+		message = "comment.pending"
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "评论创建成功",
-		"comment": CommentResponse{
-			ID:        comment.ID,
-			Content:   comment.Content,
-			UserID:    comment.UserID,
-			Username:  user.Username,
-			CreatedAt: comment.CreatedAt,
+		"message": T(c, message),
+		"status":  comment.Status,
+		"comment": CommentNode{
+			CommentResponse: CommentResponse{
+				ID:        comment.ID,
+				Content:   comment.Content,
+				UserID:    comment.UserID,
+				Username:  user.Username,
+				CreatedAt: comment.CreatedAt,
+			},
+			ParentID: comment.ParentID,
+			Depth:    comment.Depth,
+			Replies:  []*CommentNode{},
 		},
 	})
 }
 
-// 获取某篇文章的所有评论（公开接口）
+// 获取某篇文章的评论树（公开接口）
 func GetCommentsByPostHandler(c *gin.Context) {
 	log.Printf("获取评论列表: 文章ID=%s", c.Param("id"))
 	postIDStr := c.Param("id")
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文章ID"})
+		abortWithError(c, errInvalidPostID)
 		return
 	}
 
-	var comments []Comment
-	if err := DB.Where("post_id = ?", postID).Order("created_at asc").Find(&comments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败: " + err.Error()})
+	// 未发布的文章对其他人不可见，它的评论同样不可见
+	var post Post
+	if err := DB.Scopes(visiblePosts(c)).Select("id").First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errPostNotFound)
+		} else {
+			abortWithError(c, internalError("post.get_failed", err))
+		}
 		return
 	}
 
-	// 获取所有评论的用户信息
-	userIDs := make([]uint, 0, len(comments))
-	for _, cmt := range comments {
-		userIDs = append(userIDs, cmt.UserID)
-	}
-	var users []User
-	DB.Where("id IN ?", userIDs).Find(&users)
-	userMap := make(map[uint]string)
-	for _, u := range users {
-		userMap[u.ID] = u.Username
+	// 顶层评论分页，每条评论下展开若干层回复
+	pageReq, err := parsePageRequest(c, currentConfig().Pagination.DefaultPageSize)
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
-
-	resp := make([]CommentResponse, 0, len(comments))
-	for _, cmt := range comments {
-		resp = append(resp, CommentResponse{
-			ID:        cmt.ID,
-			Content:   cmt.Content,
-			UserID:    cmt.UserID,
-			Username:  userMap[cmt.UserID],
-			CreatedAt: cmt.CreatedAt,
-		})
+	query := DB.Unscoped().Model(&Comment{}).Scopes(threadComments(post.ID)).Where("post_id = ? AND parent_id IS NULL", postID)
+	comments, pageInfo, err := buildCommentTree(query, post.ID, pageReq, parseTreeDepth(c))
+	if err != nil {
+		abortWithError(c, internalError("comment.get_failed", err))
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"comments": resp})
+	c.JSON(http.StatusOK, gin.H{
+		"comments":   comments,
+		"pagination": pageInfo,
+	})
 }
 
 func main() {
+	// 加载配置
+	configFile := initConfig()
+
+	// 处理命令行子命令
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// 初始化数据库
 	InitDatabase()
+	// 初始化邮件驱动
+	initMailer()
+	initRateLimiter()
+	initStorage()
+	initRenderCache()
+
+	// 定期清理过期的刷新 token 与吊销记录
+	go runTokenJanitor(time.Hour)
+	// 定时发布到期的文章
+	go runPostPublisher(currentConfig().Posts.PublishInterval.Duration)
+	// 收到 SIGHUP 时重新加载可热更新的配置
+	go watchConfigReload(configFile)
+
+	// 校验错误使用 JSON 字段名，并按请求语言翻译
+	initValidator()
 
 	// 创建 Gin 引擎
-	r := gin.Default()
+	if currentConfig().Env == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := setupRouter()
+
+	// 启动 HTTP 服务器
+	addr := currentConfig().Server.Addr
+	log.Printf("服务器正在启动，监听地址 %s...", addr)
+	if err := r.Run(addr); err != nil {
+		log.Fatalf("无法启动服务器: %v", err)
+	}
+}
+
+// setupRouter 创建 Gin 引擎并注册中间件和全部路由
+func setupRouter() *gin.Engine {
+	r := gin.New()
+	// 只采信可信代理转发的 X-Forwarded-For，否则客户端可以伪造 IP 绕过按 IP 的限流
+	if err := r.SetTrustedProxies(currentConfig().Server.TrustedProxies); err != nil {
+		log.Fatalf("server.trusted_proxies 配置错误: %v", err)
+	}
+	r.Use(gin.Logger(), gin.CustomRecovery(recoverWithAPIError))
+	// 请求 ID 与统一错误响应，处理函数通过 abortWithError 返回错误
+	r.Use(RequestIDMiddleware(), ErrorMiddleware())
+	r.NoRoute(NoRouteHandler)
 
 	// 公共路由组 (不需要认证)
 	public := r.Group("/api/v1")
+	public.Use(OptionalAuthMiddleware(), RateLimit(RateLimitPublic, rateLimitByIP)) // 识别已登录的作者，以便查看自己未发布的文章
 	{
-		public.POST("/register", RegisterHandler)
-		public.POST("/login", LoginHandler)
+		// 注册、登录等接口额外按 IP 限流，防止暴力破解
+		authLimit := RateLimit(RateLimitAuth, rateLimitByIP)
+		public.POST("/register", RateLimit(RateLimitRegister, rateLimitByIP), RegisterHandler)
+		public.POST("/login", RateLimit(RateLimitLogin, rateLimitByIP), LoginHandler)
+		public.POST("/login/mfa", authLimit, LoginMFAHandler)
+		public.POST("/token/refresh", authLimit, RefreshTokenHandler)
+		// 邮箱验证与重置密码
+		public.GET("/verify-email", authLimit, VerifyEmailHandler)
+		public.POST("/verify-email", authLimit, VerifyEmailHandler)
+		public.POST("/password/forgot", authLimit, ForgotPasswordHandler)
+		public.POST("/password/reset", authLimit, ResetPasswordHandler)
+		// Sign-In with Ethereum
+		public.GET("/siwe/nonce", authLimit, SIWENonceHandler)
+		public.POST("/siwe/verify", authLimit, SIWELoginHandler)
 
 		// 公开的文章查询接口
 		public.GET("/posts", GetAllPostsHandler)
 		public.GET("/posts/:id", GetPostByIDHandler)
+		// 全文搜索
+		public.GET("/search", SearchHandler)
+		// 标签和分类
+		public.GET("/tags", GetTagsHandler)
+		public.GET("/categories", GetCategoriesHandler)
 		// 新增：获取某篇文章的所有评论
 		public.GET("/posts/:id/comments", GetCommentsByPostHandler)
+		// 作者主页
+		public.GET("/users/:username", GetUserPageHandler)
+		// 分页获取某条评论的回复
+		public.GET("/comments/:id/replies", GetCommentRepliesHandler)
+		// 文章附件
+		public.GET("/posts/:id/attachments", GetPostAttachmentsHandler)
+		public.GET("/attachments/:id", DownloadAttachmentHandler)
+		public.GET("/attachments/:id/thumbnail", AttachmentThumbnailHandler)
+	}
+
+	// 订阅源 (不需要认证，只包含已发布的文章)：全站、按作者、按标签
+	feeds := r.Group("", RateLimit(RateLimitPublic, rateLimitByIP))
+	for _, format := range []string{FeedFormatRSS, FeedFormatAtom, FeedFormatJSON} {
+		feeds.GET("/feed."+format, FeedHandler(format))
+		feeds.GET("/users/:username/feed."+format, FeedHandler(format))
+		feeds.GET("/tags/:tag/feed."+format, FeedHandler(format))
+	}
+
+	// 账号路由组 (需要认证，未验证邮箱的账号也可以使用)
+	account := r.Group("/api/v1")
+	account.Use(AuthMiddleware(), RateLimit(RateLimitUser, rateLimitByUser))
+	{
+		// 个人资料路由
+		account.GET("/profile", RequireScope(ScopeRead), GetProfileHandler)
 	}
 
-	// 受保护的路由组 (需要认证)
+	// 账号管理只能使用交互式登录的访问 token，API Key 不能使用
+	session := account.Group("", RequireSession())
+	{
+		session.POST("/logout", LogoutHandler)
+		session.POST("/verify-email/resend", ResendVerificationHandler)
+
+		// 个人资料、修改密码、注销账号
+		session.PUT("/profile", UpdateProfileHandler)
+		session.PUT("/profile/password", ChangePasswordHandler)
+		session.DELETE("/profile", DeleteAccountHandler)
+		session.PUT("/profile/locale", UpdateLocaleHandler)
+		session.POST("/profile/wallet", LinkWalletHandler)
+		session.DELETE("/profile/wallet", UnlinkWalletHandler)
+
+		// 两步验证
+		session.POST("/mfa/totp/setup", SetupTOTPHandler)
+		session.POST("/mfa/totp/enable", EnableTOTPHandler)
+		session.POST("/mfa/totp/disable", DisableTOTPHandler)
+		session.POST("/mfa/recovery-codes", RegenerateRecoveryCodesHandler)
+
+		// API Key
+		session.GET("/api-keys", ListAPIKeysHandler)
+		session.POST("/api-keys", CreateAPIKeyHandler)
+		session.DELETE("/api-keys/:id", RevokeAPIKeyHandler)
+	}
+
+	// 受保护的路由组 (需要认证，未验证邮箱的账号只能执行只读请求，角色要求两步验证时必须先开启)
+	// 使用 API Key 访问时还要求 key 拥有对应的权限范围
 	protected := r.Group("/api/v1")
-	protected.Use(AuthMiddleware()) // 应用认证中间件
+	protected.Use(AuthMiddleware(), RateLimit(RateLimitUser, rateLimitByUser), RequireVerifiedEmail(), RequireMFAEnrollment()) // 应用认证中间件
 	{
-		// 个人资料路由
-		protected.GET("/profile", func(c *gin.Context) {
-			userID, _ := c.Get("userID")
-			username, _ := c.Get("username")
-			c.JSON(http.StatusOK, gin.H{
-				"message":  "这是受保护的个人资料区域",
-				"user_id":  userID,
-				"username": username,
-			})
-		})
+		postsWrite := RequireScope(ScopePostsWrite)
+		commentsWrite := RequireScope(ScopeCommentsWrite)
 
 		// 文章管理接口
-		protected.POST("/posts", CreatePostHandler)
-		protected.PUT("/posts/:id", UpdatePostHandler)
-		protected.DELETE("/posts/:id", DeletePostHandler)
+		protected.POST("/posts", postsWrite, CreatePostHandler)
+		// 作者本人或拥有相应权限的角色（管理员）可以编辑、删除文章
+		protected.PUT("/posts/:id", postsWrite, Authorize(Policy{
+			Permission: PermPostUpdateAny,
+			Owner:      postOwner,
+			Message:    "post.update_forbidden",
+		}), UpdatePostHandler)
+		protected.DELETE("/posts/:id", postsWrite, Authorize(Policy{
+			Permission: PermPostDeleteAny,
+			Owner:      postOwner,
+			Message:    "post.delete_forbidden",
+		}), DeletePostHandler)
+		// 修订历史：作者本人或管理员可以查看、比较和恢复
+		revisionPolicy := Authorize(Policy{
+			Permission: PermPostUpdateAny,
+			Owner:      postOwner,
+			Message:    "revision.view_forbidden",
+		})
+		protected.GET("/posts/:id/revisions", RequireScope(ScopeRead), revisionPolicy, ListRevisionsHandler)
+		protected.GET("/posts/:id/revisions/diff", RequireScope(ScopeRead), revisionPolicy, DiffRevisionsHandler)
+		protected.POST("/posts/:id/revisions/:version/restore", postsWrite, revisionPolicy, RestoreRevisionHandler)
+		// 附件：文章作者或能编辑任意文章的角色可以上传、删除
+		protected.POST("/posts/:id/attachments", postsWrite, Authorize(Policy{
+			Permission: PermPostUpdateAny,
+			Owner:      postOwner,
+			Message:    "post.update_forbidden",
+		}), UploadAttachmentHandler)
+		protected.DELETE("/attachments/:id", postsWrite, Authorize(Policy{
+			Permission: PermPostUpdateAny,
+			Owner:      attachmentOwner,
+			Message:    "attachment.delete_forbidden",
+		}), DeleteAttachmentHandler)
 		// 新增：创建评论
-		protected.POST("/posts/:id/comments", CreateCommentHandler)
-	}
-
-	// 启动 HTTP 服务器
-	log.Println("服务器正在启动，监听端口 :8080...")
-	if err := r.Run(":8080"); err != nil {
-		respondWithError(nil, http.StatusInternalServerError, "无法启动服务器", err)
-	}
+		protected.POST("/posts/:id/comments", commentsWrite, CreateCommentHandler)
+		// 评论作者可以编辑自己的评论，作者本人或管理员可以删除
+		protected.PUT("/comments/:id", commentsWrite, Authorize(Policy{
+			Owner:   commentOwner,
+			Message: "comment.edit_forbidden",
+		}), UpdateCommentHandler)
+		protected.DELETE("/comments/:id", commentsWrite, Authorize(Policy{
+			Permission: PermCommentDeleteAny,
+			Owner:      commentOwner,
+			Message:    "comment.delete_forbidden",
+		}), DeleteCommentHandler)
+		// 版主和管理员可以审核、隐藏任意评论
+		moderate := RequireScope(ScopeCommentsModerate)
+		protected.PUT("/comments/:id/moderation", moderate, RequirePermission(PermCommentModerate), ModerateCommentHandler)
+		protected.GET("/moderation/comments", moderate, RequirePermission(PermCommentModerate), GetModerationQueueHandler)
+
+		// 管理接口
+		admin := RequireScope(ScopeAdmin)
+		protected.PUT("/admin/users/:id/role", admin, RequirePermission(PermUserManage), UpdateUserRoleHandler)
+		protected.GET("/admin/role-policies", admin, RequirePermission(PermUserManage), GetRolePoliciesHandler)
+		protected.PUT("/admin/role-policies/:role", admin, RequirePermission(PermUserManage), UpdateRolePolicyHandler)
+	}
+	return r
 }
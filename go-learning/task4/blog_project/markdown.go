@@ -0,0 +1,764 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markdown 渲染：支持 CommonMark 的常用语法和 GFM 表格、删除线
+// 标题、段落、引用、列表、代码块（带语法高亮）、分隔线、表格、链接、图片、强调、行内代码
+// 输出的 HTML 不可信（Markdown 中可以直接写 HTML），必须经过 sanitizeHTML 过滤后才能返回给客户端
+
+// 渲染的资源上限：恶意构造的内容（大量不闭合的 * 或 [、上万层的 >）不能让渲染时间随长度平方增长
+const (
+	mdMaxNesting       = 16   // 引用和列表最多嵌套的层数，更深的部分按段落渲染
+	mdMaxInlineNesting = 16   // 强调和链接最多嵌套的层数，更深的部分原样输出
+	mdMaxInlineSpan    = 1024 // 强调、链接的开始标记之后最多向后查找多少字节的结束标记
+)
+
+var (
+	mdATXHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdThematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdSetextH1      = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	mdSetextH2      = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	mdFence         = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	mdBlockquote    = regexp.MustCompile(`^ {0,3}> ?`)
+	mdListItem      = regexp.MustCompile(`^( {0,3})([-+*]|\d{1,9}[.)])([ \t]+|$)`)
+	mdHTMLBlock     = regexp.MustCompile(`^ {0,3}<(?:[A-Za-z][A-Za-z0-9-]*|/[A-Za-z]|!--)`)
+	mdTableDelim    = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdEntity        = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	mdAutolink      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\x00-\x20]*)>`)
+	mdEmailAutolink = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	mdInlineHTML    = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>`)
+)
+
+// renderMarkdown 把 Markdown 渲染为 HTML
+func renderMarkdown(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	var b strings.Builder
+	renderBlocks(&b, lines, false, 0)
+	return b.String()
+}
+
+// expandTabs 把行首的制表符展开为空格（制表位宽度为 4），方便按缩进判断代码块和列表
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i, r := range line {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		if r != ' ' {
+			b.WriteString(line[i:])
+			return b.String()
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// isBlank 判断一行是否为空行
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indentOf 返回行首空格数
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripIndent 去掉最多 n 个行首空格
+func stripIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && line[i] == ' ' {
+		i++
+	}
+	return line[i:]
+}
+
+// startsBlock 判断一行是否会打断段落，开始一个新的块
+func startsBlock(line string) bool {
+	if mdATXHeading.MatchString(line) || mdThematicBreak.MatchString(line) || mdFence.MatchString(line) ||
+		mdBlockquote.MatchString(line) || mdHTMLBlock.MatchString(line) {
+		return true
+	}
+	// 只有以 1 开头的有序列表和非空的列表项才能打断段落
+	if m := mdListItem.FindStringSubmatch(line); m != nil && strings.TrimSpace(line[len(m[0]):]) != "" {
+		marker := m[2]
+		return marker == "-" || marker == "+" || marker == "*" || marker[:len(marker)-1] == "1"
+	}
+	return false
+}
+
+// renderBlocks 渲染一组行；tight 为 true 时（紧凑列表中）段落不包裹 <p>，depth 为引用和列表的嵌套层数
+func renderBlocks(b *strings.Builder, lines []string, tight bool, depth int) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case mdFence.MatchString(line):
+			i = renderFencedCode(b, lines, i)
+
+		case indentOf(line) >= 4:
+			var code []string
+			for i < len(lines) && (indentOf(lines[i]) >= 4 || isBlank(lines[i])) {
+				code = append(code, stripIndent(lines[i], 4))
+				i++
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n") + "\n"))
+			b.WriteString("</code></pre>\n")
+
+		case mdATXHeading.MatchString(line):
+			m := mdATXHeading.FindStringSubmatch(line)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level + ">" + renderInline(strings.TrimSpace(m[2])) + "</h" + level + ">\n")
+			i++
+
+		case mdThematicBreak.MatchString(line):
+			b.WriteString("<hr />\n")
+			i++
+
+		case mdBlockquote.MatchString(line) && depth < mdMaxNesting:
+			var quoted []string
+			for i < len(lines) && !isBlank(lines[i]) {
+				if loc := mdBlockquote.FindStringIndex(lines[i]); loc != nil {
+					quoted = append(quoted, lines[i][loc[1]:])
+				} else if startsBlock(lines[i]) {
+					break
+				} else {
+					// 惰性续行：没有 > 前缀的行属于引用中的段落
+					quoted = append(quoted, lines[i])
+				}
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false, depth+1)
+			b.WriteString("</blockquote>\n")
+
+		case mdListItem.MatchString(line) && depth < mdMaxNesting:
+			i = renderList(b, lines, i, depth)
+
+		case mdHTMLBlock.MatchString(line):
+			for i < len(lines) && !isBlank(lines[i]) {
+				b.WriteString(lines[i] + "\n")
+				i++
+			}
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && mdTableDelim.MatchString(lines[i+1]) &&
+			len(splitTableRow(line)) == len(splitTableRow(lines[i+1])):
+			i = renderTable(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+// renderFencedCode 渲染 ``` 或 ~~~ 包围的代码块，信息字符串的第一个词为语言
+func renderFencedCode(b *strings.Builder, lines []string, i int) int {
+	m := mdFence.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	lang := ""
+	if fields := strings.Fields(m[3]); len(fields) > 0 {
+		lang = strings.ToLower(fields[0])
+	}
+	i++
+	var code []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if indentOf(lines[i]) < 4 && strings.HasPrefix(trimmed, fence[:1]) &&
+			len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, stripIndent(lines[i], indent))
+	}
+
+	text := strings.Join(code, "\n")
+	if len(code) > 0 {
+		text += "\n"
+	}
+	if lang != "" {
+		b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	b.WriteString(highlightCode(lang, text))
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderParagraph 渲染段落，下一行是 === 或 --- 时为 Setext 标题
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var para []string
+	for i < len(lines) && !isBlank(lines[i]) {
+		if len(para) > 0 {
+			if mdSetextH1.MatchString(lines[i]) || mdSetextH2.MatchString(lines[i]) {
+				level := "1"
+				if mdSetextH2.MatchString(lines[i]) {
+					level = "2"
+				}
+				b.WriteString("<h" + level + ">" + renderInline(strings.Join(para, "\n")) + "</h" + level + ">\n")
+				return i + 1
+			}
+			if startsBlock(lines[i]) {
+				break
+			}
+		}
+		para = append(para, strings.TrimLeft(lines[i], " "))
+		i++
+	}
+	text := renderInline(strings.TrimRight(strings.Join(para, "\n"), " "))
+	if tight {
+		b.WriteString(text + "\n")
+	} else {
+		b.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+// renderList 渲染有序或无序列表，列表项之间或列表项内部有空行时为松散列表，段落包裹 <p>
+func renderList(b *strings.Builder, lines []string, i, depth int) int {
+	first := mdListItem.FindStringSubmatch(lines[i])
+	marker := first[2]
+	ordered := marker != "-" && marker != "+" && marker != "*"
+	delimiter := marker[len(marker)-1:]
+
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		m := mdListItem.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		// 列表符号类型不同时开始一个新的列表
+		if ordered != (m[2] != "-" && m[2] != "+" && m[2] != "*") || m[2][len(m[2])-1:] != delimiter {
+			break
+		}
+		contentIndent := len(m[0])
+		if rest := lines[i][len(m[0]):]; strings.TrimSpace(rest) == "" {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		} else if len(m[3]) > 4 {
+			// 符号后的空格超过 4 个时，内容是缩进代码块，只算一个空格
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		item := []string{stripIndent(lines[i][len(m[1])+len(m[2]):], contentIndent-len(m[1])-len(m[2]))}
+		i++
+
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				// 空行之后的内容缩进足够时仍属于当前列表项
+				j := i
+				for j < len(lines) && isBlank(lines[j]) {
+					j++
+				}
+				if j < len(lines) && indentOf(lines[j]) >= contentIndent {
+					item = append(item, "")
+					loose = true
+					i++
+					continue
+				}
+				break
+			}
+			if indentOf(line) >= contentIndent {
+				item = append(item, line[contentIndent:])
+			} else if !startsBlock(line) && !mdListItem.MatchString(line) && !isBlank(item[len(item)-1]) {
+				item = append(item, line) // 惰性续行
+			} else {
+				break
+			}
+			i++
+		}
+		items = append(items, item)
+
+		// 列表项之间有空行时为松散列表
+		j := i
+		for j < len(lines) && isBlank(lines[j]) {
+			j++
+		}
+		if j < len(lines) && j > i && mdListItem.MatchString(lines[j]) {
+			next := mdListItem.FindStringSubmatch(lines[j])
+			if next[2][len(next[2])-1:] == delimiter {
+				loose = true
+			}
+		}
+		i = j
+		if i < len(lines) && !mdListItem.MatchString(lines[i]) {
+			break
+		}
+	}
+
+	if ordered {
+		start, _ := strconv.Atoi(marker[:len(marker)-1])
+		if start != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		renderBlocks(&inner, item, !loose, depth+1)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// splitTableRow 按 | 拆分表格的一行，转义的 \| 不作为分隔符
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// renderTable 渲染 GFM 表格，分隔行中的冒号决定列的对齐方式
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	aligns := make([]string, len(header))
+	for k, cell := range splitTableRow(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[k] = "center"
+		case right:
+			aligns[k] = "right"
+		case left:
+			aligns[k] = "left"
+		}
+	}
+	cell := func(tag string, k int, text string) {
+		if aligns[k] != "" {
+			b.WriteString("<" + tag + ` align="` + aligns[k] + `">`)
+		} else {
+			b.WriteString("<" + tag + ">")
+		}
+		b.WriteString(renderInline(text) + "</" + tag + ">")
+	}
+
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for k, text := range header {
+		cell("th", k, text)
+	}
+	b.WriteString("</tr>\n</thead>\n")
+	i += 2
+	if i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		b.WriteString("<tbody>\n")
+		for i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") && !startsBlock(lines[i]) {
+			row := splitTableRow(lines[i])
+			b.WriteString("<tr>")
+			for k := range header {
+				text := ""
+				if k < len(row) {
+					text = row[k]
+				}
+				cell("td", k, text)
+			}
+			b.WriteString("</tr>\n")
+			i++
+		}
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+	return i
+}
+
+// isASCIIPunct 判断是否为可以用反斜杠转义的 ASCII 标点
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// renderInline 渲染行内元素
+func renderInline(s string) string {
+	return renderInlineNested(s, 0)
+}
+
+// renderInlineNested 渲染行内元素，depth 为强调和链接的嵌套层数
+func renderInlineNested(s string, depth int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if depth >= mdMaxInlineNesting && (c == '*' || c == '_' || c == '~' || c == '[' || c == '!') {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		switch c {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				b.WriteString("<br />\n")
+				i += 2
+				continue
+			}
+			if i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				b.WriteString(html.EscapeString(s[i+1 : i+2]))
+				i += 2
+				continue
+			}
+			b.WriteByte('\\')
+			i++
+
+		case '`':
+			n := runLength(s, i, '`')
+			if end := findCodeSpanEnd(s, i+n, n); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+
+		case '!', '[':
+			if c == '!' && (i+1 >= len(s) || s[i+1] != '[') {
+				b.WriteByte('!')
+				i++
+				continue
+			}
+			start := i
+			if c == '!' {
+				start++
+			}
+			text, dest, title, end, ok := parseLink(s, start)
+			if !ok {
+				b.WriteString(s[i : start+1])
+				i = start + 1
+				continue
+			}
+			titleAttr := ""
+			if title != "" {
+				titleAttr = ` title="` + html.EscapeString(title) + `"`
+			}
+			if c == '!' {
+				b.WriteString(`<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(plainText(text)) + `"` + titleAttr + " />")
+			} else {
+				b.WriteString(`<a href="` + html.EscapeString(dest) + `"` + titleAttr + ">" + renderInlineNested(text, depth+1) + "</a>")
+			}
+			i = end
+
+		case '<':
+			rest := s[i:]
+			if m := mdAutolink.FindStringSubmatch(rest); m != nil {
+				b.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+			} else if m := mdEmailAutolink.FindStringSubmatch(rest); m != nil {
+				b.WriteString(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+				i += len(m[0])
+			} else if m := mdInlineHTML.FindString(rest); m != "" {
+				b.WriteString(m) // 原样输出，由 sanitizeHTML 过滤
+				i += len(m)
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+
+		case '&':
+			if m := mdEntity.FindString(s[i:]); m != "" {
+				b.WriteString(m)
+				i += len(m)
+			} else {
+				b.WriteString("&amp;")
+				i++
+			}
+
+		case '*', '_', '~':
+			i = renderEmphasis(&b, s, i, depth)
+
+		case ' ':
+			// 行尾的空格不输出，有两个以上时为硬换行
+			n := runLength(s, i, ' ')
+			if i+n < len(s) && s[i+n] == '\n' {
+				if n >= 2 {
+					b.WriteString("<br />")
+				}
+			} else {
+				b.WriteString(s[i : i+n])
+			}
+			i += n
+
+		case '\n':
+			b.WriteByte('\n')
+			i++
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(html.EscapeString(s[i : i+size]))
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// runLength 返回从 i 开始连续的字符 c 的个数
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// findCodeSpanEnd 查找与开头长度相同的反引号串，返回其位置
+func findCodeSpanEnd(s string, from, n int) int {
+	for j := from; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		k := runLength(s, j, '`')
+		if k == n {
+			return j
+		}
+		j += k
+	}
+	return -1
+}
+
+// parseLink 解析 [text](dest "title")，start 指向 [；整个链接不能超过 mdMaxInlineSpan 个字节
+func parseLink(s string, start int) (text, dest, title string, end int, ok bool) {
+	s = s[:min(len(s), start+mdMaxInlineSpan)]
+	depth := 0
+	closeBracket := -1
+	for j := start; j < len(s) && closeBracket < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '`':
+			n := runLength(s, j, '`')
+			if e := findCodeSpanEnd(s, j+n, n); e >= 0 {
+				j = e + n - 1
+			} else {
+				j += n - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeBracket = j
+			}
+		}
+	}
+	if closeBracket < 0 || closeBracket+1 >= len(s) || s[closeBracket+1] != '(' {
+		return "", "", "", 0, false
+	}
+	text = s[start+1 : closeBracket]
+
+	j := closeBracket + 2
+	skipSpaces := func() {
+		for j < len(s) && (s[j] == ' ' || s[j] == '\n') {
+			j++
+		}
+	}
+	skipSpaces()
+	if j < len(s) && s[j] == '<' {
+		e := strings.IndexAny(s[j+1:], ">\n")
+		if e < 0 || s[j+1+e] != '>' {
+			return "", "", "", 0, false
+		}
+		dest = s[j+1 : j+1+e]
+		j += e + 2
+	} else {
+		parens := 0
+		begin := j
+		for j < len(s) && s[j] > ' ' {
+			if s[j] == '\\' && j+1 < len(s) {
+				j += 2
+				continue
+			}
+			if s[j] == '(' {
+				parens++
+			} else if s[j] == ')' {
+				if parens == 0 {
+					break
+				}
+				parens--
+			}
+			j++
+		}
+		dest = s[begin:j]
+	}
+	skipSpaces()
+	if j < len(s) && (s[j] == '"' || s[j] == '\'' || s[j] == '(') {
+		closer := s[j]
+		if closer == '(' {
+			closer = ')'
+		}
+		e := strings.IndexByte(s[j+1:], closer)
+		if e < 0 {
+			return "", "", "", 0, false
+		}
+		title = s[j+1 : j+1+e]
+		j += e + 2
+		skipSpaces()
+	}
+	if j >= len(s) || s[j] != ')' {
+		return "", "", "", 0, false
+	}
+	return text, unescapeBackslashes(dest), unescapeBackslashes(title), j + 1, true
+}
+
+// unescapeBackslashes 去掉链接地址和标题中的反斜杠转义
+func unescapeBackslashes(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// plainText 去掉强调等标记，用于图片的 alt
+func plainText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '*' || r == '_' || r == '`' || r == '[' || r == ']' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// renderEmphasis 处理 *、_ 和 ~~：找到同样长度的结束标记时渲染为 em / strong / del，否则原样输出
+func renderEmphasis(b *strings.Builder, s string, i, depth int) int {
+	c := s[i]
+	n := runLength(s, i, c)
+	literal := func() int {
+		b.WriteString(s[i : i+n])
+		return i + n
+	}
+
+	// 开始标记后面不能是空白；_ 在单词中间时不作为强调（例如 snake_case）
+	if i+n >= len(s) || unicode.IsSpace(rune(s[i+n])) || n > 3 {
+		return literal()
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return literal()
+	}
+
+	var k int
+	var open, close string
+	switch {
+	case c == '~' && n == 2:
+		k, open, close = 2, "<del>", "</del>"
+	case c == '~':
+		return literal()
+	case n >= 2:
+		k, open, close = 2, "<strong>", "</strong>"
+	default:
+		k, open, close = 1, "<em>", "</em>"
+	}
+
+	end := findEmphasisClose(s, i+k, c, k)
+	if end < 0 && k == 2 && c != '~' {
+		// 找不到 ** 的结束标记时退回到 *
+		k, open, close = 1, "<em>", "</em>"
+		end = findEmphasisClose(s, i+1, c, 1)
+		if end >= 0 {
+			b.WriteString(s[i : i+n-1])
+			i += n - 1
+		}
+	}
+	if end < 0 {
+		return literal()
+	}
+	b.WriteString(open + renderInlineNested(s[i+k:end], depth+1) + close)
+	return end + k
+}
+
+// findEmphasisClose 查找长度为 k（或 3，取其末尾）的结束标记，结束标记前面不能是空白
+// 跳过行内代码，结束标记不能跨越代码；只在 mdMaxInlineSpan 个字节内查找
+func findEmphasisClose(s string, from int, c byte, k int) int {
+	s = s[:min(len(s), from+mdMaxInlineSpan)]
+	for j := from; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			n := runLength(s, j, '`')
+			if e := findCodeSpanEnd(s, j+n, n); e >= 0 {
+				j = e + n
+			} else {
+				j += n
+			}
+			continue
+		case c:
+			n := runLength(s, j, c)
+			closes := j > from && !unicode.IsSpace(rune(s[j-1]))
+			if c == '_' && j+n < len(s) && isWordByte(s[j+n]) {
+				closes = false
+			}
+			if closes && (n == k || (n == 3 && c != '~')) {
+				return j + n - k
+			}
+			j += n
+			continue
+		}
+		j++
+	}
+	return -1
+}
+
+// isWordByte 判断是否为字母或数字（只看 ASCII，中文前后的 _ 仍然可以作为强调）
+func isWordByte(c byte) bool {
+	return c < utf8.RuneSelf && (c == '_' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"标题", "# 标题\n\n## 小节", "<h1 id=\"标题\">标题</h1>\n<h2 id=\"小节\">小节</h2>\n"},
+		{"行内格式", "*em* **strong** ~~del~~ `a*b`", "<p><em>em</em> <strong>strong</strong> <del>del</del> <code>a*b</code></p>\n"},
+		{"转义", `a\*b\*`, "<p>a*b*</p>\n"},
+		{"链接和图片", `[a](http://example.com "t") ![i](/a.png)`,
+			"<p><a href=\"http://example.com\" title=\"t\" rel=\"nofollow noopener noreferrer\">a</a> <img src=\"/a.png\" alt=\"i\" /></p>\n"},
+		{"代码块", "```go\nfunc main() {}\n```", "<pre><code class=\"language-go\"><span class=\"hl-keyword\">func</span> main() {}\n</code></pre>\n"},
+		{"紧凑列表", "- a\n- b\n\n1. x\n2. y", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>x</li>\n<li>y</li>\n</ol>\n"},
+		{"松散列表", "- a\n\n- b", "<ul>\n<li><p>a</p></li>\n<li><p>b</p></li>\n</ul>\n"},
+		{"嵌套引用", "> q\n> > n", "<blockquote>\n<p>q</p>\n<blockquote>\n<p>n</p>\n</blockquote>\n</blockquote>\n"},
+		{"表格", "| a | b |\n|:-|-:|\n| 1 | 2 |",
+			"<table>\n<thead>\n<tr><th align=\"left\">a</th><th align=\"right\">b</th></tr>\n</thead>\n<tbody>\n<tr><td align=\"left\">1</td><td align=\"right\">2</td></tr>\n</tbody>\n</table>\n"},
+		{"硬换行", "a  \nb \nc", "<p>a<br />\nb\nc</p>\n"},
+		{"不闭合的标记", "*a **b [c](", "<p>*a **b [c](</p>\n"},
+		{"事件属性", `x <span onclick="alert(1)">y</span>`, "<p>x <span>y</span></p>\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := renderContent(ContentFormatMarkdown, tc.in).HTML; got != tc.want {
+				t.Errorf("渲染结果不符\n输入: %q\n得到: %q\n期望: %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownNestingLimit(t *testing.T) {
+	out := renderContent(ContentFormatMarkdown, strings.Repeat(">", 100)+" x").HTML
+	if n := strings.Count(out, "<blockquote>"); n != mdMaxNesting {
+		t.Errorf("引用嵌套了 %d 层，期望最多 %d 层", n, mdMaxNesting)
+	}
+	out = renderContent(ContentFormatMarkdown, strings.Repeat("*", 100)+"x"+strings.Repeat("*", 100)).HTML
+	if n := strings.Count(out, "<em>") + strings.Count(out, "<strong>"); n > mdMaxInlineNesting {
+		t.Errorf("强调嵌套了 %d 层，期望最多 %d 层", n, mdMaxInlineNesting)
+	}
+}
+
+// 恶意构造的内容渲染时间应该随长度线性增长，这里的输入在修复前需要数秒
+func TestRenderPathological(t *testing.T) {
+	var deepList strings.Builder
+	for i := 0; i < 2000; i++ {
+		deepList.WriteString(strings.Repeat("  ", i) + "- x\n")
+	}
+	cases := []struct {
+		name, format, in string
+	}{
+		{"不闭合的强调", ContentFormatMarkdown, strings.Repeat("*a ", 30000)},
+		{"不闭合的加粗", ContentFormatMarkdown, strings.Repeat("**a ", 30000)},
+		{"不闭合的链接", ContentFormatMarkdown, strings.Repeat("[a](", 20000)},
+		{"方括号", ContentFormatMarkdown, strings.Repeat("[", 50000)},
+		{"深层引用", ContentFormatMarkdown, strings.Repeat(">", 20000) + " x"},
+		{"深层列表", ContentFormatMarkdown, deepList.String()},
+		{"大量硬换行", ContentFormatMarkdown, strings.Repeat("a  \n", 30000)},
+		{"深层 div", ContentFormatHTML, strings.Repeat("<div>", 20000)},
+		{"深层列表标签", ContentFormatHTML, strings.Repeat("<ul><li>", 20000)},
+		{"Markdown 中的深层 HTML", ContentFormatMarkdown, strings.Repeat("<blockquote>", 20000)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			renderContent(tc.format, tc.in)
+			if d := time.Since(start); d > 2*time.Second {
+				t.Errorf("渲染耗时 %v", d)
+			}
+		})
+	}
+}
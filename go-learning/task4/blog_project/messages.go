@@ -0,0 +1,432 @@
+package main
+
+// messages 消息目录：语言 -> 消息 ID -> 消息模板（fmt 格式）
+// 新增消息时两种语言都要补上，缺少翻译时会退回 zh-CN
+var messages = map[string]map[string]string{
+	LocaleZhCN: {
+		// 通用
+		"request.invalid":           "无效的请求数据",
+		"request.invalid_id":        "无效的ID",
+		"request.validation_failed": "请求参数校验失败",
+		"request.route_not_found":   "接口不存在",
+		"request.rate_limited":      "请求过于频繁，请 %d 秒后再试",
+		"resource.not_found":        "资源不存在",
+		"resource.conflict":         "资源已存在",
+		"resource.fk_conflict":      "存在关联数据，无法完成操作",
+		"server.internal_error":     "服务器内部错误",
+		"db.query_failed":           "数据库查询错误",
+
+		// 认证与权限
+		"auth.token_missing":           "请求未包含授权 token",
+		"auth.token_malformed":         "授权 token 格式错误",
+		"auth.token_expired":           "Token 已过期",
+		"auth.token_invalid":           "无效的 Token",
+		"auth.token_revoked":           "Token 已被吊销",
+		"auth.token_check_failed":      "校验 Token 状态失败",
+		"auth.token_issue_failed":      "生成 token 失败",
+		"auth.unauthenticated":         "用户未认证",
+		"auth.invalid_credentials":     "用户名或密码错误",
+		"auth.account_locked":          "密码错误次数过多，账号已被临时锁定，请 %d 分钟后再试",
+		"auth.api_key_invalid":         "API Key 无效或已吊销",
+		"auth.api_key_expired":         "API Key 已过期",
+		"auth.api_key_not_allowed":     "该操作不能使用 API Key，请登录后操作",
+		"auth.insufficient_scope":      "API Key 缺少 %s 权限范围",
+		"auth.refresh_token_invalid":   "刷新 token 无效",
+		"auth.refresh_token_expired":   "刷新 token 已过期",
+		"auth.refresh_token_reused":    "刷新 token 已失效，请重新登录",
+		"auth.refresh_failed":          "刷新 token 失败",
+		"auth.logout_failed":           "登出失败",
+		"auth.forbidden":               "您没有权限执行此操作",
+		"auth.permission_check_failed": "权限检查失败",
+		"auth.logged_in":               "登录成功",
+		"auth.refreshed":               "刷新成功",
+		"auth.logged_out":              "登出成功",
+		"auth.email_not_verified":      "请先验证邮箱，未验证的账号只能浏览内容",
+		"auth.password_reset_sent":     "如果该邮箱已注册，我们会发送一封重置密码的邮件",
+		"auth.password_reset":          "密码已重置，请使用新密码重新登录",
+		"auth.reset_token_invalid":     "重置密码链接无效或已过期",
+		"auth.reset_request_failed":    "发送重置密码邮件失败",
+		"auth.reset_failed":            "重置密码失败",
+
+		"auth.mfa_required":               "请输入两步验证码",
+		"auth.mfa_token_invalid":          "两步验证已超时或失败次数过多，请重新登录",
+		"auth.mfa_invalid_code":           "验证码错误",
+		"auth.mfa_code_required":          "开启了两步验证，请提供验证码或恢复码",
+		"auth.mfa_enrollment_required":    "您所在的角色要求开启两步验证，请先完成设置",
+		"auth.mfa_already_enabled":        "两步验证已经开启",
+		"auth.mfa_not_enabled":            "尚未开启两步验证",
+		"auth.mfa_setup_required":         "请先获取两步验证密钥",
+		"auth.mfa_required_by_role":       "您所在的角色要求开启两步验证，不能关闭",
+		"auth.mfa_setup":                  "请用身份验证器扫描二维码，然后提交验证码完成开启",
+		"auth.mfa_enabled":                "两步验证已开启，请妥善保存恢复码，每个恢复码只能使用一次",
+		"auth.mfa_disabled":               "两步验证已关闭",
+		"auth.mfa_failed":                 "两步验证操作失败",
+		"auth.recovery_codes_regenerated": "已生成新的恢复码，旧的恢复码已失效",
+
+		"auth.siwe_invalid_message":   "签名消息格式错误",
+		"auth.siwe_domain_mismatch":   "签名消息中的域名与本站不一致",
+		"auth.siwe_chain_not_allowed": "不支持该链 ID",
+		"auth.siwe_not_yet_valid":     "签名消息尚未生效",
+		"auth.siwe_expired":           "签名消息已过期",
+		"auth.siwe_invalid_signature": "签名无效",
+		"auth.siwe_nonce_invalid":     "nonce 无效、已使用或已过期，请重新获取",
+		"auth.siwe_nonce_failed":      "生成 nonce 失败",
+		"auth.siwe_failed":            "钱包登录失败",
+
+		// 用户
+		"user.registered":                "用户注册成功",
+		"user.username_taken":            "用户名已存在",
+		"user.email_taken":               "邮箱已被注册",
+		"user.password_hash_failed":      "密码加密失败",
+		"user.create_failed":             "用户创建失败",
+		"user.not_found":                 "用户不存在",
+		"user.invalid_role":              "无效的角色",
+		"user.invalid_locale":            "不支持的语言",
+		"user.role_update_failed":        "修改角色失败",
+		"user.role_updated":              "角色修改成功",
+		"user.role_policy_updated":       "角色安全策略已更新",
+		"user.role_policy_update_failed": "更新角色安全策略失败",
+		"user.role_policy_list_failed":   "获取角色安全策略失败",
+		"user.locale_update_failed":      "修改语言偏好失败",
+		"user.locale_updated":            "语言偏好已更新，重新登录或刷新 token 后生效",
+		"profile.fetched":                "获取个人资料成功",
+		"profile.updated":                "个人资料已更新",
+		"profile.update_failed":          "更新个人资料失败",
+
+		"user.email_verified":             "邮箱验证成功",
+		"user.email_already_verified":     "邮箱已经验证过了",
+		"user.verification_sent":          "验证邮件已发送，请查收",
+		"user.verification_send_failed":   "发送验证邮件失败",
+		"user.verification_token_invalid": "邮箱验证链接无效或已过期",
+		"user.verify_failed":              "邮箱验证失败",
+		"user.email_missing":              "账号没有绑定邮箱",
+
+		"user.wallet_linked":        "钱包绑定成功",
+		"user.wallet_unlinked":      "钱包已解除绑定",
+		"user.wallet_taken":         "该钱包已绑定其他账号",
+		"user.wallet_not_linked":    "尚未绑定钱包",
+		"user.wallet_required":      "账号没有设置邮箱和密码，解除钱包绑定后将无法登录",
+		"user.wallet_link_failed":   "绑定钱包失败",
+		"user.wallet_unlink_failed": "解除钱包绑定失败",
+
+		"user.password_changed":             "密码修改成功，请重新登录",
+		"user.password_change_failed":       "修改密码失败",
+		"user.password_not_set":             "账号没有设置密码",
+		"user.deleted":                      "账号已注销",
+		"user.delete_failed":                "注销账号失败",
+		"user.delete_confirmation_mismatch": "确认的用户名与当前账号不一致",
+		"user.page_fetched":                 "获取作者主页成功",
+		"user.page_failed":                  "获取作者主页失败",
+
+		// API Key
+		"api_key.created":            "API Key 创建成功，请立即保存，之后将无法再次查看",
+		"api_key.revoked":            "API Key 已吊销",
+		"api_key.not_found":          "API Key 不存在",
+		"api_key.invalid_expires_at": "过期时间必须晚于当前时间",
+		"api_key.create_failed":      "创建 API Key 失败",
+		"api_key.list_failed":        "获取 API Key 列表失败",
+		"api_key.revoke_failed":      "吊销 API Key 失败",
+
+		// 邮件，正文参数依次为用户名、有效期、链接
+		"mail.verify_subject": "请验证您的邮箱",
+		"mail.verify_body":    "%s，您好：\n\n感谢注册。请在 %s 内打开下面的链接验证邮箱：\n\n%s\n\n如果您没有注册过账号，请忽略这封邮件。\n",
+		"mail.reset_subject":  "重置密码",
+		"mail.reset_body":     "%s，您好：\n\n我们收到了重置密码的请求。请在 %s 内打开下面的链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略这封邮件，您的密码不会被修改。\n",
+
+		// 文章
+		"post.created":             "文章创建成功",
+		"post.listed":              "获取文章列表成功",
+		"post.fetched":             "获取文章详情成功",
+		"post.updated":             "文章更新成功",
+		"post.deleted":             "文章删除成功",
+		"post.not_found":           "文章不存在",
+		"post.invalid_id":          "无效的文章ID",
+		"post.invalid_status":      "无效的文章状态",
+		"post.publish_at_required": "定时发布的文章必须指定发布时间",
+		"post.publish_at_past":     "定时发布时间必须晚于当前时间",
+		"post.create_failed":       "文章创建失败",
+		"post.list_failed":         "获取文章列表失败",
+		"post.get_failed":          "获取文章失败",
+		"post.detail_failed":       "获取文章详情失败",
+		"post.update_failed":       "更新文章失败",
+		"post.delete_failed":       "删除文章失败",
+		"post.update_forbidden":    "您没有权限更新此文章",
+		"post.delete_forbidden":    "您没有权限删除此文章",
+		"post.version_conflict":    "文章已被修改，请重新获取最新版本后再编辑",
+
+		// 修订历史
+		"revision.restored":        "文章已恢复",
+		"revision.invalid_version": "无效的版本号",
+		"revision.not_found":       "修订版本不存在",
+		"revision.list_failed":     "获取修订记录失败",
+		"revision.get_failed":      "获取修订版本失败",
+		"revision.diff_failed":     "生成 diff 失败",
+		"revision.restore_failed":  "恢复修订版本失败",
+		"revision.view_forbidden":  "您没有权限查看此文章的修订历史",
+
+		// 评论
+		"comment.created":                   "评论创建成功",
+		"comment.pending":                   "评论已提交，等待审核",
+		"comment.updated":                   "评论编辑成功",
+		"comment.deleted":                   "评论删除成功",
+		"comment.moderated":                 "评论审核状态已更新",
+		"comment.not_found":                 "评论不存在",
+		"comment.parent_not_found":          "回复的评论不存在",
+		"comment.depth_exceeded":            "回复层级过深，最多允许 %d 层",
+		"comment.locked":                    "该评论已被版主处理，不能再编辑",
+		"comment.invalid_moderation_status": "无效的审核状态",
+		"comment.create_failed":             "评论创建失败",
+		"comment.get_failed":                "获取评论失败",
+		"comment.replies_failed":            "获取评论回复失败",
+		"comment.update_failed":             "编辑评论失败",
+		"comment.delete_failed":             "删除评论失败",
+		"comment.moderate_failed":           "审核评论失败",
+		"comment.queue_failed":              "获取审核队列失败",
+		"comment.edit_forbidden":            "您没有权限编辑此评论",
+		"comment.delete_forbidden":          "您没有权限删除此评论",
+
+		// 附件
+		"attachment.uploaded":         "附件上传成功",
+		"attachment.listed":           "获取附件列表成功",
+		"attachment.deleted":          "附件已删除",
+		"attachment.not_found":        "附件不存在",
+		"attachment.file_required":    "请通过 multipart 表单的 file 字段上传文件",
+		"attachment.too_large":        "文件大小不能超过 %sMB",
+		"attachment.unsupported_type": "不支持上传 %s 类型的文件",
+		"attachment.invalid_image":    "图片文件已损坏或无法解析",
+		"attachment.delete_forbidden": "您没有权限删除此附件",
+		"attachment.upload_failed":    "上传附件失败",
+		"attachment.list_failed":      "获取附件列表失败",
+		"attachment.get_failed":       "查询附件失败",
+		"attachment.download_failed":  "读取附件失败",
+		"attachment.delete_failed":    "删除附件失败",
+
+		// 分页、搜索、标签
+		"pagination.invalid_cursor":    "无效的分页游标",
+		"pagination.invalid_page_size": "无效的分页大小",
+		"pagination.page_unsupported":  "不支持 page 参数，请使用 next_cursor 翻页",
+		"search.query_required":        "请提供搜索关键词",
+		"search.term_too_short":        "每个搜索关键词至少需要 %d 个字符",
+		"search.failed":                "搜索失败",
+		"tag.list_failed":              "获取标签列表失败",
+		"category.list_failed":         "获取分类列表失败",
+
+		// 订阅源
+		"feed.failed": "生成订阅源失败",
+
+		// 自定义校验规则的提示，第一个参数为字段名
+		"validation.username":   "%s必须以字母开头，只能包含字母、数字和下划线，长度为3到32个字符",
+		"validation.password":   "%s至少需要%d个字符，且必须同时包含字母和数字",
+		"validation.maxcontent": "%s不能超过%d个字符",
+		"validation.locale":     "%s必须是以下语言之一：%s",
+		"validation.apiscope":   "%s必须是以下权限范围之一：%s",
+		"validation.httpurl":    "%s必须是以 http:// 或 https:// 开头的网址",
+	},
+	LocaleEnUS: {
+		"request.invalid":           "Invalid request data",
+		"request.invalid_id":        "Invalid ID",
+		"request.validation_failed": "Request validation failed",
+		"request.route_not_found":   "Endpoint not found",
+		"request.rate_limited":      "Too many requests, please try again in %d seconds",
+		"resource.not_found":        "Resource not found",
+		"resource.conflict":         "Resource already exists",
+		"resource.fk_conflict":      "The operation conflicts with related data",
+		"server.internal_error":     "Internal server error",
+		"db.query_failed":           "Database query failed",
+
+		"auth.token_missing":           "Authorization token is missing",
+		"auth.token_malformed":         "Malformed authorization token",
+		"auth.token_expired":           "Token has expired",
+		"auth.token_invalid":           "Invalid token",
+		"auth.token_revoked":           "Token has been revoked",
+		"auth.token_check_failed":      "Failed to verify token status",
+		"auth.token_issue_failed":      "Failed to issue token",
+		"auth.unauthenticated":         "Authentication required",
+		"auth.invalid_credentials":     "Invalid username or password",
+		"auth.account_locked":          "Too many failed login attempts, the account is locked, please try again in %d minutes",
+		"auth.api_key_invalid":         "Invalid or revoked API key",
+		"auth.api_key_expired":         "API key has expired",
+		"auth.api_key_not_allowed":     "API keys cannot be used for this action, please sign in",
+		"auth.insufficient_scope":      "The API key is missing the %s scope",
+		"auth.refresh_token_invalid":   "Invalid refresh token",
+		"auth.refresh_token_expired":   "Refresh token has expired",
+		"auth.refresh_token_reused":    "Refresh token is no longer valid, please log in again",
+		"auth.refresh_failed":          "Failed to refresh token",
+		"auth.logout_failed":           "Failed to log out",
+		"auth.forbidden":               "You do not have permission to perform this action",
+		"auth.permission_check_failed": "Permission check failed",
+		"auth.logged_in":               "Logged in successfully",
+		"auth.refreshed":               "Token refreshed",
+		"auth.logged_out":              "Logged out successfully",
+		"auth.email_not_verified":      "Please verify your email address; unverified accounts have read-only access",
+		"auth.password_reset_sent":     "If the email address is registered, a password reset email has been sent",
+		"auth.password_reset":          "Your password has been reset, please log in again",
+		"auth.reset_token_invalid":     "The password reset link is invalid or has expired",
+		"auth.reset_request_failed":    "Failed to send the password reset email",
+		"auth.reset_failed":            "Failed to reset password",
+
+		"auth.mfa_required":               "Please enter your two-factor authentication code",
+		"auth.mfa_token_invalid":          "Two-factor authentication timed out or failed too many times, please log in again",
+		"auth.mfa_invalid_code":           "Invalid verification code",
+		"auth.mfa_code_required":          "Two-factor authentication is enabled, please provide a verification code or recovery code",
+		"auth.mfa_enrollment_required":    "Your role requires two-factor authentication, please set it up first",
+		"auth.mfa_already_enabled":        "Two-factor authentication is already enabled",
+		"auth.mfa_not_enabled":            "Two-factor authentication is not enabled",
+		"auth.mfa_setup_required":         "Please request a two-factor authentication secret first",
+		"auth.mfa_required_by_role":       "Your role requires two-factor authentication, it cannot be disabled",
+		"auth.mfa_setup":                  "Scan the QR code with your authenticator app, then submit a code to finish enabling",
+		"auth.mfa_enabled":                "Two-factor authentication enabled; store your recovery codes safely, each can be used once",
+		"auth.mfa_disabled":               "Two-factor authentication disabled",
+		"auth.mfa_failed":                 "Two-factor authentication operation failed",
+		"auth.recovery_codes_regenerated": "New recovery codes generated; the old ones are no longer valid",
+
+		"auth.siwe_invalid_message":   "Malformed sign-in message",
+		"auth.siwe_domain_mismatch":   "The domain in the sign-in message does not match this site",
+		"auth.siwe_chain_not_allowed": "Chain ID is not supported",
+		"auth.siwe_not_yet_valid":     "The sign-in message is not valid yet",
+		"auth.siwe_expired":           "The sign-in message has expired",
+		"auth.siwe_invalid_signature": "Invalid signature",
+		"auth.siwe_nonce_invalid":     "Nonce is invalid, used or expired, please request a new one",
+		"auth.siwe_nonce_failed":      "Failed to generate nonce",
+		"auth.siwe_failed":            "Wallet sign-in failed",
+
+		"user.registered":                "Registration successful",
+		"user.username_taken":            "Username is already taken",
+		"user.email_taken":               "Email is already registered",
+		"user.password_hash_failed":      "Failed to hash password",
+		"user.create_failed":             "Failed to create user",
+		"user.not_found":                 "User not found",
+		"user.invalid_role":              "Invalid role",
+		"user.invalid_locale":            "Unsupported language",
+		"user.role_update_failed":        "Failed to update role",
+		"user.role_updated":              "Role updated",
+		"user.role_policy_updated":       "Role security policy updated",
+		"user.role_policy_update_failed": "Failed to update role security policy",
+		"user.role_policy_list_failed":   "Failed to list role security policies",
+		"user.locale_update_failed":      "Failed to update language preference",
+		"user.locale_updated":            "Language preference updated; it takes effect after you log in again or refresh your token",
+		"profile.fetched":                "Profile fetched",
+		"profile.updated":                "Profile updated",
+		"profile.update_failed":          "Failed to update profile",
+
+		"user.email_verified":             "Email address verified",
+		"user.email_already_verified":     "Email address is already verified",
+		"user.verification_sent":          "Verification email sent, please check your inbox",
+		"user.verification_send_failed":   "Failed to send the verification email",
+		"user.verification_token_invalid": "The verification link is invalid or has expired",
+		"user.verify_failed":              "Failed to verify email address",
+		"user.email_missing":              "No email address is associated with this account",
+
+		"user.wallet_linked":        "Wallet linked",
+		"user.wallet_unlinked":      "Wallet unlinked",
+		"user.wallet_taken":         "This wallet is already linked to another account",
+		"user.wallet_not_linked":    "No wallet is linked",
+		"user.wallet_required":      "The account has no email and password, you would be unable to sign in after unlinking the wallet",
+		"user.wallet_link_failed":   "Failed to link wallet",
+		"user.wallet_unlink_failed": "Failed to unlink wallet",
+
+		"user.password_changed":             "Password changed, please log in again",
+		"user.password_change_failed":       "Failed to change password",
+		"user.password_not_set":             "The account has no password",
+		"user.deleted":                      "Account deleted",
+		"user.delete_failed":                "Failed to delete account",
+		"user.delete_confirmation_mismatch": "The confirmed username does not match the current account",
+		"user.page_fetched":                 "Author page fetched",
+		"user.page_failed":                  "Failed to fetch author page",
+
+		"api_key.created":            "API key created, copy it now as it will not be shown again",
+		"api_key.revoked":            "API key revoked",
+		"api_key.not_found":          "API key not found",
+		"api_key.invalid_expires_at": "Expiry time must be in the future",
+		"api_key.create_failed":      "Failed to create API key",
+		"api_key.list_failed":        "Failed to list API keys",
+		"api_key.revoke_failed":      "Failed to revoke API key",
+
+		"mail.verify_subject": "Please verify your email address",
+		"mail.verify_body":    "Hi %s,\n\nThanks for signing up. Please open the link below within %s to verify your email address:\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+		"mail.reset_subject":  "Reset your password",
+		"mail.reset_body":     "Hi %s,\n\nWe received a request to reset your password. Open the link below within %s to choose a new password:\n\n%s\n\nIf you did not request this, you can ignore this email and your password will not change.\n",
+
+		"post.created":             "Post created",
+		"post.listed":              "Posts retrieved",
+		"post.fetched":             "Post retrieved",
+		"post.updated":             "Post updated",
+		"post.deleted":             "Post deleted",
+		"post.not_found":           "Post not found",
+		"post.invalid_id":          "Invalid post ID",
+		"post.invalid_status":      "Invalid post status",
+		"post.publish_at_required": "Scheduled posts must specify a publish time",
+		"post.publish_at_past":     "Scheduled publish time must be in the future",
+		"post.create_failed":       "Failed to create post",
+		"post.list_failed":         "Failed to list posts",
+		"post.get_failed":          "Failed to load post",
+		"post.detail_failed":       "Failed to load post details",
+		"post.update_failed":       "Failed to update post",
+		"post.delete_failed":       "Failed to delete post",
+		"post.update_forbidden":    "You do not have permission to update this post",
+		"post.delete_forbidden":    "You do not have permission to delete this post",
+		"post.version_conflict":    "The post has been modified; fetch the latest version and try again",
+
+		"revision.restored":        "Post restored",
+		"revision.invalid_version": "Invalid revision version",
+		"revision.not_found":       "Revision not found",
+		"revision.list_failed":     "Failed to list revisions",
+		"revision.get_failed":      "Failed to load revision",
+		"revision.diff_failed":     "Failed to generate diff",
+		"revision.restore_failed":  "Failed to restore revision",
+		"revision.view_forbidden":  "You do not have permission to view this post's revisions",
+
+		"comment.created":                   "Comment created",
+		"comment.pending":                   "Comment submitted and awaiting moderation",
+		"comment.updated":                   "Comment updated",
+		"comment.deleted":                   "Comment deleted",
+		"comment.moderated":                 "Comment moderation status updated",
+		"comment.not_found":                 "Comment not found",
+		"comment.parent_not_found":          "The comment you are replying to does not exist",
+		"comment.depth_exceeded":            "Replies are nested too deeply; at most %d levels are allowed",
+		"comment.locked":                    "This comment has been moderated and can no longer be edited",
+		"comment.invalid_moderation_status": "Invalid moderation status",
+		"comment.create_failed":             "Failed to create comment",
+		"comment.get_failed":                "Failed to load comments",
+		"comment.replies_failed":            "Failed to load replies",
+		"comment.update_failed":             "Failed to update comment",
+		"comment.delete_failed":             "Failed to delete comment",
+		"comment.moderate_failed":           "Failed to moderate comment",
+		"comment.queue_failed":              "Failed to load moderation queue",
+		"comment.edit_forbidden":            "You do not have permission to edit this comment",
+		"comment.delete_forbidden":          "You do not have permission to delete this comment",
+
+		"attachment.uploaded":         "Attachment uploaded",
+		"attachment.listed":           "Attachments listed",
+		"attachment.deleted":          "Attachment deleted",
+		"attachment.not_found":        "Attachment not found",
+		"attachment.file_required":    "Upload the file in the file field of a multipart form",
+		"attachment.too_large":        "File size must not exceed %sMB",
+		"attachment.unsupported_type": "Files of type %s are not allowed",
+		"attachment.invalid_image":    "The image file is corrupt or cannot be decoded",
+		"attachment.delete_forbidden": "You do not have permission to delete this attachment",
+		"attachment.upload_failed":    "Failed to upload attachment",
+		"attachment.list_failed":      "Failed to list attachments",
+		"attachment.get_failed":       "Failed to look up attachment",
+		"attachment.download_failed":  "Failed to read attachment",
+		"attachment.delete_failed":    "Failed to delete attachment",
+
+		"pagination.invalid_cursor":    "Invalid pagination cursor",
+		"pagination.invalid_page_size": "Invalid page size",
+		"pagination.page_unsupported":  "The page parameter is not supported, use next_cursor instead",
+		"search.query_required":        "Please provide a search query",
+		"search.term_too_short":        "Each search term must be at least %d characters long",
+		"search.failed":                "Search failed",
+		"tag.list_failed":              "Failed to list tags",
+		"category.list_failed":         "Failed to list categories",
+
+		"feed.failed": "Failed to build feed",
+
+		"validation.username":   "%s must start with a letter and contain only letters, digits and underscores (3-32 characters)",
+		"validation.password":   "%s must be at least %d characters long and contain both letters and digits",
+		"validation.maxcontent": "%s must not exceed %d characters",
+		"validation.locale":     "%s must be one of: %s",
+		"validation.apiscope":   "%s must be one of: %s",
+		"validation.httpurl":    "%s must be a URL starting with http:// or https://",
+	},
+}
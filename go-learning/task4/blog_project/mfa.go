@@ -0,0 +1,515 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+// TOTP 参数，与 Google Authenticator 等常见身份验证器的默认值一致（RFC 6238）
+const (
+	totpPeriod = 30 // 每个验证码的有效时间（秒）
+	totpSkew   = 1  // 允许前后各偏差一个时间片，容忍手机时钟误差
+	totpDigits = otp.DigitsSix
+
+	recoveryCodeCount  = 10 // 每次生成的恢复码数量
+	mfaMaxAttempts     = 5  // 登录时同一个两步验证 token 允许输错的次数
+	totpQRCodeSize     = 200
+	recoveryCodeLength = 10 // 恢复码的十六进制字符数，展示时每 5 位用 - 分隔
+)
+
+var errInvalidSecondFactor = errors.New("两步验证码错误")
+
+// totpOpts TOTP 校验参数
+func totpOpts() totp.ValidateOpts {
+	return totp.ValidateOpts{Period: totpPeriod, Skew: totpSkew, Digits: totpDigits, Algorithm: otp.AlgorithmSHA1}
+}
+
+// matchTOTP 校验验证码，返回匹配的时间片编号；时间片用于拒绝重复使用同一个验证码
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	for i := -totpSkew; i <= totpSkew; i++ {
+		t := now.Add(time.Duration(i*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totpOpts())
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeRecoveryCode 去掉恢复码中的分隔符和空格，并统一为小写
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// isTOTPCode 判断输入是否为 6 位数字验证码，否则按恢复码处理
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits.Length() {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// verifySecondFactor 校验两步验证码或恢复码，通过后立即作废，同一个验证码不能使用两次
+func verifySecondFactor(tx *gorm.DB, user *User, code string) error {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		result := tx.Model(&User{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	result := tx.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidSecondFactor
+	}
+	log.Printf("用户 %s 使用了恢复码登录", user.Username)
+	return nil
+}
+
+// generateRecoveryCodes 生成一组新的恢复码，旧的恢复码全部作废；返回的明文只展示这一次
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomToken(recoveryCodeLength / 2)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:recoveryCodeLength/2]+"-"+raw[recoveryCodeLength/2:])
+		records = append(records, RecoveryCode{UserID: userID, CodeHash: hashToken(raw)})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// roleRequiresMFA 判断角色是否被要求开启两步验证
+func roleRequiresMFA(tx *gorm.DB, role string) (bool, error) {
+	var policy RolePolicy
+	err := tx.Where("role = ?", role).Limit(1).Find(&policy).Error
+	return policy.RequireMFA, err
+}
+
+// respondWithTokens 签发 token 对并返回登录成功的响应
+// 角色要求两步验证但用户还没有开启时，在响应中提示客户端引导用户完成设置
+func respondWithTokens(c *gin.Context, user User) {
+	tokens, err := issueTokenPair(DB, user, "")
+	if err != nil {
+		abortWithError(c, internalError("auth.token_issue_failed", err))
+		return
+	}
+
+	resp := gin.H{
+		"message":       T(c, "auth.logged_in"),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	}
+	if user.TOTPEnabledAt == nil {
+		required, err := roleRequiresMFA(DB, user.Role)
+		if err != nil {
+			log.Printf("查询角色安全策略失败: %v", err)
+		}
+		if required {
+			resp["mfa_setup_required"] = true
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// respondMFAChallenge 密码正确但开启了两步验证，返回短时有效的 mfa_pending token，而不是正式的 JWT
+func respondMFAChallenge(c *gin.Context, user User) {
+	ttl := currentConfig().Auth.MFAPendingTTL.Duration
+	token, err := issueActionToken(DB, user, PurposeMFAPending, ttl)
+	if err != nil {
+		abortWithError(c, internalError("auth.token_issue_failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      T(c, "auth.mfa_required"),
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_in":   int64(ttl.Seconds()),
+	})
+}
+
+// LoginMFAHandler 登录第二步：提交 mfa_token 和验证码（或恢复码），通过后签发正式的 token
+func LoginMFAHandler(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required,max=32"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	errMFAToken := newAPIError(http.StatusUnauthorized, CodeMFATokenInvalid, "auth.mfa_token_invalid")
+	claims, err := parseActionToken(req.MFAToken, PurposeMFAPending)
+	if err != nil {
+		abortWithError(c, errMFAToken)
+		return
+	}
+
+	// 先把 token 标记为已使用再校验验证码：验证码错误时事务回滚，token 仍可重试
+	var user User
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := markActionTokenUsed(tx, claims); err != nil {
+			return err
+		}
+		if err := tx.First(&user, claims.UserID).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabledAt == nil {
+			return errActionTokenInvalid
+		}
+		return verifySecondFactor(tx, &user, req.Code)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, errInvalidSecondFactor):
+		// 记录失败次数，超过上限后 token 作废，需要重新输入密码
+		DB.Model(&ActionToken{}).Where("jti = ? AND used_at IS NULL", claims.ID).
+			Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1")})
+		DB.Model(&ActionToken{}).Where("jti = ? AND used_at IS NULL AND attempts >= ?", claims.ID, mfaMaxAttempts).
+			Update("used_at", time.Now())
+		abortWithError(c, newAPIError(http.StatusUnauthorized, CodeMFAInvalidCode, "auth.mfa_invalid_code"))
+		return
+	case errors.Is(err, errActionTokenInvalid), errors.Is(err, gorm.ErrRecordNotFound):
+		abortWithError(c, errMFAToken)
+		return
+	default:
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+
+	log.Printf("用户 %s 通过两步验证登录", user.Username)
+	respondWithTokens(c, user)
+}
+
+// currentUser 读取当前登录用户的完整记录
+func currentUser(c *gin.Context) (*User, error) {
+	userID, _ := c.Get("userID")
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetupTOTPHandler 生成新的 TOTP 密钥，返回 otpauth:// URI 和二维码 PNG（data URI）
+// 密钥在用 EnableTOTPHandler 提交验证码之前不会生效，重复调用会替换为新的密钥
+func SetupTOTPHandler(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		abortWithError(c, newAPIError(http.StatusConflict, CodeMFAAlreadyEnabled, "auth.mfa_already_enabled"))
+		return
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      currentConfig().Auth.MFAIssuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+		Digits:      totpDigits,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+
+	if err := DB.Model(user).Update("totp_secret", key.Secret()).Error; err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     T(c, "auth.mfa_setup"),
+		"secret":      key.Secret(),
+		"otpauth_uri": key.URL(),
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
+}
+
+// EnableTOTPHandler 提交身份验证器中的验证码确认开启两步验证，返回恢复码
+func EnableTOTPHandler(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required,len=6,numeric"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		abortWithError(c, newAPIError(http.StatusConflict, CodeMFAAlreadyEnabled, "auth.mfa_already_enabled"))
+		return
+	}
+	if user.TOTPSecret == "" {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeMFASetupRequired, "auth.mfa_setup_required"))
+		return
+	}
+	step, ok := matchTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeMFAInvalidCode, "auth.mfa_invalid_code"))
+		return
+	}
+
+	var codes []string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled_at": time.Now(),
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+
+	log.Printf("用户 %s 开启了两步验证", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.mfa_enabled"), "recovery_codes": codes})
+}
+
+// DisableTOTPHandler 关闭两步验证，需要同时提供密码和验证码（或恢复码）
+// 没有设置密码的钱包账号不需要密码，只凭验证码（或恢复码）确认身份
+func DisableTOTPHandler(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"max=72"`
+		Code     string `json:"code" binding:"required,max=32"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeMFANotEnabled, "auth.mfa_not_enabled"))
+		return
+	}
+	if accountLocked(*user, time.Now()) {
+		abortWithError(c, errAccountLocked(c, *user.LockedUntil))
+		return
+	}
+	if apiErr := confirmPassword(user, req.Password); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+	required, err := roleRequiresMFA(DB, user.Role)
+	if err != nil {
+		abortWithError(c, internalError("auth.mfa_failed", err))
+		return
+	}
+	if required {
+		abortWithError(c, newAPIError(http.StatusForbidden, CodeMFARequiredByRole, "auth.mfa_required_by_role"))
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := confirmSecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+	if err != nil {
+		respondProfileError(c, err, "auth.mfa_failed")
+		return
+	}
+
+	log.Printf("用户 %s 关闭了两步验证", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.mfa_disabled")})
+}
+
+// RegenerateRecoveryCodesHandler 重新生成恢复码，需要提供当前的验证码（或一个未使用的恢复码）
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required,max=32"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.TOTPEnabledAt == nil {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeMFANotEnabled, "auth.mfa_not_enabled"))
+		return
+	}
+
+	var codes []string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeMFAInvalidCode, "auth.mfa_invalid_code"))
+		} else {
+			abortWithError(c, internalError("auth.mfa_failed", err))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.recovery_codes_regenerated"), "recovery_codes": codes})
+}
+
+// RequireMFAEnrollment 角色要求两步验证而用户尚未开启时，拒绝访问受保护的接口，需要放在 AuthMiddleware 之后
+// 设置两步验证的接口在账号路由组中，不受影响
+func RequireMFAEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claimsVal, _ := c.Get("claims")
+		claims, ok := claimsVal.(*Claims)
+		if !ok {
+			abortWithError(c, errAuthRequired)
+			return
+		}
+		if claims.MFA {
+			c.Next()
+			return
+		}
+
+		required, err := roleRequiresMFA(DB, claims.Role)
+		if err != nil {
+			abortWithError(c, internalError("auth.permission_check_failed", err))
+			return
+		}
+		if required {
+			// 访问 token 可能是开启两步验证之前签发的，以数据库为准
+			var user User
+			if err := DB.Select("id", "totp_enabled_at").First(&user, claims.UserID).Error; err != nil {
+				abortWithError(c, toAPIError(err))
+				return
+			}
+			if user.TOTPEnabledAt == nil {
+				abortWithError(c, newAPIError(http.StatusForbidden, CodeMFAEnrollmentRequired, "auth.mfa_enrollment_required"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// GetRolePoliciesHandler 查看各角色的安全策略（仅管理员）
+func GetRolePoliciesHandler(c *gin.Context) {
+	var stored []RolePolicy
+	if err := DB.Find(&stored).Error; err != nil {
+		abortWithError(c, internalError("user.role_policy_list_failed", err))
+		return
+	}
+	byRole := make(map[string]RolePolicy, len(stored))
+	for _, p := range stored {
+		byRole[p.Role] = p
+	}
+
+	policies := make([]gin.H, 0, len(rolePermissions))
+	for _, role := range []string{RoleUser, RoleModerator, RoleAdmin} {
+		policies = append(policies, gin.H{"role": role, "require_mfa": byRole[role].RequireMFA})
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// UpdateRolePolicyHandler 修改角色的安全策略（仅管理员），例如要求管理员必须开启两步验证
+func UpdateRolePolicyHandler(c *gin.Context) {
+	role := c.Param("role")
+	if !validRole(role) {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidRole, "user.invalid_role"))
+		return
+	}
+	var req struct {
+		RequireMFA *bool `json:"require_mfa" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	policy := RolePolicy{Role: role, RequireMFA: *req.RequireMFA}
+	if err := DB.Save(&policy).Error; err != nil {
+		abortWithError(c, internalError("user.role_policy_update_failed", err))
+		return
+	}
+
+	log.Printf("角色 %s 的安全策略已更新: require_mfa=%v", role, policy.RequireMFA)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.role_policy_updated"), "role": role, "require_mfa": policy.RequireMFA})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// enableTOTP 通过接口为用户开启两步验证，返回密钥和恢复码
+func enableTOTP(t *testing.T, r http.Handler, token string) (string, []string) {
+	t.Helper()
+	w := doJSON(r, http.MethodPost, "/api/v1/mfa/totp/setup", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("生成两步验证密钥失败: %d %s", w.Code, w.Body.String())
+	}
+	secret := decodeJSON(t, w)["secret"].(string)
+	code, _ := totp.GenerateCodeCustom(secret, time.Now(), totpOpts())
+	w = doJSON(r, http.MethodPost, "/api/v1/mfa/totp/enable", token, map[string]string{"code": code})
+	if w.Code != http.StatusOK {
+		t.Fatalf("开启两步验证失败: %d %s", w.Code, w.Body.String())
+	}
+	var codes []string
+	for _, c := range decodeJSON(t, w)["recovery_codes"].([]interface{}) {
+		codes = append(codes, c.(string))
+	}
+	return secret, codes
+}
+
+// nextTOTPCode 返回下一个时间片的验证码；开启时已经用掉了当前时间片，同一个时间片不能重复使用
+func nextTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, time.Now().Add(totpPeriod*time.Second), totpOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// totpEnabled 查询数据库中用户是否开启了两步验证
+func totpEnabled(t *testing.T, userID uint) bool {
+	t.Helper()
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user.TOTPEnabledAt != nil
+}
+
+func TestDisableTOTP(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	secret, _ := enableTOTP(t, r, token)
+
+	disable := func(body map[string]string) int {
+		t.Helper()
+		return doJSON(r, http.MethodPost, "/api/v1/mfa/totp/disable", token, body).Code
+	}
+	if code := disable(map[string]string{"code": nextTOTPCode(t, secret)}); code != http.StatusUnauthorized {
+		t.Errorf("设置了密码的账号必须提供密码: %d", code)
+	}
+	if code := disable(map[string]string{"password": "Passw0rd!x", "code": "000000"}); code != http.StatusBadRequest {
+		t.Errorf("验证码错误应返回 400: %d", code)
+	}
+	if !totpEnabled(t, user.ID) {
+		t.Fatal("校验失败时不应关闭两步验证")
+	}
+	if code := disable(map[string]string{"password": "Passw0rd!x", "code": nextTOTPCode(t, secret)}); code != http.StatusOK {
+		t.Fatalf("关闭两步验证失败: %d", code)
+	}
+	var remaining int64
+	DB.Model(&RecoveryCode{}).Where("user_id = ?", user.ID).Count(&remaining)
+	if totpEnabled(t, user.ID) || remaining != 0 {
+		t.Errorf("关闭后应清除密钥和恢复码: enabled=%v recovery=%d", totpEnabled(t, user.ID), remaining)
+	}
+}
+
+func TestDisableTOTPCountsPasswordFailures(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) { cfg.Auth.LockoutThreshold = 2 })
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	secret, _ := enableTOTP(t, r, token)
+
+	for i := 0; i < 2; i++ {
+		w := doJSON(r, http.MethodPost, "/api/v1/mfa/totp/disable", token, map[string]string{"password": "wrong", "code": "000000"})
+		if w.Code != http.StatusUnauthorized || errorCode(t, w) != CodeInvalidCredentials {
+			t.Fatalf("密码错误应返回 401: %d %s", w.Code, w.Body.String())
+		}
+	}
+	DB.First(&user, user.ID)
+	if !accountLocked(user, time.Now()) {
+		t.Fatal("关闭两步验证时输错密码也应计入失败次数并锁定账号")
+	}
+	// 锁定期间即使密码正确也不能继续尝试
+	w := doJSON(r, http.MethodPost, "/api/v1/mfa/totp/disable", token, map[string]string{"password": "Passw0rd!x", "code": nextTOTPCode(t, secret)})
+	if w.Code != http.StatusLocked || errorCode(t, w) != CodeAccountLocked || !totpEnabled(t, user.ID) {
+		t.Errorf("账号锁定期间不应接受密码: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDisableTOTPWalletAccount(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "wallet", RoleUser)
+	DB.Model(&user).Updates(map[string]interface{}{"password": "", "wallet_address": "0x00000000000000000000000000000000000000aa"})
+	token := testToken(t, user)
+	_, recovery := enableTOTP(t, r, token)
+
+	// 没有密码的账号凭恢复码（或验证码）确认身份
+	w := doJSON(r, http.MethodPost, "/api/v1/mfa/totp/disable", token, map[string]string{"code": "aaaaa-bbbbb"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeMFAInvalidCode {
+		t.Errorf("恢复码错误应返回 400: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/mfa/totp/disable", token, map[string]string{"code": recovery[0]})
+	if w.Code != http.StatusOK || totpEnabled(t, user.ID) {
+		t.Errorf("钱包账号应可以用恢复码关闭两步验证: %d %s", w.Code, w.Body.String())
+	}
+}
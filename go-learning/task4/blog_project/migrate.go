@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次有编号的数据库结构变更
+// 每个迁移放在单独的 migration_NNNN_*.go 文件中，并在 migrations 中按顺序登记。
+// 迁移使用 GORM Migrator 而不是手写 SQL，这样同一份迁移可以同时用于 SQLite、PostgreSQL 和 MySQL。
+// 迁移中使用的模型是当时表结构的快照（mNNNN 前缀），不要引用会继续变化的业务模型。
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// migrations 所有迁移，必须按版本号递增排列；已经发布的迁移不要再修改
+var migrations = []Migration{
+	migration0001InitialSchema,
+	migration0002AuthTokens,
+	migration0003PostWorkflow,
+	migration0004CommentModeration,
+	migration0005UserLocale,
+	migration0006EmailVerification,
+	migration0007TOTP,
+	migration0008SIWE,
+	migration0009LoginLockout,
+	migration0010APIKeys,
+	migration0011UserProfile,
+	migration0012Attachments,
+	migration0013ContentFormat,
+	migration0014TokenVersion,
+}
+
+// SchemaMigration 记录已经执行过的迁移
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 迁移记录表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// ensureMigrationTable 创建 schema_migrations 表
+func ensureMigrationTable(db *gorm.DB) error {
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	return db.Migrator().CreateTable(&SchemaMigration{})
+}
+
+// appliedMigrations 返回已执行的迁移，按版本号索引
+func appliedMigrations(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	var records []SchemaMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// pendingMigrations 返回尚未执行的迁移
+func pendingMigrations(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// migrateUp 依次执行所有未执行的迁移，每个迁移在单独的事务中完成
+// 注意：MySQL 的 DDL 会隐式提交事务，迁移中途失败时可能需要手动清理
+func migrateUp(db *gorm.DB) (int, error) {
+	pending, err := pendingMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return i, fmt.Errorf("执行迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+		}
+		log.Printf("已执行迁移 %04d_%s", m.Version, m.Name)
+	}
+	return len(pending), nil
+}
+
+// migrateDown 按版本号从大到小回滚 steps 个已执行的迁移
+func migrateDown(db *gorm.DB, steps int) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	done := 0
+	for i := len(migrations) - 1; i >= 0 && done < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("回滚迁移 %04d_%s 失败: %w", m.Version, m.Name, err)
+		}
+		log.Printf("已回滚迁移 %04d_%s", m.Version, m.Name)
+		done++
+	}
+	return done, nil
+}
+
+// printMigrationStatus 输出每个迁移的执行情况
+func printMigrationStatus(db *gorm.DB, w io.Writer) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%-8s %-32s %s\n", "版本", "名称", "状态")
+	for _, m := range migrations {
+		status := "未执行"
+		if r, ok := applied[m.Version]; ok {
+			status = "已执行于 " + r.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d     %-32s %s\n", m.Version, m.Name, status)
+	}
+	return nil
+}
+
+// checkPendingMigrations 启动服务前检查迁移，存在未执行的迁移时拒绝启动
+func checkPendingMigrations(db *gorm.DB) error {
+	pending, err := pendingMigrations(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		last := pending[len(pending)-1]
+		return fmt.Errorf("数据库有 %d 个未执行的迁移（最新为 %04d_%s），请先运行 `blog_project migrate up`",
+			len(pending), last.Version, last.Name)
+	}
+	return nil
+}
+
+// parseSteps 解析 migrate down 的回滚数量，默认为 1
+func parseSteps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("无效的回滚数量: %s", args[0])
+	}
+	return n, nil
+}
+
+// createTables 创建尚不存在的表及其索引
+// 在引入迁移之前数据库由 AutoMigrate 维护，表可能已经存在，此时直接跳过
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if !tx.Migrator().HasTable(model) {
+			if err := tx.Migrator().CreateTable(model); err != nil {
+				return err
+			}
+		}
+		if err := ensureIndexes(tx, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns 为表添加尚不存在的列，并补齐模型中声明的索引
+func addColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return ensureIndexes(tx, model)
+}
+
+// dropColumns 删除列，model 为删除后的表结构快照
+// SQLite 删除列时会重建整张表，原有索引会丢失，所以最后按快照重新创建索引
+func dropColumns(tx *gorm.DB, model interface{}, columns ...string) error {
+	for _, column := range columns {
+		if !tx.Migrator().HasColumn(model, column) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(model, column); err != nil {
+			return err
+		}
+	}
+	return ensureIndexes(tx, model)
+}
+
+// ensureIndexes 创建模型中声明但数据库里还不存在的索引
+func ensureIndexes(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		if tx.Migrator().HasIndex(model, idx.Name) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(model, idx.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndexes 删除存在的索引
+func dropIndexes(tx *gorm.DB, model interface{}, names ...string) error {
+	for _, name := range names {
+		if !tx.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTables 删除存在的表
+func dropTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if err := tx.Migrator().DropTable(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
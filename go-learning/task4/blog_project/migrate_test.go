@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// assertNoPendingMigrations 检查没有未执行的迁移，并且 status 输出中每个迁移都已执行
+func assertNoPendingMigrations(t *testing.T) {
+	t.Helper()
+	pending, err := pendingMigrations(DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("仍有 %d 个未执行的迁移", len(pending))
+	}
+	if err := checkPendingMigrations(DB); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := printMigrationStatus(DB, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "未执行") {
+		t.Fatalf("status 中不应有未执行的迁移:\n%s", out.String())
+	}
+	if lines := strings.Count(out.String(), "已执行于"); lines != len(migrations) {
+		t.Fatalf("status 应列出 %d 个已执行的迁移，实际 %d 个:\n%s", len(migrations), lines, out.String())
+	}
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	setupTestApp(t)
+	assertNoPendingMigrations(t)
+
+	n, err := migrateDown(DB, len(migrations))
+	if err != nil {
+		t.Fatalf("回滚全部迁移失败: %v", err)
+	}
+	if n != len(migrations) {
+		t.Fatalf("应回滚 %d 个迁移，实际 %d 个", len(migrations), n)
+	}
+	for _, table := range []string{"users", "posts", "comments", "refresh_tokens", "attachments"} {
+		if DB.Migrator().HasTable(table) {
+			t.Errorf("回滚后表 %s 仍然存在", table)
+		}
+	}
+	if err := checkPendingMigrations(DB); err == nil {
+		t.Fatal("回滚后启动检查应报告未执行的迁移")
+	}
+
+	if n, err = migrateUp(DB); err != nil {
+		t.Fatalf("重新执行迁移失败: %v", err)
+	}
+	if n != len(migrations) {
+		t.Fatalf("应执行 %d 个迁移，实际 %d 个", len(migrations), n)
+	}
+	assertNoPendingMigrations(t)
+	if n, err = migrateUp(DB); err != nil || n != 0 {
+		t.Fatalf("没有未执行的迁移时 up 不应做任何事: %d %v", n, err)
+	}
+}
+
+// 逐个回滚再重新执行（migrate redo），每个迁移的 Down 都必须能单独撤销它的 Up
+func TestMigrateRedoEachStep(t *testing.T) {
+	setupTestApp(t)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, err := migrateDown(DB, len(migrations)-i); err != nil {
+			t.Fatalf("回滚到 %04d 之前失败: %v", migrations[i].Version, err)
+		}
+		pending, err := pendingMigrations(DB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != len(migrations)-i || pending[0].Version != migrations[i].Version {
+			t.Fatalf("回滚后未执行的迁移应从 %04d 开始: %v", migrations[i].Version, pending)
+		}
+		if _, err := migrateUp(DB); err != nil {
+			t.Fatalf("重新执行 %04d 失败: %v", migrations[i].Version, err)
+		}
+	}
+	assertNoPendingMigrations(t)
+}
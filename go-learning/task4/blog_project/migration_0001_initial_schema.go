@@ -0,0 +1,47 @@
+package main
+
+import "gorm.io/gorm"
+
+// 0001 最初的用户、文章、评论三张表
+// 引入迁移之前已经由 AutoMigrate 创建的数据库会直接跳过建表
+
+type m0001User struct {
+	gorm.Model
+	Username string         `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password string         `gorm:"type:varchar(255);not null"`
+	Email    string         `gorm:"type:varchar(100);uniqueIndex"`
+	Posts    []m0001Post    `gorm:"foreignKey:UserID"`
+	Comments []m0001Comment `gorm:"foreignKey:UserID"`
+}
+
+func (m0001User) TableName() string { return "users" }
+
+type m0001Post struct {
+	gorm.Model
+	Title    string         `gorm:"type:varchar(255);not null"`
+	Content  string         `gorm:"type:text;not null"`
+	UserID   uint           `gorm:"not null"`
+	Comments []m0001Comment `gorm:"foreignKey:PostID"`
+}
+
+func (m0001Post) TableName() string { return "posts" }
+
+type m0001Comment struct {
+	gorm.Model
+	Content string `gorm:"type:text;not null"`
+	UserID  uint   `gorm:"not null"`
+	PostID  uint   `gorm:"not null"`
+}
+
+func (m0001Comment) TableName() string { return "comments" }
+
+var migration0001InitialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &m0001User{}, &m0001Post{}, &m0001Comment{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &m0001Comment{}, &m0001Post{}, &m0001User{})
+	},
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0002 用户角色、刷新 token 和访问 token 吊销列表
+
+type m0002User struct {
+	gorm.Model
+	Username string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password string `gorm:"type:varchar(255);not null"`
+	Email    string `gorm:"type:varchar(100);uniqueIndex"`
+	Role     string `gorm:"type:varchar(20);not null;default:user"`
+}
+
+func (m0002User) TableName() string { return "users" }
+
+type m0002RefreshToken struct {
+	gorm.Model
+	UserID     uint      `gorm:"not null;index"`
+	TokenHash  string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	FamilyID   string    `gorm:"type:varchar(64);index;not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	RevokedAt  *time.Time
+	ReplacedBy string `gorm:"type:varchar(64)"`
+}
+
+func (m0002RefreshToken) TableName() string { return "refresh_tokens" }
+
+type m0002RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (m0002RevokedToken) TableName() string { return "revoked_tokens" }
+
+var migration0002AuthTokens = Migration{
+	Version: 2,
+	Name:    "auth_tokens",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0002User{}, "Role"); err != nil {
+			return err
+		}
+		return createTables(tx, &m0002RefreshToken{}, &m0002RevokedToken{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropTables(tx, &m0002RevokedToken{}, &m0002RefreshToken{}); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0001User{}, "role")
+	},
+}
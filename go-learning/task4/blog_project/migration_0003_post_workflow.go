@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0003 文章状态与定时发布、修订历史、标签和分类
+// 已有的文章默认视为已发布
+
+type m0003Post struct {
+	gorm.Model
+	Title                string     `gorm:"type:varchar(255);not null"`
+	Content              string     `gorm:"type:text;not null"`
+	Status               string     `gorm:"type:varchar(20);not null;default:published;index"`
+	PublishAt            *time.Time `gorm:"index"`
+	UserID               uint       `gorm:"not null"`
+	CommentsNeedApproval bool       `gorm:"not null;default:false"`
+}
+
+func (m0003Post) TableName() string { return "posts" }
+
+type m0003PostRevision struct {
+	gorm.Model
+	PostID   uint   `gorm:"not null;uniqueIndex:idx_post_version"`
+	Version  int    `gorm:"not null;uniqueIndex:idx_post_version"`
+	Title    string `gorm:"type:varchar(255);not null"`
+	Content  string `gorm:"type:text;not null"`
+	EditorID uint   `gorm:"not null"`
+}
+
+func (m0003PostRevision) TableName() string { return "post_revisions" }
+
+type m0003Tag struct {
+	gorm.Model
+	Name string `gorm:"type:varchar(50);uniqueIndex;not null"`
+}
+
+func (m0003Tag) TableName() string { return "tags" }
+
+type m0003Category struct {
+	gorm.Model
+	Name string `gorm:"type:varchar(50);uniqueIndex;not null"`
+}
+
+func (m0003Category) TableName() string { return "categories" }
+
+type m0003PostTag struct {
+	PostID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID  uint `gorm:"primaryKey;autoIncrement:false"`
+	Post   m0003Post
+	Tag    m0003Tag
+}
+
+func (m0003PostTag) TableName() string { return "post_tags" }
+
+type m0003PostCategory struct {
+	PostID     uint `gorm:"primaryKey;autoIncrement:false"`
+	CategoryID uint `gorm:"primaryKey;autoIncrement:false"`
+	Post       m0003Post
+	Category   m0003Category
+}
+
+func (m0003PostCategory) TableName() string { return "post_categories" }
+
+var migration0003PostWorkflow = Migration{
+	Version: 3,
+	Name:    "post_workflow",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0003Post{}, "Status", "PublishAt", "CommentsNeedApproval"); err != nil {
+			return err
+		}
+		return createTables(tx, &m0003PostRevision{}, &m0003Tag{}, &m0003Category{}, &m0003PostTag{}, &m0003PostCategory{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropTables(tx, &m0003PostCategory{}, &m0003PostTag{}, &m0003Category{}, &m0003Tag{}, &m0003PostRevision{}); err != nil {
+			return err
+		}
+		if err := dropIndexes(tx, &m0003Post{}, "idx_posts_status", "idx_posts_publish_at"); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0001Post{}, "status", "publish_at", "comments_need_approval")
+	},
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0004 评论审核状态和楼中楼回复
+// 早期版本用 hidden 布尔列隐藏评论，这里把它转换为 hidden 状态后删除
+
+type m0004Comment struct {
+	gorm.Model
+	Content     string `gorm:"type:text;not null"`
+	UserID      uint   `gorm:"not null"`
+	PostID      uint   `gorm:"not null"`
+	Status      string `gorm:"type:varchar(20);not null;default:approved;index"`
+	ModeratedBy *uint
+	ModeratedAt *time.Time
+	ParentID    *uint `gorm:"index"`
+	Depth       int   `gorm:"not null;default:0"`
+}
+
+func (m0004Comment) TableName() string { return "comments" }
+
+var migration0004CommentModeration = Migration{
+	Version: 4,
+	Name:    "comment_moderation",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0004Comment{}, "Status", "ModeratedBy", "ModeratedAt", "ParentID", "Depth"); err != nil {
+			return err
+		}
+		if !tx.Migrator().HasColumn(&m0004Comment{}, "hidden") {
+			return nil
+		}
+		if err := tx.Exec("UPDATE comments SET status = ? WHERE hidden = ?", CommentStatusHidden, true).Error; err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0004Comment{}, "hidden")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndexes(tx, &m0004Comment{}, "idx_comments_status", "idx_comments_parent_id"); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0001Comment{}, "status", "moderated_by", "moderated_at", "parent_id", "depth")
+	},
+}
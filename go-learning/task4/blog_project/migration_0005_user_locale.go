@@ -0,0 +1,27 @@
+package main
+
+import "gorm.io/gorm"
+
+// 0005 用户语言偏好，为空时按 Accept-Language 协商
+
+type m0005User struct {
+	gorm.Model
+	Username string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password string `gorm:"type:varchar(255);not null"`
+	Email    string `gorm:"type:varchar(100);uniqueIndex"`
+	Role     string `gorm:"type:varchar(20);not null;default:user"`
+	Locale   string `gorm:"type:varchar(10)"`
+}
+
+func (m0005User) TableName() string { return "users" }
+
+var migration0005UserLocale = Migration{
+	Version: 5,
+	Name:    "user_locale",
+	Up: func(tx *gorm.DB) error {
+		return addColumns(tx, &m0005User{}, "Locale")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &m0002User{}, "locale")
+	},
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0006 邮箱验证和重置密码
+// 已有账号视为已验证（验证时间取注册时间），避免升级后老用户被限制为只读
+
+type m0006User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+}
+
+func (m0006User) TableName() string { return "users" }
+
+type m0006ActionToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"type:varchar(30);not null"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (m0006ActionToken) TableName() string { return "action_tokens" }
+
+var migration0006EmailVerification = Migration{
+	Version: 6,
+	Name:    "email_verification",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0006User{}, "EmailVerifiedAt"); err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			return err
+		}
+		return createTables(tx, &m0006ActionToken{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropTables(tx, &m0006ActionToken{}); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0005User{}, "email_verified_at")
+	},
+}
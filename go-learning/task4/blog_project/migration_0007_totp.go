@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0007 TOTP 两步验证、恢复码和按角色的安全策略
+// action_tokens 增加失败次数，登录时的两步验证 token 多次输错后作废
+
+type m0007User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastStep    int64 `gorm:"not null;default:0"`
+}
+
+func (m0007User) TableName() string { return "users" }
+
+type m0007RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (m0007RecoveryCode) TableName() string { return "recovery_codes" }
+
+type m0007ActionToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"type:varchar(30);not null"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	Attempts  int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+}
+
+func (m0007ActionToken) TableName() string { return "action_tokens" }
+
+type m0007RolePolicy struct {
+	Role       string `gorm:"type:varchar(20);primaryKey"`
+	RequireMFA bool   `gorm:"not null;default:false"`
+	UpdatedAt  time.Time
+}
+
+func (m0007RolePolicy) TableName() string { return "role_policies" }
+
+var migration0007TOTP = Migration{
+	Version: 7,
+	Name:    "totp",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0007User{}, "TOTPSecret", "TOTPEnabledAt", "TOTPLastStep"); err != nil {
+			return err
+		}
+		if err := addColumns(tx, &m0007ActionToken{}, "Attempts"); err != nil {
+			return err
+		}
+		return createTables(tx, &m0007RecoveryCode{}, &m0007RolePolicy{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropTables(tx, &m0007RecoveryCode{}, &m0007RolePolicy{}); err != nil {
+			return err
+		}
+		if err := dropColumns(tx, &m0006ActionToken{}, "attempts"); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0006User{}, "totp_secret", "totp_enabled_at", "totp_last_step")
+	},
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0008 Sign-In with Ethereum：用户绑定钱包地址，签发的 nonce 单独建表
+
+type m0008User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastStep    int64   `gorm:"not null;default:0"`
+	WalletAddress   *string `gorm:"type:varchar(42);uniqueIndex"`
+}
+
+func (m0008User) TableName() string { return "users" }
+
+type m0008SIWENonce struct {
+	ID        uint      `gorm:"primarykey"`
+	Nonce     string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (m0008SIWENonce) TableName() string { return "siwe_nonces" }
+
+var migration0008SIWE = Migration{
+	Version: 8,
+	Name:    "siwe",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0008User{}, "WalletAddress"); err != nil {
+			return err
+		}
+		return createTables(tx, &m0008SIWENonce{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropTables(tx, &m0008SIWENonce{}); err != nil {
+			return err
+		}
+		if err := dropIndexes(tx, &m0008User{}, "idx_users_wallet_address"); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0007User{}, "wallet_address")
+	},
+}
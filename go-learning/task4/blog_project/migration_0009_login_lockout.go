@@ -0,0 +1,39 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0009 记录连续登录失败次数，失败过多时临时锁定账号
+
+type m0009User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastStep    int64   `gorm:"not null;default:0"`
+	WalletAddress   *string `gorm:"type:varchar(42);uniqueIndex"`
+	FailedLogins    int     `gorm:"not null;default:0"`
+	LastFailedLogin *time.Time
+	LockedUntil     *time.Time
+}
+
+func (m0009User) TableName() string { return "users" }
+
+var migration0009LoginLockout = Migration{
+	Version: 9,
+	Name:    "login_lockout",
+	Up: func(tx *gorm.DB) error {
+		return addColumns(tx, &m0009User{}, "FailedLogins", "LastFailedLogin", "LockedUntil")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &m0008User{}, "failed_logins", "last_failed_login", "locked_until")
+	},
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0010 用户创建的 API Key
+
+type m0010APIKey struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(100);not null"`
+	Prefix     string `gorm:"type:varchar(20);not null"`
+	KeyHash    string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     string `gorm:"type:varchar(255);not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (m0010APIKey) TableName() string { return "api_keys" }
+
+var migration0010APIKeys = Migration{
+	Version: 10,
+	Name:    "api_keys",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &m0010APIKey{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &m0010APIKey{})
+	},
+}
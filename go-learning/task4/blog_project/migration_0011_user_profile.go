@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0011 用户资料：昵称、个人简介、头像、个人网站
+
+type m0011User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastStep    int64   `gorm:"not null;default:0"`
+	WalletAddress   *string `gorm:"type:varchar(42);uniqueIndex"`
+	FailedLogins    int     `gorm:"not null;default:0"`
+	LastFailedLogin *time.Time
+	LockedUntil     *time.Time
+	DisplayName     string `gorm:"type:varchar(100)"`
+	Bio             string `gorm:"type:varchar(500)"`
+	AvatarURL       string `gorm:"type:varchar(500)"`
+	Website         string `gorm:"type:varchar(255)"`
+}
+
+func (m0011User) TableName() string { return "users" }
+
+var migration0011UserProfile = Migration{
+	Version: 11,
+	Name:    "user_profile",
+	Up: func(tx *gorm.DB) error {
+		return addColumns(tx, &m0011User{}, "DisplayName", "Bio", "AvatarURL", "Website")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &m0009User{}, "display_name", "bio", "avatar_url", "website")
+	},
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0012 文章附件
+
+type m0012Attachment struct {
+	ID           uint   `gorm:"primarykey"`
+	PostID       uint   `gorm:"not null;index"`
+	UserID       uint   `gorm:"not null;index"`
+	Filename     string `gorm:"type:varchar(255);not null"`
+	ContentType  string `gorm:"type:varchar(100);not null"`
+	Size         int64  `gorm:"not null"`
+	Width        int
+	Height       int
+	StorageKey   string `gorm:"type:varchar(255);uniqueIndex;not null"`
+	ThumbnailKey string `gorm:"type:varchar(255)"`
+	CreatedAt    time.Time
+}
+
+func (m0012Attachment) TableName() string { return "attachments" }
+
+var migration0012Attachments = Migration{
+	Version: 12,
+	Name:    "attachments",
+	Up: func(tx *gorm.DB) error {
+		return createTables(tx, &m0012Attachment{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropTables(tx, &m0012Attachment{})
+	},
+}
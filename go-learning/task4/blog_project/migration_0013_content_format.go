@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0013 文章内容格式：plain / markdown / html，已有文章和修订版本按纯文本处理
+
+type m0013Post struct {
+	gorm.Model
+	Title                string     `gorm:"type:varchar(255);not null"`
+	Content              string     `gorm:"type:text;not null"`
+	ContentFormat        string     `gorm:"type:varchar(20);not null;default:plain"`
+	Status               string     `gorm:"type:varchar(20);not null;default:published;index"`
+	PublishAt            *time.Time `gorm:"index"`
+	UserID               uint       `gorm:"not null"`
+	CommentsNeedApproval bool       `gorm:"not null;default:false"`
+}
+
+func (m0013Post) TableName() string { return "posts" }
+
+type m0013PostRevision struct {
+	gorm.Model
+	PostID        uint   `gorm:"not null;uniqueIndex:idx_post_version"`
+	Version       int    `gorm:"not null;uniqueIndex:idx_post_version"`
+	Title         string `gorm:"type:varchar(255);not null"`
+	Content       string `gorm:"type:text;not null"`
+	ContentFormat string `gorm:"type:varchar(20);not null;default:plain"`
+	EditorID      uint   `gorm:"not null"`
+}
+
+func (m0013PostRevision) TableName() string { return "post_revisions" }
+
+var migration0013ContentFormat = Migration{
+	Version: 13,
+	Name:    "content_format",
+	Up: func(tx *gorm.DB) error {
+		if err := addColumns(tx, &m0013Post{}, "ContentFormat"); err != nil {
+			return err
+		}
+		return addColumns(tx, &m0013PostRevision{}, "ContentFormat")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumns(tx, &m0003PostRevision{}, "content_format"); err != nil {
+			return err
+		}
+		return dropColumns(tx, &m0003Post{}, "content_format")
+	},
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0014 访问 token 版本：修改、重置密码或注销账号时加一，之前签发的访问 token 全部失效
+
+type m0014User struct {
+	gorm.Model
+	Username        string `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string `gorm:"type:varchar(255);not null"`
+	Email           string `gorm:"type:varchar(100);uniqueIndex"`
+	Role            string `gorm:"type:varchar(20);not null;default:user"`
+	Locale          string `gorm:"type:varchar(10)"`
+	EmailVerifiedAt *time.Time
+	TOTPSecret      string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt   *time.Time
+	TOTPLastStep    int64   `gorm:"not null;default:0"`
+	WalletAddress   *string `gorm:"type:varchar(42);uniqueIndex"`
+	FailedLogins    int     `gorm:"not null;default:0"`
+	LastFailedLogin *time.Time
+	LockedUntil     *time.Time
+	DisplayName     string `gorm:"type:varchar(100)"`
+	Bio             string `gorm:"type:varchar(500)"`
+	AvatarURL       string `gorm:"type:varchar(500)"`
+	Website         string `gorm:"type:varchar(255)"`
+	TokenVersion    int    `gorm:"not null;default:0"`
+}
+
+func (m0014User) TableName() string { return "users" }
+
+var migration0014TokenVersion = Migration{
+	Version: 14,
+	Name:    "token_version",
+	Up: func(tx *gorm.DB) error {
+		return addColumns(tx, &m0014User{}, "TokenVersion")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumns(tx, &m0011User{}, "token_version")
+	},
+}
@@ -1,42 +1,186 @@
 package main // 或者 package models，如果你想创建一个单独的包
 
 import (
-	"time"
 	"gorm.io/gorm"
+	"time"
 )
 
-// User 用户模型
+// User 用户模型；密码、邮箱、两步验证等敏感字段不参与 JSON 序列化，文章详情中预加载的作者信息不会泄露它们
 type User struct {
-	gorm.Model        // 内嵌 gorm.Model，包含 ID, CreatedAt, UpdatedAt, DeletedAt 字段
-	Username   string `gorm:"type:varchar(100);uniqueIndex;not null"`
-	Password   string `gorm:"type:varchar(255);not null"` // 实际项目中密码应该被哈希存储
-	Email      string `gorm:"type:varchar(100);uniqueIndex"`
-	Posts      []Post      `gorm:"foreignKey:UserID"` // 一个用户可以有多篇文章
-	Comments   []Comment   `gorm:"foreignKey:UserID"` // 一个用户可以有多条评论
+	gorm.Model                 // 内嵌 gorm.Model，包含 ID, CreatedAt, UpdatedAt, DeletedAt 字段
+	Username        string     `gorm:"type:varchar(100);uniqueIndex;not null"`
+	Password        string     `gorm:"type:varchar(255);not null" json:"-"` // 实际项目中密码应该被哈希存储
+	Email           string     `gorm:"type:varchar(100);uniqueIndex" json:"-"`
+	Role            string     `gorm:"type:varchar(20);not null;default:user"` // 用户角色：user / moderator / admin
+	Locale          string     `gorm:"type:varchar(10)" json:"-"`              // 语言偏好：zh-CN / en-US，为空时按 Accept-Language
+	EmailVerifiedAt *time.Time `json:"-"`                                      // 邮箱验证时间，为空表示未验证，未验证的账号只能浏览
+	TOTPSecret      string     `gorm:"type:varchar(64)" json:"-"`              // 两步验证密钥（Base32），开启前为待确认的密钥
+	TOTPEnabledAt   *time.Time `json:"-"`                                      // 开启两步验证的时间，为空表示未开启
+	TOTPLastStep    int64      `gorm:"not null;default:0" json:"-"`            // 最近一次使用的验证码时间片，防止同一个验证码被重复使用
+	WalletAddress   *string    `gorm:"type:varchar(42);uniqueIndex"`           // 绑定的以太坊钱包地址（EIP-55 校验和格式），用于 Sign-In with Ethereum
+	FailedLogins    int        `gorm:"not null;default:0" json:"-"`            // 时间窗口内连续输错密码的次数
+	LastFailedLogin *time.Time `json:"-"`                                      // 最近一次输错密码的时间
+	LockedUntil     *time.Time `json:"-"`                                      // 账号锁定到什么时候，为空或已过去表示未锁定
+	DisplayName     string     `gorm:"type:varchar(100)"`                      // 昵称，为空时显示用户名
+	Bio             string     `gorm:"type:varchar(500)"`                      // 个人简介
+	AvatarURL       string     `gorm:"type:varchar(500)"`                      // 头像地址
+	Website         string     `gorm:"type:varchar(255)"`                      // 个人网站
+	TokenVersion    int        `gorm:"not null;default:0" json:"-"`            // 访问 token 的版本，与 token 中的不一致时 token 失效
+	Posts           []Post     `gorm:"foreignKey:UserID"`                      // 一个用户可以有多篇文章
+	Comments        []Comment  `gorm:"foreignKey:UserID"`                      // 一个用户可以有多条评论
 }
 
 // Post 博客文章模型
 type Post struct {
-	gorm.Model        // 内嵌 gorm.Model
-	Title      string `gorm:"type:varchar(255);not null"`
-	Content    string `gorm:"type:text;not null"`
-	UserID     uint   `gorm:"not null"` // 外键，关联 User 的 ID
-	User       User   // 属于某个用户 (Belongs To 关系)
-	Comments   []Comment `gorm:"foreignKey:PostID"` // 一篇文章可以有多条评论
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	gorm.Model                        // 内嵌 gorm.Model
+	Title                string       `gorm:"type:varchar(255);not null"`
+	Content              string       `gorm:"type:text;not null"`
+	ContentFormat        string       `gorm:"type:varchar(20);not null;default:plain"`           // 内容格式：plain / markdown / html
+	ContentHTML          string       `gorm:"-" json:"content_html,omitempty"`                   // 渲染并过滤后的 HTML，只在文章详情中返回
+	TOC                  []TOCEntry   `gorm:"-" json:"toc,omitempty"`                            // 由标题生成的目录
+	Status               string       `gorm:"type:varchar(20);not null;default:published;index"` // 文章状态：draft / scheduled / published / archived
+	PublishAt            *time.Time   `gorm:"index"`                                             // 发布时间，定时发布时为计划发布时间
+	UserID               uint         `gorm:"not null"`                                          // 外键，关联 User 的 ID
+	User                 User         // 属于某个用户 (Belongs To 关系)
+	Comments             []Comment    `gorm:"foreignKey:PostID"`          // 一篇文章可以有多条评论
+	CommentsNeedApproval bool         `gorm:"not null;default:false"`     // 新评论是否需要审核后才公开
+	Tags                 []Tag        `gorm:"many2many:post_tags;"`       // 文章的标签 (Many To Many 关系)
+	Categories           []Category   `gorm:"many2many:post_categories;"` // 文章的分类 (Many To Many 关系)
+	Attachments          []Attachment `gorm:"foreignKey:PostID"`          // 文章的图片和文件附件
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
 }
 
 // Comment 评论模型
 type Comment struct {
-	gorm.Model        // 内嵌 gorm.Model
-	Content    string `gorm:"type:text;not null"`
-	UserID     uint   `gorm:"not null"` // 外键，关联 User 的 ID
-	User       User   // 属于某个用户 (Belongs To 关系)
-	PostID     uint   `gorm:"not null"` // 外键，关联 Post 的 ID
-	Post       Post   // 属于某篇文章 (Belongs To 关系)
+	gorm.Model         // 内嵌 gorm.Model
+	Content     string `gorm:"type:text;not null"`
+	UserID      uint   `gorm:"not null"` // 外键，关联 User 的 ID
+	User        User   // 属于某个用户 (Belongs To 关系)
+	PostID      uint   `gorm:"not null"` // 外键，关联 Post 的 ID
+	Post        Post   // 属于某篇文章 (Belongs To 关系)
+	Status      string `gorm:"type:varchar(20);not null;default:approved;index"` // 审核状态：pending / approved / rejected / hidden
+	ModeratedBy *uint  // 最后处理该评论的版主
+	ModeratedAt *time.Time
+	ParentID    *uint `gorm:"index"`              // 回复的父评论 ID，顶层评论为空
+	Depth       int   `gorm:"not null;default:0"` // 嵌套深度，顶层评论为 0
+	CreatedAt   time.Time
+}
+
+// Tag 标签模型
+type Tag struct {
+	gorm.Model
+	Name  string `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Posts []Post `gorm:"many2many:post_tags;" json:"-"`
+}
+
+// Category 分类模型
+type Category struct {
+	gorm.Model
+	Name  string `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Posts []Post `gorm:"many2many:post_categories;" json:"-"`
+}
+
+// PostRevision 文章修订记录，每次更新文章都会保存一个新版本
+type PostRevision struct {
+	gorm.Model
+	PostID        uint   `gorm:"not null;uniqueIndex:idx_post_version"`
+	Version       int    `gorm:"not null;uniqueIndex:idx_post_version"` // 从 1 开始递增的版本号
+	Title         string `gorm:"type:varchar(255);not null"`
+	Content       string `gorm:"type:text;not null"`
+	ContentFormat string `gorm:"type:varchar(20);not null;default:plain"`
+	EditorID      uint   `gorm:"not null"` // 编辑者的用户 ID
+}
+
+// RefreshToken 刷新 token 模型，只保存 token 的哈希值
+// 同一次登录轮换出来的 token 共享一个 FamilyID，用于检测重放
+type RefreshToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"not null;index"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	FamilyID   string     `gorm:"type:varchar(64);index;not null"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	RevokedAt  *time.Time // 被轮换或吊销的时间，为空表示仍然有效
+	ReplacedBy string     `gorm:"type:varchar(64)"` // 轮换后新 token 的哈希
+}
+
+// RevokedToken 已吊销的访问 token（按 jti 记录），过期后可以清理
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// ActionToken 邮箱验证、重置密码等一次性 token 的记录
+// token 本身是签名过的 JWT，这里按 jti 记录使用状态，保证每个 token 只能使用一次
+type ActionToken struct {
+	ID        uint       `gorm:"primarykey"`
+	UserID    uint       `gorm:"not null;index"`
+	Purpose   string     `gorm:"type:varchar(30);not null"` // verify_email / reset_password / mfa_pending
+	JTI       string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time // 使用或作废的时间
+	Attempts  int        `gorm:"not null;default:0"` // 验证失败次数，用于限制两步验证码的尝试次数
+	CreatedAt time.Time
+}
+
+// RecoveryCode 两步验证的恢复码，只保存哈希值，每个恢复码只能使用一次
+type RecoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RolePolicy 按角色配置的安全策略，由管理员修改
+type RolePolicy struct {
+	Role       string `gorm:"type:varchar(20);primaryKey"`
+	RequireMFA bool   `gorm:"not null;default:false"` // 该角色的用户必须开启两步验证
+	UpdatedAt  time.Time
+}
+
+// SIWENonce Sign-In with Ethereum 的一次性随机数，签名消息中的 Nonce 必须是服务端签发且未使用的
+type SIWENonce struct {
+	ID        uint      `gorm:"primarykey"`
+	Nonce     string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// APIKey 用户创建的 API Key，只保存哈希值，明文只在创建时返回一次
+type APIKey struct {
+	ID         uint       `gorm:"primarykey"`
+	UserID     uint       `gorm:"not null;index"`
+	Name       string     `gorm:"type:varchar(100);not null"`
+	Prefix     string     `gorm:"type:varchar(20);not null"` // key 的开头部分，用于在列表中识别
+	KeyHash    string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     string     `gorm:"type:varchar(255);not null"` // 权限范围，以空格分隔
+	ExpiresAt  *time.Time // 过期时间，为空表示永不过期
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
 	CreatedAt  time.Time
 }
 
+// Attachment 文章的附件（图片或文件），文件本身保存在 Storage 中
+// ContentType 按文件内容识别，不采信客户端声明的类型；URL 和 ThumbnailURL 在查询后填充
+type Attachment struct {
+	ID           uint   `gorm:"primarykey"`
+	PostID       uint   `gorm:"not null;index"`
+	UserID       uint   `gorm:"not null;index"`             // 上传者
+	Filename     string `gorm:"type:varchar(255);not null"` // 上传时的原始文件名，只用于展示和下载
+	ContentType  string `gorm:"type:varchar(100);not null"`
+	Size         int64  `gorm:"not null"`
+	Width        int    // 图片的宽高，不是图片时为 0
+	Height       int
+	StorageKey   string `gorm:"type:varchar(255);uniqueIndex;not null" json:"-"`
+	ThumbnailKey string `gorm:"type:varchar(255)" json:"-"` // 缩略图的存储 key，没有缩略图时为空
+	URL          string `gorm:"-"`
+	ThumbnailURL string `gorm:"-"`
+	CreatedAt    time.Time
+}
+
 // 如果你将模型放在单独的 models 包中，上面的 package main 需要改为 package models
-// 并且在其他地方引用时需要 import "your_blog_project/models"
\ No newline at end of file
+// 并且在其他地方引用时需要 import "your_blog_project/models"
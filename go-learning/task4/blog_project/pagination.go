@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidCursor   = newAPIError(http.StatusBadRequest, CodeInvalidCursor, "pagination.invalid_cursor")
+	errInvalidPageSize = newAPIError(http.StatusBadRequest, CodeInvalidPageSize, "pagination.invalid_page_size")
+	errPageUnsupported = newAPIError(http.StatusBadRequest, CodePageUnsupported, "pagination.page_unsupported")
+)
+
+// Cursor 分页游标，按 (created_at, id) 定位一条记录
+// 对客户端来说是不透明的字符串
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+	Backward  bool      `json:"b,omitempty"` // true 表示向前翻页（prev_cursor）
+}
+
+// encode 把游标编码为 URL 安全的字符串
+func (cur Cursor) encode() string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor 解析客户端传回的游标
+func decodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cur Cursor
+	if err := json.Unmarshal(b, &cur); err != nil || cur.ID == 0 {
+		return nil, errInvalidCursor
+	}
+	return &cur, nil
+}
+
+// PageRequest 从查询参数中解析出的分页请求
+type PageRequest struct {
+	Limit     int
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+// PageInfo 分页信息，随列表一起返回
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"` // 仅在 ?with_total=true 时返回
+}
+
+// parsePageRequest 解析 ?limit=&cursor=&with_total= 参数，page_size 作为 limit 的别名
+// 旧的 ?page= 页码参数直接返回 400，避免客户端一直拿到第一页而不自知
+func parsePageRequest(c *gin.Context, defaultLimit int) (PageRequest, error) {
+	req := PageRequest{Limit: defaultLimit}
+	if _, ok := c.GetQuery("page"); ok {
+		return req, errPageUnsupported
+	}
+
+	limitStr := c.Query("limit")
+	if limitStr == "" {
+		limitStr = c.Query("page_size")
+	}
+	if limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return req, errInvalidPageSize
+		}
+		req.Limit = limit
+	}
+	// 超过 pagination.max_page_size 时按最大值处理
+	if maxSize := currentConfig().Pagination.MaxPageSize; req.Limit > maxSize {
+		req.Limit = maxSize
+	}
+
+	if s := c.Query("cursor"); s != "" {
+		cur, err := decodeCursor(s)
+		if err != nil {
+			return req, err
+		}
+		req.Cursor = cur
+	}
+
+	req.WithTotal, _ = strconv.ParseBool(c.Query("with_total"))
+	return req, nil
+}
+
+// paginate 对 query 做基于 (created_at, id) 的游标分页
+// table 用于限定列名，desc 为 true 时按时间倒序（最新的在前）；keyOf 返回一条记录的游标位置
+func paginate[T any](query *gorm.DB, req PageRequest, table string, desc bool, keyOf func(T) Cursor) ([]T, PageInfo, error) {
+	info := PageInfo{Limit: req.Limit}
+
+	if req.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, info, err
+		}
+		info.Total = &total
+	}
+
+	backward := req.Cursor != nil && req.Cursor.Backward
+	// 实际查询方向：向前翻页时反向查询，取到结果后再翻转回来
+	scanDesc := desc != backward
+
+	createdAt, id := table+".created_at", table+".id"
+	q := query.Session(&gorm.Session{})
+	if req.Cursor != nil {
+		op := ">"
+		if scanDesc {
+			op = "<"
+		}
+		q = q.Where("("+createdAt+" "+op+" ?) OR ("+createdAt+" = ? AND "+id+" "+op+" ?)",
+			req.Cursor.CreatedAt, req.Cursor.CreatedAt, req.Cursor.ID)
+	}
+	order := " asc"
+	if scanDesc {
+		order = " desc"
+	}
+
+	var items []T
+	if err := q.Order(createdAt + order).Order(id + order).Limit(req.Limit + 1).Find(&items).Error; err != nil {
+		return nil, info, err
+	}
+
+	hasMore := len(items) > req.Limit
+	if hasMore {
+		items = items[:req.Limit]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if len(items) > 0 {
+		first, last := keyOf(items[0]), keyOf(items[len(items)-1])
+		first.Backward = true
+		// 向后翻页时，还有更多数据才给出 next；向前翻页时，当前页之后一定还有数据
+		if (!backward && hasMore) || backward {
+			info.NextCursor = last.encode()
+		}
+		// 带游标的向后翻页一定有上一页；向前翻页时，还有更多数据才给出 prev
+		if (!backward && req.Cursor != nil) || (backward && hasMore) {
+			info.PrevCursor = first.encode()
+		}
+	}
+	info.HasMore = info.NextCursor != ""
+
+	return items, info, nil
+}
+
+// postCursor 返回文章的游标位置
+func postCursor(p Post) Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+// commentCursor 返回评论的游标位置
+func commentCursor(cm Comment) Cursor {
+	return Cursor{CreatedAt: cm.CreatedAt, ID: cm.ID}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC), ID: 42, Backward: true}
+	got, err := decodeCursor(want.encode())
+	if err != nil {
+		t.Fatalf("解析游标失败: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Backward != want.Backward {
+		t.Errorf("游标往返不一致: %+v != %+v", got, want)
+	}
+
+	for _, s := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-05-01T00:00:00Z"}`)), // 缺少 id
+	} {
+		if _, err := decodeCursor(s); !errors.Is(err, errInvalidCursor) {
+			t.Errorf("decodeCursor(%q) 应返回 errInvalidCursor，得到 %v", s, err)
+		}
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	setupTestApp(t, func(cfg *Config) { cfg.Pagination.MaxPageSize = 50 })
+	cursor := Cursor{CreatedAt: time.Now().UTC(), ID: 7}.encode()
+
+	tests := []struct {
+		query     string
+		wantLimit int
+		wantTotal bool
+		wantErr   error
+	}{
+		{"", 10, false, nil},
+		{"limit=5", 5, false, nil},
+		{"page_size=5", 5, false, nil},
+		{"limit=5&page_size=8", 5, false, nil},
+		{"limit=500", 50, false, nil},
+		{"with_total=true&cursor=" + cursor, 10, true, nil},
+		{"limit=0", 0, false, errInvalidPageSize},
+		{"limit=abc", 0, false, errInvalidPageSize},
+		{"cursor=bogus", 0, false, errInvalidCursor},
+		{"page=2", 0, false, errPageUnsupported},
+		{"page=1&limit=5", 0, false, errPageUnsupported},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		req, err := parsePageRequest(c, 10)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%q: 期望错误 %v，得到 %v", tt.query, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || req.Limit != tt.wantLimit || req.WithTotal != tt.wantTotal {
+			t.Errorf("%q: 得到 %+v, %v", tt.query, req, err)
+		}
+	}
+}
+
+func TestPostListCursorNavigation(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "alice", RoleUser)
+	for i := 1; i <= 5; i++ {
+		createTestPost(t, author, fmt.Sprintf("post %d", i), "content")
+	}
+
+	page := func(query string) ([]string, PageInfo) {
+		t.Helper()
+		w := doJSON(r, http.MethodGet, "/api/v1/posts?"+query, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("获取文章列表返回 %d: %s", w.Code, w.Body.String())
+		}
+		body := decodeJSON(t, w)
+		var titles []string
+		for _, p := range body["posts"].([]interface{}) {
+			titles = append(titles, p.(map[string]interface{})["Title"].(string))
+		}
+		pi := body["pagination"].(map[string]interface{})
+		info := PageInfo{HasMore: pi["has_more"].(bool)}
+		info.NextCursor, _ = pi["next_cursor"].(string)
+		info.PrevCursor, _ = pi["prev_cursor"].(string)
+		if total, ok := pi["total"].(float64); ok {
+			n := int64(total)
+			info.Total = &n
+		}
+		return titles, info
+	}
+
+	first, info := page("limit=2&with_total=true")
+	if fmt.Sprint(first) != "[post 5 post 4]" || info.PrevCursor != "" || !info.HasMore {
+		t.Fatalf("第一页不对: %v %+v", first, info)
+	}
+	if info.Total == nil || *info.Total != 5 {
+		t.Errorf("with_total=true 时应返回总数 5: %v", info.Total)
+	}
+	if _, noTotal := page("limit=2"); noTotal.Total != nil {
+		t.Errorf("默认不返回总数")
+	}
+
+	second, info := page("limit=2&cursor=" + url.QueryEscape(info.NextCursor))
+	if fmt.Sprint(second) != "[post 3 post 2]" || info.PrevCursor == "" || !info.HasMore {
+		t.Fatalf("第二页不对: %v %+v", second, info)
+	}
+	last, lastInfo := page("limit=2&cursor=" + url.QueryEscape(info.NextCursor))
+	if fmt.Sprint(last) != "[post 1]" || lastInfo.HasMore || lastInfo.NextCursor != "" {
+		t.Errorf("最后一页不对: %v %+v", last, lastInfo)
+	}
+
+	// 从第二页用 prev_cursor 回到第一页，第一页没有上一页
+	back, backInfo := page("limit=2&cursor=" + url.QueryEscape(info.PrevCursor))
+	if fmt.Sprint(back) != fmt.Sprint(first) || backInfo.PrevCursor != "" || backInfo.NextCursor == "" {
+		t.Errorf("prev_cursor 应回到第一页: %v %+v", back, backInfo)
+	}
+
+	w := doJSON(r, http.MethodGet, "/api/v1/posts?page=2", "", nil)
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodePageUnsupported {
+		t.Errorf("page 参数应返回 400: %d %s", w.Code, w.Body.String())
+	}
+}
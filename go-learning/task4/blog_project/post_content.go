@@ -0,0 +1,123 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"log"
+	"strings"
+	"sync"
+)
+
+// 文章内容格式
+const (
+	ContentFormatPlain    = "plain"    // 纯文本，空行分段，单个换行渲染为 <br>
+	ContentFormatMarkdown = "markdown" // Markdown，新建文章的默认格式
+	ContentFormatHTML     = "html"     // HTML，经过白名单过滤后输出
+)
+
+// RenderedContent 文章内容渲染的结果
+type RenderedContent struct {
+	HTML string
+	TOC  []TOCEntry
+}
+
+// renderContent 把文章内容渲染为过滤后的 HTML 和目录
+func renderContent(format, content string) RenderedContent {
+	var raw string
+	switch format {
+	case ContentFormatMarkdown:
+		raw = renderMarkdown(content)
+	case ContentFormatHTML:
+		raw = content
+	default:
+		raw = renderPlain(content)
+	}
+	out, toc := sanitizeHTML(raw)
+	return RenderedContent{HTML: out, TOC: toc}
+}
+
+// renderPlain 转义纯文本，按空行分段
+func renderPlain(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var b strings.Builder
+	for _, para := range strings.Split(content, "\n\n") {
+		para = strings.Trim(para, "\n")
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(para), "\n", "<br />\n") + "</p>\n")
+	}
+	return b.String()
+}
+
+// renderCache 按内容哈希缓存渲染结果的 LRU 缓存，文章内容不变时不需要重新渲染
+// 缓存键包含格式和内容，文章修改后自然得到新的键，旧的结果会被逐渐淘汰
+type renderCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type renderCacheEntry struct {
+	key   string
+	value RenderedContent
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (rc *renderCache) get(key string) (RenderedContent, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[key]; ok {
+		rc.ll.MoveToFront(el)
+		return el.Value.(*renderCacheEntry).value, true
+	}
+	return RenderedContent{}, false
+}
+
+func (rc *renderCache) add(key string, value RenderedContent) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if el, ok := rc.items[key]; ok {
+		rc.ll.MoveToFront(el)
+		el.Value.(*renderCacheEntry).value = value
+		return
+	}
+	rc.items[key] = rc.ll.PushFront(&renderCacheEntry{key: key, value: value})
+	for rc.ll.Len() > rc.size {
+		oldest := rc.ll.Back()
+		rc.ll.Remove(oldest)
+		delete(rc.items, oldest.Value.(*renderCacheEntry).key)
+	}
+}
+
+// contentRenderCache 渲染结果缓存，由 initRenderCache 初始化；为 nil 时不缓存
+var contentRenderCache *renderCache
+
+// initRenderCache 按配置创建渲染结果缓存
+func initRenderCache() {
+	if size := currentConfig().Posts.RenderCacheSize; size > 0 {
+		contentRenderCache = newRenderCache(size)
+		log.Printf("文章渲染缓存容量: %d", size)
+	}
+}
+
+// renderPostContent 渲染文章内容，优先使用缓存
+func renderPostContent(format, content string) RenderedContent {
+	if contentRenderCache == nil {
+		return renderContent(format, content)
+	}
+	sum := sha256.Sum256([]byte(format + "\x00" + content))
+	key := hex.EncodeToString(sum[:])
+	if rendered, ok := contentRenderCache.get(key); ok {
+		return rendered
+	}
+	rendered := renderContent(format, content)
+	contentRenderCache.add(key, rendered)
+	return rendered
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApplyPostStatus(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	cases := []struct {
+		name      string
+		status    string
+		publishAt *time.Time
+		want      string // 期望的状态，为空表示期望校验失败
+	}{
+		{"未指定时直接发布", "", nil, PostStatusPublished},
+		{"草稿", PostStatusDraft, nil, PostStatusDraft},
+		{"定时发布", PostStatusScheduled, &future, PostStatusScheduled},
+		{"定时发布缺少时间", PostStatusScheduled, nil, ""},
+		{"定时发布时间已过", PostStatusScheduled, &past, ""},
+		{"补录过去的发布时间", PostStatusPublished, &past, PostStatusPublished},
+		{"未知状态", "deleted", nil, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var post Post
+			err := applyPostStatus(&post, tc.status, tc.publishAt)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("期望校验失败，得到状态 %q", post.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("校验失败: %v", err)
+			}
+			if post.Status != tc.want {
+				t.Errorf("状态为 %q，期望 %q", post.Status, tc.want)
+			}
+			if tc.want == PostStatusPublished && post.PublishAt == nil {
+				t.Error("发布的文章缺少发布时间")
+			}
+		})
+	}
+}
+
+// 不传 status 的旧客户端创建的文章仍然直接公开
+func TestCreatePostDefaultsToPublished(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+
+	w := doJSON(r, http.MethodPost, "/api/v1/posts", testToken(t, author), map[string]string{"title": "hello", "content": "world"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建文章返回 %d: %s", w.Code, w.Body.String())
+	}
+	body := decodeJSON(t, w)
+	if body["status"] != PostStatusPublished {
+		t.Fatalf("未指定状态的文章应直接发布，得到 %v", body["status"])
+	}
+	if w := doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/posts/%v", body["post_id"]), "", nil); w.Code != http.StatusOK {
+		t.Errorf("匿名用户读取新文章返回 %d", w.Code)
+	}
+}
+
+// 未发布的文章只有作者本人和能编辑任意文章的管理员可以看到
+func TestPostVisibility(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	draft := createTestPost(t, author, "draft", "content")
+	if err := DB.Model(&draft).Update("status", PostStatusDraft).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/v1/posts/%d", draft.ID)
+
+	cases := []struct {
+		who   string
+		token string
+		want  int
+	}{
+		{"匿名用户", "", http.StatusNotFound},
+		{"其他用户", testToken(t, createTestUser(t, "other", RoleUser)), http.StatusNotFound},
+		{"版主", testToken(t, createTestUser(t, "moderator", RoleModerator)), http.StatusNotFound},
+		{"作者", testToken(t, author), http.StatusOK},
+		{"管理员", testToken(t, createTestUser(t, "admin", RoleAdmin)), http.StatusOK},
+	}
+	for _, tc := range cases {
+		if w := doJSON(r, http.MethodGet, path, tc.token, nil); w.Code != tc.want {
+			t.Errorf("%s读取草稿返回 %d，期望 %d", tc.who, w.Code, tc.want)
+		}
+		w := doJSON(r, http.MethodGet, "/api/v1/posts", tc.token, nil)
+		listed := len(decodeJSON(t, w)["posts"].([]interface{})) == 1
+		if listed != (tc.want == http.StatusOK) {
+			t.Errorf("%s的文章列表中草稿是否可见: %v", tc.who, listed)
+		}
+	}
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	now := time.Now()
+	due := createTestPost(t, author, "due", "content")
+	later := createTestPost(t, author, "later", "content")
+	DB.Model(&due).Updates(map[string]interface{}{"status": PostStatusScheduled, "publish_at": now.Add(-time.Minute)})
+	DB.Model(&later).Updates(map[string]interface{}{"status": PostStatusScheduled, "publish_at": now.Add(time.Hour)})
+
+	n, err := publishScheduledPosts(now)
+	if err != nil || n != 1 {
+		t.Fatalf("应发布 1 篇到期的文章，得到 %d (%v)", n, err)
+	}
+	for _, tc := range []struct {
+		post Post
+		want string
+	}{{due, PostStatusPublished}, {later, PostStatusScheduled}} {
+		var got Post
+		DB.First(&got, tc.post.ID)
+		if got.Status != tc.want {
+			t.Errorf("文章 %s 的状态为 %q，期望 %q", tc.post.Title, got.Status, tc.want)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 个人资料、修改密码、注销账号，以及公开的作者主页
+
+var errPasswordNotSet = newAPIError(http.StatusBadRequest, CodePasswordNotSet, "user.password_not_set")
+
+// displayName 展示给其他用户的名字，没有设置昵称时使用用户名
+func displayName(user User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+// publicProfileView 任何人都可以看到的资料，不包含邮箱、角色等账号信息
+func publicProfileView(user User) gin.H {
+	return gin.H{
+		"username":       user.Username,
+		"display_name":   displayName(user),
+		"bio":            user.Bio,
+		"avatar_url":     user.AvatarURL,
+		"website":        user.Website,
+		"wallet_address": user.WalletAddress,
+		"joined_at":      user.CreatedAt,
+	}
+}
+
+// profileView 本人看到的完整资料
+func profileView(user User) gin.H {
+	view := publicProfileView(user)
+	view["id"] = user.ID
+	view["display_name"] = user.DisplayName
+	view["email"] = user.Email
+	view["email_verified"] = user.EmailVerifiedAt != nil
+	view["role"] = user.Role
+	view["locale"] = user.Locale
+	view["mfa_enabled"] = user.TOTPEnabledAt != nil
+	view["has_password"] = user.Password != ""
+	return view
+}
+
+// withDeletedUsers 预加载作者时包含已注销（匿名化）的账号，文章和评论仍然显示为 deleted-<id>
+func withDeletedUsers(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// GetProfileHandler 获取当前用户的个人资料
+func GetProfileHandler(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "profile.fetched"), "profile": profileView(*user)})
+}
+
+// ProfileUpdateRequest 修改个人资料的请求体，字段为空表示不修改，传空字符串表示清空
+// 钱包地址需要签名证明所有权，通过 /profile/wallet 绑定
+type ProfileUpdateRequest struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,max=500,httpurl"`
+	Website     *string `json:"website" binding:"omitempty,max=255,httpurl"`
+}
+
+// UpdateProfileHandler 修改个人资料
+func UpdateProfileHandler(c *gin.Context) {
+	var req ProfileUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	updates := map[string]interface{}{}
+	for column, value := range map[string]*string{
+		"display_name": req.DisplayName,
+		"bio":          req.Bio,
+		"avatar_url":   req.AvatarURL,
+		"website":      req.Website,
+	} {
+		if value != nil {
+			updates[column] = strings.TrimSpace(*value)
+		}
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if len(updates) > 0 {
+		if err := DB.Model(user).Updates(updates).Error; err != nil {
+			abortWithError(c, internalError("profile.update_failed", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "profile.updated"), "profile": profileView(*user)})
+}
+
+// confirmPassword 敏感操作前再次确认密码；没有设置密码的账号（钱包账号）跳过
+// 密码错误计入登录失败次数，防止拿到访问 token 的人借此猜密码
+func confirmPassword(user *User, password string) *APIError {
+	if user.Password == "" || CheckPasswordHash(password, user.Password) {
+		return nil
+	}
+	if _, err := recordLoginFailure(*user); err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+	}
+	return errInvalidCredentials
+}
+
+// confirmSecondFactor 开启了两步验证的账号在敏感操作前还要提供验证码（或恢复码），需要在事务中调用
+func confirmSecondFactor(tx *gorm.DB, user *User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return nil
+	}
+	if strings.TrimSpace(code) == "" {
+		return newAPIError(http.StatusBadRequest, CodeMFAInvalidCode, "auth.mfa_code_required")
+	}
+	if err := verifySecondFactor(tx, user, code); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			return newAPIError(http.StatusBadRequest, CodeMFAInvalidCode, "auth.mfa_invalid_code")
+		}
+		return err
+	}
+	return nil
+}
+
+// respondProfileError 事务中返回的 APIError 原样返回，其他错误按内部错误处理
+func respondProfileError(c *gin.Context, err error, messageID string) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		abortWithError(c, apiErr)
+		return
+	}
+	abortWithError(c, internalError(messageID, err))
+}
+
+// ChangePasswordRequest 修改密码的请求体，开启了两步验证时需要提供 code
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=72"`
+	NewPassword     string `json:"new_password" binding:"required,password"`
+	Code            string `json:"code" binding:"max=32"`
+}
+
+// ChangePasswordHandler 修改密码，需要提供当前密码；成功后吊销所有会话，需要重新登录
+func ChangePasswordHandler(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.Password == "" {
+		abortWithError(c, errPasswordNotSet)
+		return
+	}
+	if accountLocked(*user, time.Now()) {
+		abortWithError(c, errAccountLocked(c, *user.LockedUntil))
+		return
+	}
+
+	if apiErr := confirmPassword(user, req.CurrentPassword); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	hashed, err := HashPassword(req.NewPassword)
+	if err != nil {
+		abortWithError(c, internalError("user.password_hash_failed", err))
+		return
+	}
+
+	claimsVal, _ := c.Get("claims")
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := confirmSecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("password", hashed).Error; err != nil {
+			return err
+		}
+		if err := resetLoginFailures(tx, *user); err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeAccessToken(tx, claimsVal.(*Claims))
+	})
+	if err != nil {
+		respondProfileError(c, err, "user.password_change_failed")
+		return
+	}
+
+	log.Printf("用户 %s 修改了密码", user.Username)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.password_changed")})
+}
+
+// DeleteAccountRequest 注销账号的请求体；confirm_username 必须与当前用户名一致，防止误操作
+// 设置了密码的账号需要提供 password，开启了两步验证时需要提供 code
+type DeleteAccountRequest struct {
+	ConfirmUsername string `json:"confirm_username" binding:"required"`
+	Password        string `json:"password" binding:"max=72"`
+	Code            string `json:"code" binding:"max=32"`
+}
+
+// DeleteAccountHandler 注销当前账号
+// 已发布的文章和评论保留，作者显示为 deleted-<id>；个人资料、登录凭据、API Key 和未发布的文章被清除，
+// 用户名和邮箱可以被重新注册
+func DeleteAccountHandler(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if req.ConfirmUsername != user.Username {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeDeleteConfirmation, "user.delete_confirmation_mismatch"))
+		return
+	}
+	if accountLocked(*user, time.Now()) {
+		abortWithError(c, errAccountLocked(c, *user.LockedUntil))
+		return
+	}
+
+	if apiErr := confirmPassword(user, req.Password); apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	username := user.Username
+	claimsVal, _ := c.Get("claims")
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := confirmSecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+
+		// 未发布的文章只有作者本人能看到，随账号一起删除；逐条删除以便同步全文索引
+		var drafts []Post
+		if err := tx.Where("user_id = ? AND status <> ?", user.ID, PostStatusPublished).Find(&drafts).Error; err != nil {
+			return err
+		}
+		for i := range drafts {
+			if err := tx.Delete(&drafts[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&ActionToken{}).Error; err != nil {
+			return err
+		}
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		if err := revokeAccessToken(tx, claimsVal.(*Claims)); err != nil {
+			return err
+		}
+
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"username":          fmt.Sprintf("deleted-%d", user.ID),
+			"password":          "",
+			"email":             nil,
+			"locale":            "",
+			"email_verified_at": nil,
+			"totp_secret":       "",
+			"totp_enabled_at":   nil,
+			"totp_last_step":    0,
+			"wallet_address":    nil,
+			"failed_logins":     0,
+			"last_failed_login": nil,
+			"locked_until":      nil,
+			"display_name":      "",
+			"bio":               "",
+			"avatar_url":        "",
+			"website":           "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		respondProfileError(c, err, "user.delete_failed")
+		return
+	}
+
+	log.Printf("用户 %s 注销了账号 (ID=%d)", username, user.ID)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.deleted")})
+}
+
+// GetUserPageHandler 公开的作者主页：个人资料、统计数据和已发布的文章（分页）
+func GetUserPageHandler(c *gin.Context) {
+	pageReq, err := parsePageRequest(c, currentConfig().Pagination.DefaultPageSize)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var user User
+	if err := DB.Where("username = ?", c.Param("username")).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, newAPIError(http.StatusNotFound, CodeUserNotFound, "user.not_found"))
+		} else {
+			abortWithError(c, internalError("user.page_failed", err))
+		}
+		return
+	}
+
+	var postCount, commentCount int64
+	if err := DB.Model(&Post{}).Where("user_id = ? AND status = ?", user.ID, PostStatusPublished).Count(&postCount).Error; err != nil {
+		abortWithError(c, internalError("user.page_failed", err))
+		return
+	}
+	if err := DB.Model(&Comment{}).Where("user_id = ? AND status = ?", user.ID, CommentStatusApproved).Count(&commentCount).Error; err != nil {
+		abortWithError(c, internalError("user.page_failed", err))
+		return
+	}
+
+	// 作者主页只展示已发布的文章，作者本人和管理员看到的也一样
+	query := DB.Model(&Post{}).Where("posts.user_id = ? AND posts.status = ?", user.ID, PostStatusPublished).
+		Preload("User").Preload("Tags").Preload("Categories")
+	posts, pageInfo, err := paginate(query, pageReq, "posts", true, postCursor)
+	if err != nil {
+		abortWithError(c, internalError("user.page_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": T(c, "user.page_fetched"),
+		"user":    publicProfileView(user),
+		"stats": gin.H{
+			"posts":    postCount,
+			"comments": commentCount,
+		},
+		"posts":      posts,
+		"pagination": pageInfo,
+	})
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestProfileViews(t *testing.T) {
+	wallet := "0x00000000000000000000000000000000000000aa"
+	user := User{Username: "alice", Email: "alice@example.com", Role: RoleAdmin, WalletAddress: &wallet, Password: "hash"}
+
+	public := publicProfileView(user)
+	if public["display_name"] != "alice" {
+		t.Errorf("没有昵称时应显示用户名: %v", public["display_name"])
+	}
+	for _, key := range []string{"id", "email", "role", "locale", "mfa_enabled", "has_password"} {
+		if _, ok := public[key]; ok {
+			t.Errorf("公开资料不应包含 %s", key)
+		}
+	}
+
+	// 本人看到的是原始昵称，方便编辑
+	own := profileView(user)
+	if own["display_name"] != "" || own["email"] != "alice@example.com" || own["has_password"] != true {
+		t.Errorf("本人资料不对: %v", own)
+	}
+
+	user.DisplayName = "Alice"
+	if displayName(user) != "Alice" {
+		t.Errorf("设置了昵称时应显示昵称: %s", displayName(user))
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+
+	update := func(body map[string]interface{}) (int, map[string]interface{}) {
+		t.Helper()
+		w := doJSON(r, http.MethodPut, "/api/v1/profile", token, body)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		return w.Code, decodeJSON(t, w)["profile"].(map[string]interface{})
+	}
+
+	code, profile := update(map[string]interface{}{"display_name": "  Alice  ", "bio": "hello", "website": "https://alice.example.com"})
+	if code != http.StatusOK || profile["display_name"] != "Alice" || profile["bio"] != "hello" {
+		t.Fatalf("修改资料失败: %d %v", code, profile)
+	}
+	// 没有传的字段保持不变，传空字符串表示清空
+	if _, profile := update(map[string]interface{}{"bio": ""}); profile["display_name"] != "Alice" || profile["bio"] != "" {
+		t.Errorf("只应修改传入的字段: %v", profile)
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"头像不是 URL": {"avatar_url": "javascript:alert(1)"},
+		"网站不是 URL": {"website": "alice.example.com"},
+		"简介过长":     {"bio": string(make([]byte, 501))},
+	} {
+		if code, _ := update(body); code != http.StatusBadRequest {
+			t.Errorf("%s 应返回 400，得到 %d", name, code)
+		}
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+
+	change := func(current, next string) int {
+		t.Helper()
+		return doJSON(r, http.MethodPut, "/api/v1/profile/password", token, map[string]string{"current_password": current, "new_password": next}).Code
+	}
+	if code := change("wrong", "N3wPassw0rd!"); code != http.StatusUnauthorized {
+		t.Errorf("当前密码错误应返回 401: %d", code)
+	}
+	if code := change("Passw0rd!x", "short"); code != http.StatusBadRequest {
+		t.Errorf("新密码也要满足强度要求: %d", code)
+	}
+	if code := change("Passw0rd!x", "N3wPassw0rd!"); code != http.StatusOK {
+		t.Fatalf("修改密码失败: %d", code)
+	}
+
+	// 修改密码后当前会话失效，需要用新密码重新登录
+	if w := doJSON(r, http.MethodGet, "/api/v1/profile", token, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("修改密码后旧的访问 token 应失效: %d", w.Code)
+	}
+	w := doJSON(r, http.MethodPost, "/api/v1/login", "", map[string]string{"username": "alice", "password": "N3wPassw0rd!"})
+	if w.Code != http.StatusOK {
+		t.Errorf("应可以用新密码登录: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+	token := testToken(t, user)
+	published := createTestPost(t, user, "published", "content")
+	draft := createTestPost(t, user, "draft", "content")
+	DB.Model(&draft).Update("status", PostStatusDraft)
+
+	w := doJSON(r, http.MethodDelete, "/api/v1/profile", token, map[string]string{"confirm_username": "bob", "password": "Passw0rd!x"})
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeDeleteConfirmation {
+		t.Errorf("确认的用户名不一致应返回 400: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodDelete, "/api/v1/profile", token, map[string]string{"confirm_username": "alice", "password": "Passw0rd!x"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("注销账号失败: %d %s", w.Code, w.Body.String())
+	}
+
+	var deleted User
+	DB.Unscoped().First(&deleted, user.ID)
+	if deleted.Username != fmt.Sprintf("deleted-%d", user.ID) || deleted.Email != "" || deleted.Password != "" || !deleted.DeletedAt.Valid {
+		t.Errorf("注销后账号应被匿名化: %+v", deleted)
+	}
+	var drafts int64
+	DB.Unscoped().Model(&Post{}).Where("id = ? AND deleted_at IS NULL", draft.ID).Count(&drafts)
+	if drafts != 0 {
+		t.Error("未发布的文章应随账号删除")
+	}
+
+	// 已发布的文章保留，作者显示为 deleted-<id>
+	w = doJSON(r, http.MethodGet, fmt.Sprintf("/api/v1/posts/%d", published.ID), "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("已发布的文章应保留: %d", w.Code)
+	}
+	author := decodeJSON(t, w)["post"].(map[string]interface{})["User"].(map[string]interface{})
+	if author["Username"] != deleted.Username {
+		t.Errorf("文章作者应显示为 %s: %v", deleted.Username, author["Username"])
+	}
+
+	if w := doJSON(r, http.MethodGet, "/api/v1/users/alice", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("注销后作者主页应返回 404: %d", w.Code)
+	}
+	// 用户名和邮箱可以被重新注册
+	w = doJSON(r, http.MethodPost, "/api/v1/register", "", map[string]string{"username": "alice", "password": "Passw0rd!x", "email": user.Email})
+	if w.Code != http.StatusCreated {
+		t.Errorf("注销后用户名和邮箱应可以重新注册: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUserPage(t *testing.T) {
+	r := setupTestApp(t)
+	alice := createTestUser(t, "alice", RoleUser)
+	DB.Model(&alice).Updates(map[string]interface{}{"display_name": "Alice", "bio": "hello"})
+	bob := createTestUser(t, "bob", RoleUser)
+	post := createTestPost(t, alice, "published", "content")
+	createTestPost(t, bob, "bob's post", "content")
+	draft := createTestPost(t, alice, "draft", "content")
+	DB.Model(&draft).Update("status", PostStatusDraft)
+	DB.Create(&Comment{Content: "approved", PostID: post.ID, UserID: alice.ID, Status: CommentStatusApproved})
+	DB.Create(&Comment{Content: "pending", PostID: post.ID, UserID: alice.ID, Status: CommentStatusPending})
+
+	// 作者本人看到的主页与访客相同，不包含草稿
+	w := doJSON(r, http.MethodGet, "/api/v1/users/alice", testToken(t, alice), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取作者主页失败: %d %s", w.Code, w.Body.String())
+	}
+	body := decodeJSON(t, w)
+	profile := body["user"].(map[string]interface{})
+	if profile["display_name"] != "Alice" || profile["bio"] != "hello" || profile["email"] != nil {
+		t.Errorf("作者主页应只包含公开资料: %v", profile)
+	}
+	stats := body["stats"].(map[string]interface{})
+	if stats["posts"] != float64(1) || stats["comments"] != float64(1) {
+		t.Errorf("只统计已发布的文章和已通过的评论: %v", stats)
+	}
+	posts := body["posts"].([]interface{})
+	if len(posts) != 1 || posts[0].(map[string]interface{})["Title"] != "published" {
+		t.Errorf("只列出该作者已发布的文章: %v", posts)
+	}
+
+	if w := doJSON(r, http.MethodGet, "/api/v1/users/nobody", "", nil); w.Code != http.StatusNotFound || errorCode(t, w) != CodeUserNotFound {
+		t.Errorf("不存在的用户应返回 404: %d %s", w.Code, w.Body.String())
+	}
+}
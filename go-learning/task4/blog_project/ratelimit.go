@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流采用令牌桶算法：桶的容量为 burst，每 per/requests 时间补充一个令牌，每个请求消耗一个令牌
+// 桶按 "策略名:ip:<IP>" 或 "策略名:user:<用户ID>" 区分，同一个请求可以同时受多个策略限制
+
+// RateLimitRule 一条限流规则，requests 为 0 表示不限制
+type RateLimitRule struct {
+	Requests int      `yaml:"requests" toml:"requests"` // 每个周期补充的令牌数
+	Per      Duration `yaml:"per" toml:"per"`           // 周期
+	Burst    int      `yaml:"burst" toml:"burst"`       // 桶容量，即允许的突发请求数，0 表示等于 requests
+}
+
+// rate 每秒补充的令牌数
+func (r RateLimitRule) rate() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+// burst 桶容量
+func (r RateLimitRule) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 桶中剩余的令牌数
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌的时间
+}
+
+// RateLimitStore 保存令牌桶状态的存储；单实例部署使用内存存储，多实例部署需要共享的存储（如 Redis）
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error)
+}
+
+// tokenBucket 内存中的一个令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 桶回满的时间，之后可以删除，下次访问时重新创建一个满的桶
+}
+
+// MemoryRateLimitStore 进程内的令牌桶存储
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewMemoryRateLimitStore 创建内存存储，并定期清理已经回满的桶
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.cleanup()
+		}
+	}()
+	return s
+}
+
+// Take 从桶中取一个令牌
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	now := time.Now()
+	rate, burst := rule.rate(), float64(rule.burst())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	result := takeToken(&b.tokens, rate)
+	b.full = now.Add(time.Duration((burst - b.tokens) / rate * float64(time.Second)))
+	return result, nil
+}
+
+// cleanup 删除已经回满的桶
+func (s *MemoryRateLimitStore) cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if b.full.Before(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// takeToken 令牌足够时扣除一个，否则计算还需要等待多久
+func takeToken(tokens *float64, rate float64) RateLimitResult {
+	if *tokens >= 1 {
+		*tokens--
+		return RateLimitResult{Allowed: true, Remaining: int(*tokens)}
+	}
+	wait := time.Duration((1 - *tokens) / rate * float64(time.Second))
+	return RateLimitResult{Allowed: false, RetryAfter: wait}
+}
+
+// RedisScripter 执行 Lua 脚本的 Redis 客户端，兼容 Redis 协议的服务（如 Valkey、KeyDB）都可以使用
+// 例如 go-redis 可以这样适配：
+//
+//	func (a adapter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return a.client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisScripter interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// redisTokenBucketScript 在 Redis 中原子地更新令牌桶，返回 {是否允许, 剩余令牌数}
+// 令牌数可能是小数，Lua 的数字返回给客户端时会被截断成整数，所以用字符串返回
+const redisTokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}
+`
+
+// RedisRateLimitStore 保存在 Redis 中的令牌桶，多个实例共享限流状态
+type RedisRateLimitStore struct {
+	client RedisScripter
+	prefix string
+}
+
+// NewRedisRateLimitStore 创建 Redis 存储，prefix 为键名前缀
+func NewRedisRateLimitStore(client RedisScripter, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, prefix: prefix}
+}
+
+// Take 从桶中取一个令牌
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (RateLimitResult, error) {
+	rate := rule.rate()
+	reply, err := s.client.Eval(ctx, redisTokenBucketScript, []string{s.prefix + key},
+		strconv.FormatFloat(rate, 'f', -1, 64), rule.burst(), time.Now().UnixMilli())
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return RateLimitResult{}, fmt.Errorf("限流脚本返回了无法识别的结果: %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("限流脚本返回了无法识别的令牌数: %v", values[1])
+	}
+	if allowed == 1 {
+		return RateLimitResult{Allowed: true, Remaining: int(tokens)}, nil
+	}
+	return RateLimitResult{Allowed: false, RetryAfter: time.Duration((1 - tokens) / rate * float64(time.Second))}, nil
+}
+
+// rateLimitStore 当前使用的限流存储，由 initRateLimiter 初始化
+var rateLimitStore RateLimitStore
+
+// initRateLimiter 初始化限流存储；部署多个实例时应改为使用 NewRedisRateLimitStore，让各实例共享限流状态
+func initRateLimiter() {
+	rateLimitStore = NewMemoryRateLimitStore(time.Minute)
+}
+
+// 限流策略名，对应配置中 rate_limit 下的规则
+const (
+	RateLimitLogin    = "login"
+	RateLimitRegister = "register"
+	RateLimitAuth     = "auth"
+	RateLimitPublic   = "public"
+	RateLimitUser     = "user"
+)
+
+// rateLimitRule 返回策略当前的规则，规则可以热加载
+func rateLimitRule(policy string) RateLimitRule {
+	cfg := currentConfig().RateLimit
+	switch policy {
+	case RateLimitLogin:
+		return cfg.Login
+	case RateLimitRegister:
+		return cfg.Register
+	case RateLimitAuth:
+		return cfg.Auth
+	case RateLimitPublic:
+		return cfg.Public
+	case RateLimitUser:
+		return cfg.User
+	}
+	return RateLimitRule{}
+}
+
+// RateLimitKey 根据请求计算限流的键，返回空字符串表示不限制
+type RateLimitKey func(c *gin.Context) string
+
+// rateLimitByIP 按客户端 IP 限流，用于公开接口
+func rateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// rateLimitByUser 按登录用户限流，需要放在 AuthMiddleware 之后；没有登录时按 IP
+func rateLimitByUser(c *gin.Context) string {
+	if userID, ok := c.Get("userID"); ok {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return rateLimitByIP(c)
+}
+
+// RateLimit 按策略限流的中间件，超出限制时返回 429 和 Retry-After
+// 存储出错时放行请求，限流不可用不应该影响正常访问
+func RateLimit(policy string, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := rateLimitRule(policy)
+		if !currentConfig().RateLimit.Enabled || rule.Requests == 0 {
+			c.Next()
+			return
+		}
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		result, err := rateLimitStore.Take(c.Request.Context(), policy+":"+k, rule)
+		if err != nil {
+			log.Printf("限流检查失败，已放行: policy=%s, err=%v", policy, err)
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rule.burst()))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := retryAfterSeconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortWithError(c, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "request.rate_limited", retryAfter))
+			return
+		}
+		c.Next()
+	}
+}
+
+// retryAfterSeconds 把等待时间向上取整为秒，Retry-After 至少为 1
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,125 @@
+//go:build integration
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 在真实的 Redis 上验证限流 Lua 脚本，需要设置 BLOG_TEST_REDIS_ADDR：
+//
+//	BLOG_TEST_REDIS_ADDR=127.0.0.1:6379 go test -tags integration -run Redis ./...
+
+// respClient 只实现 EVAL 所需部分 RESP 协议的 Redis 客户端，测试不需要引入 Redis 驱动
+type respClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *respClient) do(args ...interface{}) (interface{}, error) {
+	fmt.Fprintf(c.conn, "*%d\r\n", len(args))
+	for _, arg := range args {
+		s := fmt.Sprint(arg)
+		fmt.Fprintf(c.conn, "$%d\r\n%s\r\n", len(s), s)
+	}
+	return c.read()
+}
+
+// read 读取一个回复，整数为 int64，字符串为 string，数组为 []interface{}，与 go-redis 的返回类型一致
+func (c *respClient) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("无效的回复: %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, errors.New(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, _ := strconv.Atoi(body)
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, _ := strconv.Atoi(body)
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("无效的回复: %q", line)
+}
+
+func (c *respClient) Eval(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	cmd := []interface{}{"EVAL", script, len(keys)}
+	for _, k := range keys {
+		cmd = append(cmd, k)
+	}
+	return c.do(append(cmd, args...)...)
+}
+
+func TestRedisTokenBucketScript(t *testing.T) {
+	addr := os.Getenv("BLOG_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("未设置 BLOG_TEST_REDIS_ADDR")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("连接 Redis 失败: %v", err)
+	}
+	defer conn.Close()
+	client := &respClient{conn: conn, r: bufio.NewReader(conn)}
+
+	prefix := fmt.Sprintf("blog_test:%d:", time.Now().UnixNano())
+	store := NewRedisRateLimitStore(client, prefix)
+	rule := RateLimitRule{Requests: 10, Per: Duration{time.Second}, Burst: 3}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		r, err := store.Take(ctx, "k", rule)
+		if err != nil || !r.Allowed || r.Remaining != i {
+			t.Fatalf("突发请求应在桶容量内放行: %+v %v", r, err)
+		}
+	}
+	r, err := store.Take(ctx, "k", rule)
+	if err != nil || r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > 100*time.Millisecond {
+		t.Fatalf("桶空后应拒绝并给出等待时间: %+v %v", r, err)
+	}
+
+	// 桶在回满所需的时间后过期，不会在 Redis 中无限堆积
+	ttl, err := client.do("PTTL", prefix+"k")
+	if ms, _ := ttl.(int64); err != nil || ms <= 0 || ms > 300 {
+		t.Errorf("键应设置为回满所需的过期时间（300ms 以内）: %v %v", ttl, err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if r, err := store.Take(ctx, "k", rule); err != nil || !r.Allowed {
+		t.Errorf("按速率补充令牌后应放行: %+v %v", r, err)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTakeToken(t *testing.T) {
+	rule := RateLimitRule{Requests: 10, Per: Duration{time.Minute}}
+	if rule.burst() != 10 {
+		t.Errorf("burst 为 0 时桶容量应等于 requests，得到 %d", rule.burst())
+	}
+	rate := rule.rate() // 每 6 秒一个令牌
+
+	tokens := 1.5
+	if r := takeToken(&tokens, rate); !r.Allowed || r.Remaining != 0 || tokens != 0.5 {
+		t.Errorf("令牌足够时应扣除一个: %+v tokens=%v", r, tokens)
+	}
+	r := takeToken(&tokens, rate)
+	if r.Allowed || tokens != 0.5 {
+		t.Errorf("令牌不足时应拒绝且不扣除: %+v tokens=%v", r, tokens)
+	}
+	if r.RetryAfter != 3*time.Second {
+		t.Errorf("还差半个令牌，应等待 3s，得到 %v", r.RetryAfter)
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+	rule := RateLimitRule{Requests: 1, Per: Duration{time.Second}, Burst: 3}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		if r, _ := s.Take(ctx, "login:ip:1", rule); !r.Allowed || r.Remaining != i {
+			t.Fatalf("突发请求应在桶容量内放行: %+v", r)
+		}
+	}
+	r, _ := s.Take(ctx, "login:ip:1", rule)
+	if r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > time.Second {
+		t.Errorf("桶空后应拒绝并给出不超过一个周期的等待时间: %+v", r)
+	}
+	if r, _ := s.Take(ctx, "login:ip:2", rule); !r.Allowed {
+		t.Error("不同的键使用各自的桶")
+	}
+
+	// 模拟时间流逝：两秒后补充两个令牌
+	s.buckets["login:ip:1"].last = s.buckets["login:ip:1"].last.Add(-2 * time.Second)
+	if r, _ := s.Take(ctx, "login:ip:1", rule); !r.Allowed || r.Remaining != 1 {
+		t.Errorf("按速率补充令牌后应放行: %+v", r)
+	}
+
+	// 回满的桶被清理，未回满的保留
+	s.buckets["login:ip:2"].full = time.Now().Add(-time.Second)
+	s.cleanup()
+	if _, ok := s.buckets["login:ip:2"]; ok {
+		t.Error("已回满的桶应被清理")
+	}
+	if _, ok := s.buckets["login:ip:1"]; !ok {
+		t.Error("未回满的桶不应被清理")
+	}
+}
+
+// fakeRedis 记录脚本调用的参数并返回预设的结果
+type fakeRedis struct {
+	keys  []string
+	args  []interface{}
+	reply interface{}
+	err   error
+}
+
+func (f *fakeRedis) Eval(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	if script != redisTokenBucketScript {
+		return nil, errors.New("unexpected script")
+	}
+	f.keys, f.args = keys, args
+	return f.reply, f.err
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	redis := &fakeRedis{reply: []interface{}{int64(1), "4.25"}}
+	s := NewRedisRateLimitStore(redis, "blog:rl:")
+	rule := RateLimitRule{Requests: 120, Per: Duration{time.Minute}, Burst: 60}
+
+	r, err := s.Take(context.Background(), "public:ip:1.2.3.4", rule)
+	if err != nil || !r.Allowed || r.Remaining != 4 {
+		t.Fatalf("应解析为放行且剩余 4 个令牌: %+v %v", r, err)
+	}
+	if len(redis.keys) != 1 || redis.keys[0] != "blog:rl:public:ip:1.2.3.4" {
+		t.Errorf("键名应加上前缀: %v", redis.keys)
+	}
+	// 参数依次为每秒补充的令牌数、桶容量、当前毫秒时间戳
+	if len(redis.args) != 3 || redis.args[0] != "2" || redis.args[1] != 60 {
+		t.Errorf("脚本参数不对: %v", redis.args)
+	}
+	if ms, _ := redis.args[2].(int64); time.Since(time.UnixMilli(ms)) > time.Minute {
+		t.Errorf("第三个参数应为当前毫秒时间戳: %v", redis.args[2])
+	}
+
+	redis.reply = []interface{}{int64(0), "0.5"}
+	if r, err := s.Take(context.Background(), "k", rule); err != nil || r.Allowed || r.RetryAfter != 250*time.Millisecond {
+		t.Errorf("拒绝时应按剩余令牌计算等待时间: %+v %v", r, err)
+	}
+
+	for _, reply := range []interface{}{"OK", []interface{}{int64(1)}, []interface{}{int64(1), "NaN?"}} {
+		redis.reply = reply
+		if _, err := s.Take(context.Background(), "k", rule); err == nil {
+			t.Errorf("无法识别的返回值 %v 应返回错误", reply)
+		}
+	}
+	redis.err = errors.New("connection refused")
+	if _, err := s.Take(context.Background(), "k", rule); !errors.Is(err, redis.err) {
+		t.Errorf("Redis 错误应原样返回: %v", err)
+	}
+}
+
+// failingStore 总是返回错误的限流存储
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, RateLimitRule) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("redis unavailable")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) {
+		cfg.RateLimit.Enabled = true
+		cfg.RateLimit.Login = RateLimitRule{Requests: 2, Per: Duration{time.Minute}, Burst: 2}
+	})
+	login := func() *http.Response {
+		w := doJSON(r, http.MethodPost, "/api/v1/login", "", map[string]string{"username": "nobody", "password": "x"})
+		return w.Result()
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := login(); resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("限额内的请求应正常处理: %d %v", resp.StatusCode, resp.Header)
+		}
+	}
+	resp := login()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("超出限额应返回 429: %d %v", resp.StatusCode, resp.Header)
+	}
+	if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry < 1 || retry > 30 {
+		t.Errorf("Retry-After 应为下一个令牌的等待秒数，得到 %q", resp.Header.Get("Retry-After"))
+	}
+
+	// 存储不可用时放行请求
+	rateLimitStore = failingStore{}
+	for i := 0; i < 3; i++ {
+		if resp := login(); resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("限流存储出错时应放行: %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) {
+		cfg.Auth.LockoutThreshold = 3
+		cfg.Auth.LockoutWindow = Duration{10 * time.Minute}
+		cfg.Auth.LockoutDuration = Duration{15 * time.Minute}
+	})
+	user := createTestUser(t, "alice", RoleUser)
+	login := func(username, password string) (int, string) {
+		t.Helper()
+		w := doJSON(r, http.MethodPost, "/api/v1/login", "", map[string]string{"username": username, "password": password})
+		return w.Code, fmt.Sprintf("%s|%s", errorCode(t, w), w.Header().Get("Retry-After"))
+	}
+	reload := func() User {
+		t.Helper()
+		var u User
+		DB.First(&u, user.ID)
+		return u
+	}
+
+	// 窗口之外的失败不累计
+	login("alice", "wrong")
+	DB.Model(&User{}).Where("id = ?", user.ID).Update("last_failed_login", time.Now().Add(-11*time.Minute))
+	login("alice", "wrong")
+	if u := reload(); u.FailedLogins != 1 || u.LockedUntil != nil {
+		t.Fatalf("上一次失败在窗口之外时应重新计数: failed=%d", u.FailedLogins)
+	}
+
+	login("alice", "wrong")
+	before := time.Now()
+	if code, resp := login("alice", "wrong"); code != http.StatusUnauthorized || resp != CodeInvalidCredentials+"|" {
+		t.Errorf("触发锁定的这次请求也只返回密码错误: %d %s", code, resp)
+	}
+	u := reload()
+	if u.LockedUntil == nil || u.LockedUntil.Sub(before) < 14*time.Minute || u.LockedUntil.Sub(before) > 16*time.Minute {
+		t.Fatalf("第 3 次失败后应锁定 15 分钟: %v", u.LockedUntil)
+	}
+
+	// 锁定的账号与不存在的用户返回完全相同的响应，不能用来探测用户名
+	lockedCode, locked := login("alice", "Passw0rd!x")
+	unknownCode, unknown := login("nobody", "Passw0rd!x")
+	if lockedCode != http.StatusUnauthorized || lockedCode != unknownCode || locked != unknown {
+		t.Errorf("锁定的账号应返回与密码错误相同的响应: %d %s / %d %s", lockedCode, locked, unknownCode, unknown)
+	}
+
+	// 锁定到期后可以登录，并清除失败记录
+	DB.Model(&User{}).Where("id = ?", user.ID).Update("locked_until", time.Now().Add(-time.Second))
+	if code, _ := login("alice", "Passw0rd!x"); code != http.StatusOK {
+		t.Fatalf("锁定到期后应可以登录: %d", code)
+	}
+	if u := reload(); u.FailedLogins != 0 || u.LockedUntil != nil || u.LastFailedLogin != nil {
+		t.Errorf("登录成功后应清除失败记录: %+v", u)
+	}
+}
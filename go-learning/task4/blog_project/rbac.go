@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 用户角色
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permission 表示一项操作权限
+type Permission string
+
+const (
+	PermPostUpdateAny    Permission = "post:update:any"    // 编辑任意文章
+	PermPostDeleteAny    Permission = "post:delete:any"    // 删除任意文章
+	PermCommentModerate  Permission = "comment:moderate"   // 审核、隐藏任意评论
+	PermCommentDeleteAny Permission = "comment:delete:any" // 删除任意评论
+	PermUserManage       Permission = "user:manage"        // 管理用户角色
+)
+
+// rolePermissions 定义每个角色拥有的权限
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermCommentModerate},
+	RoleAdmin:     {PermPostUpdateAny, PermPostDeleteAny, PermCommentModerate, PermCommentDeleteAny, PermUserManage},
+}
+
+// validRole 判断角色名是否合法
+func validRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission 判断角色是否拥有某项权限
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnerLookup 根据请求找到资源所有者的用户 ID
+type OwnerLookup func(c *gin.Context) (uint, error)
+
+// Policy 路由访问策略：拥有 Permission 的角色，或者资源所有者（Owner 不为空时）可以访问
+type Policy struct {
+	Permission Permission
+	Owner      OwnerLookup
+	Message    string // 拒绝访问时提示的消息 ID
+}
+
+// currentRole 从 Context 中读取当前用户角色
+func currentRole(c *gin.Context) string {
+	if role, ok := c.Get("role"); ok {
+		if r, ok := role.(string); ok && r != "" {
+			return r
+		}
+	}
+	return RoleUser
+}
+
+// RequirePermission 要求当前用户的角色拥有指定权限
+func RequirePermission(perm Permission) gin.HandlerFunc {
+	return Authorize(Policy{Permission: perm})
+}
+
+// Authorize 按照策略检查权限，需要放在 AuthMiddleware 之后
+func Authorize(policy Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Permission != "" && HasPermission(currentRole(c), policy.Permission) {
+			c.Next()
+			return
+		}
+
+		if policy.Owner != nil {
+			userID, _ := c.Get("userID")
+			ownerID, err := policy.Owner(c)
+			if err != nil {
+				var apiErr *APIError
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					abortWithError(c, newAPIError(http.StatusNotFound, CodeNotFound, "resource.not_found"))
+				} else if errors.As(err, &apiErr) {
+					abortWithError(c, apiErr)
+				} else {
+					abortWithError(c, internalError("auth.permission_check_failed", err))
+				}
+				return
+			}
+			if uid, ok := userID.(uint); ok && uid == ownerID {
+				c.Next()
+				return
+			}
+		}
+
+		message := policy.Message
+		if message == "" {
+			message = "auth.forbidden"
+		}
+		abortWithError(c, newAPIError(http.StatusForbidden, CodeForbidden, message))
+	}
+}
+
+// postOwner 返回路由参数 :id 对应文章的作者
+func postOwner(c *gin.Context) (uint, error) {
+	postID, ok := parseID(c.Param("id"))
+	if !ok {
+		return 0, errInvalidPostID
+	}
+	var post Post
+	if err := DB.Select("id", "user_id").First(&post, postID).Error; err != nil {
+		return 0, err
+	}
+	return post.UserID, nil
+}
+
+// bootstrapAdmin 把配置项 auth.bootstrap_admin（或环境变量 BLOG_BOOTSTRAP_ADMIN）指定的用户提升为管理员，
+// 用于初始化第一个管理员
+func bootstrapAdmin() {
+	username := currentConfig().Auth.BootstrapAdmin
+	if username == "" {
+		return
+	}
+	result := DB.Model(&User{}).Where("username = ?", username).Update("role", RoleAdmin)
+	if result.Error != nil {
+		log.Printf("设置初始管理员失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("用户 %s 已被设置为管理员", username)
+	}
+}
+
+// UpdateUserRoleHandler 修改用户角色（仅管理员）
+func UpdateUserRoleHandler(c *gin.Context) {
+	log.Printf("修改用户角色请求: 用户ID=%s", c.Param("id"))
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	if !validRole(req.Role) {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidRole, "user.invalid_role"))
+		return
+	}
+	userID, ok := parseID(c.Param("id"))
+	if !ok {
+		abortWithError(c, errInvalidID)
+		return
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, newAPIError(http.StatusNotFound, CodeUserNotFound, "user.not_found"))
+		} else {
+			abortWithError(c, internalError("db.query_failed", err))
+		}
+		return
+	}
+
+	if err := DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		abortWithError(c, internalError("user.role_update_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.role_updated"), "user_id": user.ID, "role": req.Role})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleUser, PermPostUpdateAny, false},
+		{RoleUser, PermCommentModerate, false},
+		{RoleModerator, PermCommentModerate, true},
+		{RoleModerator, PermCommentDeleteAny, false},
+		{RoleModerator, PermPostDeleteAny, false},
+		{RoleAdmin, PermPostUpdateAny, true},
+		{RoleAdmin, PermUserManage, true},
+		{"unknown", PermCommentModerate, false},
+	}
+	for _, tc := range cases {
+		if got := HasPermission(tc.role, tc.perm); got != tc.want {
+			t.Errorf("HasPermission(%q, %q) = %v", tc.role, tc.perm, got)
+		}
+	}
+}
+
+// 作者本人和拥有 post:update:any 的管理员可以编辑、删除文章，其他人（包括版主）不行
+func TestPostOwnershipPolicy(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	tokens := map[string]string{
+		"author":    testToken(t, author),
+		"other":     testToken(t, createTestUser(t, "other", RoleUser)),
+		"moderator": testToken(t, createTestUser(t, "moderator", RoleModerator)),
+		"admin":     testToken(t, createTestUser(t, "admin", RoleAdmin)),
+	}
+	post := createTestPost(t, author, "title", "content")
+	path := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+	body := map[string]string{"title": "edited", "content": "content"}
+
+	for _, tc := range []struct {
+		who  string
+		want int
+	}{
+		{"other", http.StatusForbidden},
+		{"moderator", http.StatusForbidden},
+		{"author", http.StatusOK},
+		{"admin", http.StatusOK},
+	} {
+		w := doJSON(r, http.MethodPut, path, tokens[tc.who], body)
+		if w.Code != tc.want {
+			t.Errorf("%s 编辑文章返回 %d，期望 %d: %s", tc.who, w.Code, tc.want, w.Body.String())
+		}
+	}
+	if w := doJSON(r, http.MethodPut, path, "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("未登录编辑文章返回 %d", w.Code)
+	}
+	if w := doJSON(r, http.MethodPut, "/api/v1/posts/999999", tokens["author"], body); w.Code != http.StatusNotFound {
+		t.Errorf("编辑不存在的文章返回 %d，期望 404", w.Code)
+	}
+
+	if w := doJSON(r, http.MethodDelete, path, tokens["other"], nil); w.Code != http.StatusForbidden {
+		t.Errorf("其他用户删除文章返回 %d，期望 403", w.Code)
+	}
+	if w := doJSON(r, http.MethodDelete, path, tokens["admin"], nil); w.Code != http.StatusOK {
+		t.Errorf("管理员删除文章返回 %d，期望 200: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	r := setupTestApp(t)
+	admin := testToken(t, createTestUser(t, "admin", RoleAdmin))
+	target := createTestUser(t, "target", RoleUser)
+	path := fmt.Sprintf("/api/v1/admin/users/%d/role", target.ID)
+
+	if w := doJSON(r, http.MethodPut, path, testToken(t, target), map[string]string{"role": RoleAdmin}); w.Code != http.StatusForbidden {
+		t.Fatalf("普通用户修改角色返回 %d，期望 403", w.Code)
+	}
+	if w := doJSON(r, http.MethodPut, path, admin, map[string]string{"role": "root"}); w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidRole {
+		t.Errorf("无效的角色返回 %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, http.MethodPut, "/api/v1/admin/users/999999/role", admin, map[string]string{"role": RoleModerator}); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的用户返回 %d，期望 404", w.Code)
+	}
+	if w := doJSON(r, http.MethodPut, path, admin, map[string]string{"role": RoleModerator}); w.Code != http.StatusOK {
+		t.Fatalf("管理员修改角色返回 %d: %s", w.Code, w.Body.String())
+	}
+	var user User
+	if err := DB.First(&user, target.ID).Error; err != nil || user.Role != RoleModerator {
+		t.Fatalf("角色应更新为 %s，得到 %q (%v)", RoleModerator, user.Role, err)
+	}
+}
+
+// 路径中的 ID 不是数字时直接返回 400，不能被当作 SQL 条件拼接到查询中
+func TestInvalidPathID(t *testing.T) {
+	r := setupTestApp(t)
+	admin := testToken(t, createTestUser(t, "admin", RoleAdmin))
+	author := createTestUser(t, "author", RoleUser)
+	createTestPost(t, author, "title", "content")
+	injected := url.PathEscape("(SELECT substr(password,1,3)='$2a' FROM users LIMIT 1)")
+
+	cases := []struct {
+		method, path, token string
+		body                interface{}
+	}{
+		{http.MethodGet, "/api/v1/posts/" + injected, "", nil},
+		{http.MethodGet, "/api/v1/posts/-1", "", nil},
+		{http.MethodPut, "/api/v1/posts/" + injected, testToken(t, author), map[string]string{"title": "t", "content": "c"}},
+		{http.MethodDelete, "/api/v1/posts/1%20OR%201=1", testToken(t, author), nil},
+		{http.MethodPut, "/api/v1/admin/users/" + injected + "/role", admin, map[string]string{"role": RoleAdmin}},
+	}
+	for _, tc := range cases {
+		w := doJSON(r, tc.method, tc.path, tc.token, tc.body)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeInvalidRequest {
+			t.Errorf("%s %s 返回 %d，期望 400: %s", tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+}
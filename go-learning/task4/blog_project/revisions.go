@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+var errInvalidRevisionVersion = newAPIError(http.StatusBadRequest, CodeInvalidRevisionVersion, "revision.invalid_version")
+
+// RevisionResponse 用于返回文章修订记录
+type RevisionResponse struct {
+	ID        uint      `json:"id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	EditorID  uint      `json:"editor_id"`
+	Editor    string    `json:"editor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// recordRevision 把文章当前的标题、内容和内容格式保存为一个新的修订版本
+func recordRevision(tx *gorm.DB, post *Post, editorID uint) (*PostRevision, error) {
+	var latest int
+	if err := tx.Model(&PostRevision{}).Where("post_id = ?", post.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	revision := PostRevision{
+		PostID:        post.ID,
+		Version:       latest + 1,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		EditorID:      editorID,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// ensureBaseRevision 为还没有修订记录的旧文章补一个初始版本，避免第一次更新时丢失原文
+func ensureBaseRevision(tx *gorm.DB, post *Post) error {
+	var count int64
+	if err := tx.Model(&PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := recordRevision(tx, post, post.UserID)
+	return err
+}
+
+// findRevision 按版本号查找文章的修订记录
+func findRevision(postID string, version string) (*PostRevision, error) {
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return nil, errInvalidRevisionVersion
+	}
+	var revision PostRevision
+	if err := DB.Where("post_id = ? AND version = ?", postID, v).First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// revisionText 把修订内容拼成用于比较的文本，标题作为第一行
+func revisionText(r *PostRevision) string {
+	return "# " + r.Title + "\n\n" + r.Content + "\n"
+}
+
+// ListRevisionsHandler 列出文章的所有修订版本
+func ListRevisionsHandler(c *gin.Context) {
+	log.Printf("获取修订列表: 文章ID=%s", c.Param("id"))
+	var revisions []PostRevision
+	if err := DB.Where("post_id = ?", c.Param("id")).Order("version desc").Find(&revisions).Error; err != nil {
+		abortWithError(c, internalError("revision.list_failed", err))
+		return
+	}
+
+	editorIDs := make([]uint, 0, len(revisions))
+	for _, r := range revisions {
+		editorIDs = append(editorIDs, r.EditorID)
+	}
+	userMap := loadUsernames(editorIDs)
+
+	resp := make([]RevisionResponse, 0, len(revisions))
+	for _, r := range revisions {
+		resp = append(resp, RevisionResponse{
+			ID:        r.ID,
+			Version:   r.Version,
+			Title:     r.Title,
+			EditorID:  r.EditorID,
+			Editor:    userMap[r.EditorID],
+			CreatedAt: r.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": resp})
+}
+
+// DiffRevisionsHandler 返回两个修订版本之间的统一格式 diff
+// 用法：GET /posts/:id/revisions/diff?from=1&to=2
+func DiffRevisionsHandler(c *gin.Context) {
+	log.Printf("比较修订版本: 文章ID=%s, from=%s, to=%s", c.Param("id"), c.Query("from"), c.Query("to"))
+	from, err := findRevision(c.Param("id"), c.Query("from"))
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+	to, err := findRevision(c.Param("id"), c.Query("to"))
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(from)),
+		B:        difflib.SplitLines(revisionText(to)),
+		FromFile: fmt.Sprintf("v%d", from.Version),
+		ToFile:   fmt.Sprintf("v%d", to.Version),
+		Context:  3,
+	})
+	if err != nil {
+		abortWithError(c, internalError("revision.diff_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": from.Version,
+		"to":   to.Version,
+		"diff": diff,
+	})
+}
+
+// RestoreRevisionHandler 把文章恢复到指定的修订版本，恢复本身也会生成一个新版本
+func RestoreRevisionHandler(c *gin.Context) {
+	userIDVal, _ := c.Get("userID")
+	log.Printf("恢复修订版本: 文章ID=%s, 版本=%s, 用户ID=%v", c.Param("id"), c.Param("version"), userIDVal)
+	userID := userIDVal.(uint)
+
+	revision, err := findRevision(c.Param("id"), c.Param("version"))
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	var post Post
+	var head *PostRevision
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&post, revision.PostID).Error; err != nil {
+			return err
+		}
+		post.Title = revision.Title
+		post.Content = revision.Content
+		post.ContentFormat = revision.ContentFormat
+		if err := tx.Save(&post).Error; err != nil {
+			return err
+		}
+		rev, err := recordRevision(tx, &post, userID)
+		head = rev
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortWithError(c, errPostNotFound)
+		} else {
+			abortWithError(c, internalError("revision.restore_failed", err))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       T(c, "revision.restored"),
+		"restored_from": revision.Version,
+		"version":       head.Version,
+		"post":          post,
+	})
+}
+
+// respondRevisionError 统一处理查找修订版本时的错误
+func respondRevisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInvalidRevisionVersion):
+		abortWithError(c, err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		abortWithError(c, newAPIError(http.StatusNotFound, CodeRevisionNotFound, "revision.not_found"))
+	default:
+		abortWithError(c, internalError("revision.get_failed", err))
+	}
+}
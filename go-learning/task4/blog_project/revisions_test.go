@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPostRevisions(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+	// 直接写入数据库的文章没有修订记录，第一次更新时补一个初始版本
+	post := createTestPost(t, author, "v1 title", "line one\nline two")
+	base := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+
+	w := doJSON(r, http.MethodPut, base, token, map[string]string{"title": "v2 title", "content": "line one\nline 2"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("更新文章返回 %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(r, http.MethodGet, base+"/revisions", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取修订列表返回 %d: %s", w.Code, w.Body.String())
+	}
+	revisions := decodeJSON(t, w)["revisions"].([]interface{})
+	if len(revisions) != 2 {
+		t.Fatalf("应有 2 个修订版本，得到 %d", len(revisions))
+	}
+	if latest := revisions[0].(map[string]interface{}); latest["version"] != float64(2) || latest["editor"] != "author" {
+		t.Errorf("修订列表应按版本倒序并带编辑者: %v", latest)
+	}
+
+	w = doJSON(r, http.MethodGet, base+"/revisions/diff?from=1&to=2", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("比较修订版本返回 %d: %s", w.Code, w.Body.String())
+	}
+	diff := decodeJSON(t, w)["diff"].(string)
+	for _, want := range []string{"--- v1", "+++ v2", "-# v1 title", "+# v2 title", "-line two", "+line 2", " line one"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff 中缺少 %q:\n%s", want, diff)
+		}
+	}
+
+	w = doJSON(r, http.MethodPost, base+"/revisions/1/restore", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("恢复修订版本返回 %d: %s", w.Code, w.Body.String())
+	}
+	if body := decodeJSON(t, w); body["version"] != float64(3) || body["restored_from"] != float64(1) {
+		t.Errorf("恢复操作应生成版本 3: %v", body)
+	}
+	var restored Post
+	DB.First(&restored, post.ID)
+	if restored.Title != "v1 title" || restored.Content != "line one\nline two" {
+		t.Errorf("恢复后的文章为 %q %q", restored.Title, restored.Content)
+	}
+}
+
+func TestPostRevisionErrors(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+	post := createTestPost(t, author, "title", "content")
+	base := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+
+	cases := []struct {
+		name, method, path, token string
+		status                    int
+		code                      string
+	}{
+		{"版本号不是数字", http.MethodGet, base + "/revisions/diff?from=a&to=1", token, http.StatusBadRequest, CodeInvalidRevisionVersion},
+		{"版本号为 0", http.MethodPost, base + "/revisions/0/restore", token, http.StatusBadRequest, CodeInvalidRevisionVersion},
+		{"版本不存在", http.MethodPost, base + "/revisions/9/restore", token, http.StatusNotFound, CodeRevisionNotFound},
+		{"其他用户", http.MethodGet, base + "/revisions", testToken(t, createTestUser(t, "other", RoleUser)), http.StatusForbidden, CodeForbidden},
+	}
+	for _, tc := range cases {
+		w := doJSON(r, tc.method, tc.path, tc.token, nil)
+		if w.Code != tc.status || errorCode(t, w) != tc.code {
+			t.Errorf("%s: 返回 %d %s，期望 %d %s", tc.name, w.Code, errorCode(t, w), tc.status, tc.code)
+		}
+	}
+}
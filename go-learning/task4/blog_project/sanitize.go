@@ -0,0 +1,308 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML 过滤：按白名单保留标签和属性，防止文章内容中的 XSS
+// 不在白名单中的标签去掉标签本身、保留文字；script、style 等标签连同内容一起删除；注释全部删除
+
+// sanitizeAllowedTags 允许的标签及其允许的属性
+var sanitizeAllowedTags = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "div": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"blockquote": nil, "pre": nil, "code": {"class"}, "span": {"class"},
+	"em": nil, "strong": nil, "b": nil, "i": nil, "u": nil, "s": nil, "del": nil, "ins": nil,
+	"sup": nil, "sub": nil, "kbd": nil, "mark": nil, "small": nil,
+	"a":   {"href", "title"},
+	"img": {"src", "alt", "title", "width", "height"},
+	"ul":  nil, "ol": {"start"}, "li": nil, "dl": nil, "dt": nil, "dd": nil,
+	"table": nil, "thead": nil, "tbody": nil, "tfoot": nil, "tr": nil,
+	"th": {"align"}, "td": {"align"},
+}
+
+// sanitizeDroppedTags 连同内容一起删除的标签
+var sanitizeDroppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true, "object": true, "embed": true,
+	"applet": true, "noscript": true, "noembed": true, "template": true, "textarea": true, "select": true,
+	"title": true, "head": true, "svg": true, "math": true, "xmp": true, "plaintext": true,
+}
+
+// sanitizeVoidTags 没有结束标签的元素，计算嵌套层数时不计入
+var sanitizeVoidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"keygen": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// sanitizeMaxDepth 标签最多嵌套的层数
+// html 解析器遇到块级标签时会扫描一遍打开的标签栈，上万层嵌套的 <div> 要解析好几秒，所以解析前先去掉过深的标签
+const sanitizeMaxDepth = 256
+
+var (
+	sanitizeCodeClass = regexp.MustCompile(`^language-[A-Za-z0-9_+#-]+$`)
+	sanitizeSpanClass = regexp.MustCompile(`^hl-[a-z]+$`)
+	sanitizeNumber    = regexp.MustCompile(`^[0-9]{1,5}$`)
+)
+
+// TOCEntry 目录中的一项，ID 为标题的锚点
+type TOCEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// sanitizeHTML 过滤 HTML，同时给标题加上锚点 id 并生成目录
+func sanitizeHTML(src string) (string, []TOCEntry) {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(limitNesting(src)), context)
+	if err != nil {
+		// 解析器只会在读取失败时返回错误，这里退回到完全转义
+		return html.EscapeString(src), nil
+	}
+	s := &sanitizer{ids: make(map[string]bool)}
+	for _, n := range nodes {
+		s.walk(n)
+	}
+	return s.out.String(), s.toc
+}
+
+// limitNesting 用分词器（线性时间）去掉嵌套超过 sanitizeMaxDepth 层的开始和结束标签，标签中的文字保留
+// 这里按标签简单计数，不考虑 <p>、<li> 等可以省略结束标签的情况，只会多去掉标签，不会影响过滤的安全性
+func limitNesting(src string) string {
+	if strings.Count(src, "<") <= sanitizeMaxDepth {
+		return src
+	}
+	z := html.NewTokenizer(strings.NewReader(src))
+	var b strings.Builder
+	depth := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); sanitizeVoidTags[string(name)] {
+				break
+			}
+			depth++
+			if depth > sanitizeMaxDepth {
+				continue
+			}
+		case html.EndTagToken:
+			dropped := depth > sanitizeMaxDepth
+			if depth > 0 {
+				depth--
+			}
+			if dropped {
+				continue
+			}
+		}
+		b.Write(z.Raw())
+	}
+}
+
+type sanitizer struct {
+	out strings.Builder
+	toc []TOCEntry
+	ids map[string]bool // 已使用的锚点，重复的标题加上 -1、-2 后缀
+}
+
+func (s *sanitizer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		s.out.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// 注释、doctype 等全部丢弃
+		return
+	}
+
+	tag := n.Data
+	if sanitizeDroppedTags[tag] {
+		return
+	}
+	allowed, ok := sanitizeAllowedTags[tag]
+	if !ok {
+		s.children(n)
+		return
+	}
+
+	attrs := sanitizeAttrs(tag, n.Attr, allowed)
+	if tag == "img" && !hasAttr(attrs, "src") {
+		return
+	}
+	if level := headingLevel(tag); level > 0 {
+		text := strings.Join(strings.Fields(textContent(n)), " ")
+		id := s.uniqueID(slugify(text))
+		attrs = append([]html.Attribute{{Key: "id", Val: id}}, attrs...)
+		s.toc = append(s.toc, TOCEntry{Level: level, ID: id, Text: text})
+	}
+
+	s.out.WriteString("<" + tag)
+	for _, a := range attrs {
+		s.out.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	if tag == "br" || tag == "hr" || tag == "img" {
+		s.out.WriteString(" />")
+		return
+	}
+	s.out.WriteString(">")
+	s.children(n)
+	s.out.WriteString("</" + tag + ">")
+}
+
+func (s *sanitizer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.walk(c)
+	}
+}
+
+// uniqueID 返回未使用过的锚点
+func (s *sanitizer) uniqueID(slug string) string {
+	if slug == "" {
+		slug = "section"
+	}
+	id := slug
+	for n := 1; s.ids[id]; n++ {
+		id = slug + "-" + strconv.Itoa(n)
+	}
+	s.ids[id] = true
+	return id
+}
+
+// sanitizeAttrs 只保留白名单中的属性，并检查属性值
+func sanitizeAttrs(tag string, attrs []html.Attribute, allowed []string) []html.Attribute {
+	var out []html.Attribute
+	for _, a := range attrs {
+		if a.Namespace != "" || !containsString(allowed, a.Key) {
+			continue
+		}
+		val := strings.TrimSpace(a.Val)
+		switch {
+		case a.Key == "href":
+			if !safeURL(val, true) {
+				continue
+			}
+		case a.Key == "src":
+			if !safeURL(val, false) {
+				continue
+			}
+		case a.Key == "class" && tag == "code":
+			if !sanitizeCodeClass.MatchString(val) {
+				continue
+			}
+		case a.Key == "class":
+			if !sanitizeSpanClass.MatchString(val) {
+				continue
+			}
+		case a.Key == "align":
+			if val != "left" && val != "center" && val != "right" {
+				continue
+			}
+		case a.Key == "start" || a.Key == "width" || a.Key == "height":
+			if !sanitizeNumber.MatchString(val) {
+				continue
+			}
+		}
+		out = append(out, html.Attribute{Key: a.Key, Val: val})
+	}
+	// 外部链接不传递页面权重，也不暴露 window.opener
+	if tag == "a" && hasAttr(out, "href") {
+		out = append(out, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	}
+	return out
+}
+
+// safeURL 只允许 http、https（链接还允许 mailto）和相对地址，拒绝 javascript:、data: 等
+func safeURL(raw string, allowMailto bool) bool {
+	if raw == "" {
+		return false
+	}
+	for _, r := range raw {
+		if r < 0x20 || r == 0x7f || unicode.IsSpace(r) && r != ' ' {
+			return false
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https":
+		return true
+	case "mailto":
+		return allowMailto
+	}
+	return false
+}
+
+func hasAttr(attrs []html.Attribute, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// headingLevel 返回 h1-h6 的级别，其他标签返回 0
+func headingLevel(tag string) int {
+	if len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6' {
+		return int(tag[1] - '0')
+	}
+	return 0
+}
+
+// textContent 返回节点中的文字，跳过会被删除的标签
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+		if n.Type != html.ElementNode || sanitizeDroppedTags[n.Data] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// slugify 把标题转换为锚点：字母、数字（包括中文）保留并转为小写，其余字符替换为 -
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"javascript 链接", `<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"大小写混合", `<a href="JaVaScRiPt:alert(1)">x</a>`, "<a>x</a>"},
+		{"控制字符", "<a href=\"java\tscript:alert(1)\">x</a>", "<a>x</a>"},
+		{"实体编码", `<a href="&#106;avascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"vbscript 链接", `<a href="vbscript:msgbox(1)">x</a>`, "<a>x</a>"},
+		{"data 链接", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, "<a>x</a>"},
+		{"data 图片", `<img src="data:image/svg+xml,<svg onload=alert(1)>">`, ""},
+		{"mailto 图片", `<img src="mailto:a@example.com">`, ""},
+		{"允许的链接", `<a href="mailto:a@example.com">m</a><a href="/p/1">r</a>`,
+			`<a href="mailto:a@example.com" rel="nofollow noopener noreferrer">m</a><a href="/p/1" rel="nofollow noopener noreferrer">r</a>`},
+		{"覆盖 rel 和 target", `<a href="https://example.com" rel="opener" target="_blank">x</a>`,
+			`<a href="https://example.com" rel="nofollow noopener noreferrer">x</a>`},
+		{"事件属性", `<img src="/a.png" onerror="alert(1)"><p onclick="x" style="color:red">p</p>`, `<img src="/a.png" /><p>p</p>`},
+		{"svg", `<svg><script>alert(1)</script><a href="#">x</a></svg>y`, "y"},
+		{"math", `<math><mtext><img src=x onerror=alert(1)></mtext></math>y`, "y"},
+		{"script 和 style", `<script>alert(1)</script><style>p{}</style>y`, "y"},
+		{"iframe", `<iframe src="https://example.com">x</iframe>y`, "y"},
+		{"未知标签保留文字", `<custom>x</custom><form><input>y</form>`, "xy"},
+		{"注释", `a<!-- <script>alert(1)</script> -->b`, "ab"},
+		{"代码 class", `<code class="language-go">a</code><code class="x onload">b</code>`, `<code class="language-go">a</code><code>b</code>`},
+		{"高亮 class", `<span class="hl-keyword">a</span><span class="evil">b</span>`, `<span class="hl-keyword">a</span><span>b</span>`},
+		{"数值属性", `<ol start="3"><li>a</li></ol><img src="/a.png" width="100%">`, `<ol start="3"><li>a</li></ol><img src="/a.png" />`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := sanitizeHTML(tc.in); got != tc.want {
+				t.Errorf("过滤结果不符\n输入: %q\n得到: %q\n期望: %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeTOC(t *testing.T) {
+	out, toc := sanitizeHTML(`<h1>Hello World</h1><h2>Hello World</h2><h3><em>!</em></h3>`)
+	want := []TOCEntry{{1, "hello-world", "Hello World"}, {2, "hello-world-1", "Hello World"}, {3, "section", "!"}}
+	if len(toc) != len(want) {
+		t.Fatalf("目录 %+v，期望 %+v", toc, want)
+	}
+	for i := range want {
+		if toc[i] != want[i] {
+			t.Errorf("目录第 %d 项为 %+v，期望 %+v", i, toc[i], want[i])
+		}
+	}
+	if !strings.Contains(out, `<h2 id="hello-world-1">`) {
+		t.Errorf("标题缺少锚点: %s", out)
+	}
+}
+
+func TestLimitNesting(t *testing.T) {
+	in := strings.Repeat("<div>", sanitizeMaxDepth+10) + "x" + strings.Repeat("<br>", 10) + strings.Repeat("</div>", sanitizeMaxDepth+10)
+	out, _ := sanitizeHTML(in)
+	if n := strings.Count(out, "<div>"); n != sanitizeMaxDepth {
+		t.Errorf("保留了 %d 层 div，期望 %d 层", n, sanitizeMaxDepth)
+	}
+	if strings.Count(out, "<br />") != 10 || !strings.Contains(out, "x") {
+		t.Errorf("过深部分的文字和空元素应该保留: %s", out)
+	}
+	// 层数不超过上限的内容原样交给解析器
+	small := "<ul><li>a</li></ul>"
+	if got := limitNesting(small); got != small {
+		t.Errorf("limitNesting(%q) = %q", small, got)
+	}
+}
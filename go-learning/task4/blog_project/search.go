@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 全文检索基于 SQLite FTS5，go-sqlite3 需要使用 sqlite_fts5 构建标签：
+//
+//	go build -tags sqlite_fts5
+//
+// 使用 trigram 分词器，中文内容不依赖空格分词也能检索，但关键词至少需要 3 个字符。
+// 使用 PostgreSQL / MySQL 或 FTS5 不可用时退化为 LIKE 匹配，见 searchWithLike。
+const searchMinQueryLen = 3
+
+var errSearchDisabled = errors.New("全文检索未启用")
+
+// FTS5 高亮时先用控制字符标记关键词，转义全部文本后再替换为 <mark>，
+// 否则标题和正文中的 HTML 会原样出现在搜索结果里
+const (
+	searchMarkOpen  = "\x01"
+	searchMarkClose = "\x02"
+)
+
+var searchMarkReplacer = strings.NewReplacer(searchMarkOpen, "<mark>", searchMarkClose, "</mark>")
+
+// markHighlights 转义文本，并把高亮标记替换为 <mark>
+func markHighlights(s string) string {
+	return searchMarkReplacer.Replace(html.EscapeString(s))
+}
+
+// searchEnabled 表示 FTS5 索引表是否可用，不可用时 GORM 钩子不做任何事，搜索使用 LIKE 匹配
+var searchEnabled bool
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Type    string  `json:"type"` // post 或 comment
+	ID      uint    `json:"id"`
+	PostID  uint    `json:"post_id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"` // bm25 得分，越小越相关
+}
+
+// initSearchIndex 创建 FTS5 虚拟表，非 SQLite 数据库直接使用 LIKE 匹配
+func initSearchIndex(db *gorm.DB) {
+	if !isSQLite(db) {
+		log.Printf("%s 数据库不支持 FTS5，搜索将使用 LIKE 匹配", db.Dialector.Name())
+		return
+	}
+	err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		kind UNINDEXED, ref_id UNINDEXED, post_id UNINDEXED, title, body,
+		tokenize = 'trigram'
+	)`).Error
+	if err != nil {
+		log.Printf("全文检索不可用（需要使用 -tags sqlite_fts5 构建），搜索将使用 LIKE 匹配: %v", err)
+		return
+	}
+	searchEnabled = true
+}
+
+// indexPost 从 posts 表重新读取文章并写入索引，已删除的文章会从索引中移除
+func indexPost(tx *gorm.DB, postID uint) error {
+	if !searchEnabled || postID == 0 {
+		return nil
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	if err := db.Exec("DELETE FROM search_index WHERE kind = 'post' AND ref_id = ?", postID).Error; err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO search_index (kind, ref_id, post_id, title, body)
+		SELECT 'post', id, id, title, content FROM posts WHERE id = ? AND deleted_at IS NULL`, postID).Error
+}
+
+// indexComment 从 comments 表重新读取评论并写入索引
+func indexComment(tx *gorm.DB, commentID uint) error {
+	if !searchEnabled || commentID == 0 {
+		return nil
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	if err := db.Exec("DELETE FROM search_index WHERE kind = 'comment' AND ref_id = ?", commentID).Error; err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO search_index (kind, ref_id, post_id, title, body)
+		SELECT 'comment', id, post_id, '', content FROM comments WHERE id = ? AND deleted_at IS NULL`, commentID).Error
+}
+
+// AfterSave 文章保存后同步全文索引
+func (p *Post) AfterSave(tx *gorm.DB) error {
+	return indexPost(tx, p.ID)
+}
+
+// AfterDelete 文章删除后同步全文索引
+func (p *Post) AfterDelete(tx *gorm.DB) error {
+	return indexPost(tx, p.ID)
+}
+
+// AfterSave 评论保存后同步全文索引
+func (cm *Comment) AfterSave(tx *gorm.DB) error {
+	return indexComment(tx, cm.ID)
+}
+
+// AfterDelete 评论删除后同步全文索引
+func (cm *Comment) AfterDelete(tx *gorm.DB) error {
+	return indexComment(tx, cm.ID)
+}
+
+// rebuildSearchIndex 清空并重建全部索引
+func rebuildSearchIndex(db *gorm.DB) error {
+	if !searchEnabled {
+		return errSearchDisabled
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM search_index").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`INSERT INTO search_index (kind, ref_id, post_id, title, body)
+			SELECT 'post', id, id, title, content FROM posts WHERE deleted_at IS NULL`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`INSERT INTO search_index (kind, ref_id, post_id, title, body)
+			SELECT 'comment', id, post_id, '', content FROM comments WHERE deleted_at IS NULL`).Error
+	})
+}
+
+// buildMatchQuery 把用户输入转换成 FTS5 查询：每个词作为一个短语，多个词之间是 AND 关系
+func buildMatchQuery(q string) string {
+	terms := strings.Fields(q)
+	phrases := make([]string, 0, len(terms))
+	for _, t := range terms {
+		phrases = append(phrases, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+// SearchHandler 全文搜索文章和评论
+// 用法：GET /search?q=关键词&limit=20
+func SearchHandler(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	log.Printf("全文搜索: q=%s", q)
+	for _, term := range strings.Fields(q) {
+		if utf8.RuneCountInString(term) < searchMinQueryLen {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeSearchQuery, "search.term_too_short", searchMinQueryLen).
+				WithDetails(gin.H{"min_length": searchMinQueryLen}))
+			return
+		}
+	}
+	if q == "" {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeSearchQuery, "search.query_required"))
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 50 {
+		limit = 20
+	}
+
+	var results []SearchResult
+	if !searchEnabled {
+		results, err = searchWithLike(c, strings.Fields(q), limit)
+		if err != nil {
+			abortWithError(c, internalError("search.failed", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
+		return
+	}
+
+	err = DB.Table("search_index").
+		Select(`search_index.kind AS type, search_index.ref_id AS id, search_index.post_id AS post_id,
+			CASE WHEN search_index.kind = 'post' THEN highlight(search_index, 3, ?, ?) ELSE posts.title END AS title,
+			snippet(search_index, 4, ?, ?, '…', 16) AS snippet,
+			bm25(search_index, 0, 0, 0, 10.0, 1.0) AS rank`, searchMarkOpen, searchMarkClose, searchMarkOpen, searchMarkClose).
+		Joins("JOIN posts ON posts.id = search_index.post_id AND posts.deleted_at IS NULL").
+		Joins("LEFT JOIN comments ON search_index.kind = 'comment' AND comments.id = search_index.ref_id").
+		Where("search_index MATCH ?", buildMatchQuery(q)).
+		Where("search_index.kind = 'post' OR (comments.status = ? AND comments.deleted_at IS NULL)", CommentStatusApproved).
+		Scopes(visiblePosts(c)).
+		Order("rank").
+		Limit(limit).
+		Scan(&results).Error
+	if err != nil {
+		abortWithError(c, internalError("search.failed", err))
+		return
+	}
+	for i := range results {
+		results[i].Title = markHighlights(results[i].Title)
+		results[i].Snippet = markHighlights(results[i].Snippet)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
+}
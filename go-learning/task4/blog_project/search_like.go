@@ -0,0 +1,152 @@
+package main
+
+import (
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// likeEscape 是 LIKE 的转义字符；不用反斜杠，因为 MySQL 和 PostgreSQL 对字符串中反斜杠的处理不同
+const likeEscape = "!"
+
+// likeSnippetRunes 摘要中关键词前后保留的字符数
+const likeSnippetRunes = 24
+
+// likePattern 把关键词转换成小写的 LIKE 模式，并转义其中的通配符
+func likePattern(term string) string {
+	r := strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+	return "%" + r.Replace(strings.ToLower(term)) + "%"
+}
+
+// searchWithLike 在没有 FTS5 时用 LIKE 搜索文章和评论，所有关键词都需要匹配
+// 相关度按关键词出现次数计算（标题权重为 10），取负数以便与 bm25 一样越小越相关
+func searchWithLike(c *gin.Context, terms []string, limit int) ([]SearchResult, error) {
+	var posts []Post
+	postQuery := DB.Model(&Post{}).Select("posts.id, posts.title, posts.content").Scopes(visiblePosts(c))
+	for _, t := range terms {
+		p := likePattern(t)
+		postQuery = postQuery.Where("(LOWER(posts.title) LIKE ? ESCAPE '"+likeEscape+"' OR LOWER(posts.content) LIKE ? ESCAPE '"+likeEscape+"')", p, p)
+	}
+	if err := postQuery.Order("posts.created_at desc").Limit(limit).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	var comments []struct {
+		ID      uint
+		PostID  uint
+		Title   string
+		Content string
+	}
+	commentQuery := DB.Table("comments").
+		Select("comments.id, comments.post_id, posts.title, comments.content").
+		Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+		Where("comments.status = ? AND comments.deleted_at IS NULL", CommentStatusApproved).
+		Scopes(visiblePosts(c))
+	for _, t := range terms {
+		commentQuery = commentQuery.Where("LOWER(comments.content) LIKE ? ESCAPE '"+likeEscape+"'", likePattern(t))
+	}
+	if err := commentQuery.Order("comments.created_at desc").Limit(limit).Scan(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(posts)+len(comments))
+	for _, p := range posts {
+		results = append(results, SearchResult{
+			Type:    "post",
+			ID:      p.ID,
+			PostID:  p.ID,
+			Title:   highlightTerms(p.Title, terms),
+			Snippet: makeSnippet(p.Content, terms),
+			Rank:    -float64(10*countTerms(p.Title, terms) + countTerms(p.Content, terms)),
+		})
+	}
+	for _, cm := range comments {
+		results = append(results, SearchResult{
+			Type:    "comment",
+			ID:      cm.ID,
+			PostID:  cm.PostID,
+			Title:   html.EscapeString(cm.Title),
+			Snippet: makeSnippet(cm.Content, terms),
+			Rank:    -float64(countTerms(cm.Content, terms)),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank < results[j].Rank })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// countTerms 统计所有关键词在文本中出现的次数（忽略大小写）
+func countTerms(text string, terms []string) int {
+	lower := strings.ToLower(text)
+	n := 0
+	for _, t := range terms {
+		n += strings.Count(lower, strings.ToLower(t))
+	}
+	return n
+}
+
+// highlightTerms 转义文本，并用 <mark> 标记其中出现的关键词（忽略大小写）
+func highlightTerms(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// 极少数字符小写后长度会变化，此时不做高亮
+		return html.EscapeString(text)
+	}
+	marked := make([]bool, len(runes))
+	for _, t := range terms {
+		tr := []rune(strings.ToLower(t))
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if string(lower[i:i+len(tr)]) == string(tr) {
+				for k := i; k < i+len(tr); k++ {
+					marked[k] = true
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	return b.String()
+}
+
+// makeSnippet 截取第一个关键词附近的一段文本并高亮，格式与 FTS5 的 snippet() 保持一致
+func makeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := strings.ToLower(text)
+	start := -1
+	for _, t := range terms {
+		if idx := strings.Index(lower, strings.ToLower(t)); idx >= 0 {
+			pos := len([]rune(lower[:idx]))
+			if start < 0 || pos < start {
+				start = pos
+			}
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	from, to := start-likeSnippetRunes, start+2*likeSnippetRunes
+	prefix, suffix := "…", "…"
+	if from <= 0 {
+		from, prefix = 0, ""
+	}
+	if to >= len(runes) {
+		to, suffix = len(runes), ""
+	}
+	return prefix + highlightTerms(string(runes[from:to]), terms) + suffix
+}
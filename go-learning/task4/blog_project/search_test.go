@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 搜索结果中的标题和摘要必须先转义，只保留高亮用的 <mark>
+func TestSearchEscapesHTML(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	post := Post{
+		Title:   "xss <img src=x onerror=alert(1)> golang",
+		Content: "before <script>alert(1)</script> golang after",
+		Status:  PostStatusPublished,
+		UserID:  author.ID,
+	}
+	if err := DB.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	comment := Comment{Content: "reply <b onclick=x>golang</b>", PostID: post.ID, UserID: author.ID, Status: CommentStatusApproved}
+	if err := DB.Create(&comment).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	w := doJSON(r, http.MethodGet, "/api/v1/search?q=golang", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("搜索失败: %d %s", w.Code, w.Body.String())
+	}
+	results, _ := decodeJSON(t, w)["results"].([]interface{})
+	if len(results) != 2 {
+		t.Fatalf("应搜索到文章和评论，得到 %d 条: %s", len(results), w.Body.String())
+	}
+	for _, item := range results {
+		res := item.(map[string]interface{})
+		for _, field := range []string{"title", "snippet"} {
+			text, _ := res[field].(string)
+			stripped := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(text)
+			if strings.ContainsAny(stripped, "<>") {
+				t.Errorf("%s 的 %s 含有未转义的 HTML: %q", res["type"], field, text)
+			}
+		}
+	}
+
+	first := results[0].(map[string]interface{})
+	if title := first["title"].(string); first["type"] == "post" &&
+		title != "xss &lt;img src=x onerror=alert(1)&gt; <mark>golang</mark>" {
+		t.Errorf("文章标题高亮结果不对: %q", title)
+	}
+}
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Hello Golang", []string{"golang"}, "Hello <mark>Golang</mark>"},
+		{"<b>go</b>lang", []string{"lang"}, "&lt;b&gt;go&lt;/b&gt;<mark>lang</mark>"},
+		{"a & b", []string{"zzz"}, "a &amp; b"},
+		{"中文检索测试", []string{"检索"}, "中文<mark>检索</mark>测试"},
+	}
+	for _, tt := range tests {
+		if got := highlightTerms(tt.text, tt.terms); got != tt.want {
+			t.Errorf("highlightTerms(%q, %v) = %q，期望 %q", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestMarkHighlights(t *testing.T) {
+	got := markHighlights("<i>" + searchMarkOpen + "go" + searchMarkClose + "</i>")
+	if want := "&lt;i&gt;<mark>go</mark>&lt;/i&gt;"; got != want {
+		t.Fatalf("markHighlights = %q，期望 %q", got, want)
+	}
+}
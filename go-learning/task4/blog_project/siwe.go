@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/sha3"
+	"gorm.io/gorm"
+)
+
+// Sign-In with Ethereum（EIP-4361）：用户用钱包对一段固定格式的文本签名来登录
+// 服务端用 ecrecover 从签名中恢复出签名者的地址，与消息中的地址比对，整个过程不需要访问区块链
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// siweClockSkew 允许客户端与服务端之间的时钟误差
+const siweClockSkew = time.Minute
+
+var (
+	errSIWEMessage       = errors.New("SIWE 消息格式错误")
+	errSIWENonceInvalid  = errors.New("SIWE nonce 无效、已使用或已过期")
+	errInvalidSignature  = errors.New("签名无效")
+	walletAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	siweNoncePattern     = regexp.MustCompile(`^[A-Za-z0-9]{8,}$`)
+)
+
+// SIWEMessage 解析后的 EIP-4361 消息
+type SIWEMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// parseSIWEMessage 按 EIP-4361 的格式解析消息，格式如下（方括号内为可选行）：
+//
+//	example.com wants you to sign in with your Ethereum account:
+//	0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+//
+//	[Statement]
+//
+//	URI: https://example.com/login
+//	Version: 1
+//	Chain ID: 1
+//	Nonce: 32891756
+//	Issued At: 2021-09-30T16:25:24Z
+//	[Expiration Time: ...]
+//	[Not Before: ...]
+//	[Request ID: ...]
+//	[Resources:
+//	- https://example.com/a]
+func parseSIWEMessage(text string) (*SIWEMessage, error) {
+	lines := strings.Split(text, "\n")
+	i := 0
+	next := func() (string, bool) {
+		if i >= len(lines) {
+			return "", false
+		}
+		i++
+		return lines[i-1], true
+	}
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", errSIWEMessage, fmt.Sprintf(format, args...))
+	}
+
+	msg := &SIWEMessage{}
+	header, _ := next()
+	if !strings.HasSuffix(header, siweHeaderSuffix) {
+		return nil, fail("第 1 行不是 SIWE 消息头")
+	}
+	msg.Domain = strings.TrimSuffix(header, siweHeaderSuffix)
+	if scheme := strings.Index(msg.Domain, "://"); scheme >= 0 {
+		msg.Domain = msg.Domain[scheme+3:]
+	}
+	if msg.Domain == "" {
+		return nil, fail("缺少 domain")
+	}
+
+	msg.Address, _ = next()
+	if !walletAddressPattern.MatchString(msg.Address) {
+		return nil, fail("地址格式错误")
+	}
+	// EIP-4361 要求地址使用 EIP-55 校验和格式
+	if raw, _ := hex.DecodeString(msg.Address[2:]); checksumAddress(raw) != msg.Address {
+		return nil, fail("地址不是 EIP-55 校验和格式")
+	}
+
+	// 地址之后是一个空行，然后是可选的声明和一个空行
+	if line, _ := next(); line != "" {
+		return nil, fail("地址后缺少空行")
+	}
+	if line, ok := next(); !ok {
+		return nil, fail("消息不完整")
+	} else if line != "" {
+		msg.Statement = line
+		if line, _ := next(); line != "" {
+			return nil, fail("声明后缺少空行")
+		}
+	}
+
+	// field 读取一行 "名称: 值"，optional 为 true 且名称不匹配时不消耗这一行
+	field := func(name string, optional bool) (string, error) {
+		if i < len(lines) && strings.HasPrefix(lines[i], name+": ") {
+			line, _ := next()
+			return strings.TrimPrefix(line, name+": "), nil
+		}
+		if optional {
+			return "", nil
+		}
+		return "", fail("缺少 %s", name)
+	}
+	parseTime := func(name, value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fail("%s 不是 RFC 3339 格式的时间", name)
+		}
+		return &t, nil
+	}
+
+	var err error
+	if msg.URI, err = field("URI", false); err != nil {
+		return nil, err
+	}
+	if msg.Version, err = field("Version", false); err != nil {
+		return nil, err
+	}
+	if msg.Version != "1" {
+		return nil, fail("不支持的版本 %q", msg.Version)
+	}
+	chainID, err := field("Chain ID", false)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ChainID, err = strconv.Atoi(chainID); err != nil || msg.ChainID <= 0 {
+		return nil, fail("Chain ID 格式错误")
+	}
+	if msg.Nonce, err = field("Nonce", false); err != nil {
+		return nil, err
+	}
+	if !siweNoncePattern.MatchString(msg.Nonce) {
+		return nil, fail("Nonce 必须是至少 8 位的字母或数字")
+	}
+	issuedAt, err := field("Issued At", false)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := parseTime("Issued At", issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	msg.IssuedAt = *issued
+
+	expiration, _ := field("Expiration Time", true)
+	if msg.ExpirationTime, err = parseTime("Expiration Time", expiration); err != nil {
+		return nil, err
+	}
+	notBefore, _ := field("Not Before", true)
+	if msg.NotBefore, err = parseTime("Not Before", notBefore); err != nil {
+		return nil, err
+	}
+	msg.RequestID, _ = field("Request ID", true)
+
+	if i < len(lines) && lines[i] == "Resources:" {
+		next()
+		for i < len(lines) && strings.HasPrefix(lines[i], "- ") {
+			line, _ := next()
+			msg.Resources = append(msg.Resources, strings.TrimPrefix(line, "- "))
+		}
+	}
+	if i < len(lines) {
+		return nil, fail("第 %d 行无法识别", i+1)
+	}
+	return msg, nil
+}
+
+// keccak256 以太坊使用的 Keccak-256（与标准 SHA3-256 的填充方式不同）
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// personalMessageHash 计算 EIP-191 personal_sign 的消息哈希，钱包签名时会加上这个前缀
+func personalMessageHash(message string) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return keccak256([]byte(prefix), []byte(message))
+}
+
+// checksumAddress 把 20 字节的地址转换为 EIP-55 校验和格式
+// 对小写十六进制地址做 keccak256，哈希中对应位置的半字节 >= 8 时该字母大写
+func checksumAddress(addr []byte) string {
+	lower := hex.EncodeToString(addr)
+	hash := keccak256([]byte(lower))
+	out := []byte(lower)
+	for i, ch := range out {
+		if ch < 'a' {
+			continue
+		}
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if nibble&0x0f >= 8 {
+			out[i] = ch - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
+
+// recoverAddress 即 ecrecover：从 65 字节的签名 r || s || v 中恢复出签名者的地址
+func recoverAddress(hash, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", errInvalidSignature
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", errInvalidSignature
+	}
+	// decred 的紧凑签名格式为 <27 + 恢复 ID> || r || s，以太坊地址由未压缩的公钥计算
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], sig[:64])
+	pubKey, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", errInvalidSignature
+	}
+	// 地址是公钥（去掉 0x04 前缀）keccak256 哈希的后 20 字节
+	return checksumAddress(keccak256(pubKey.SerializeUncompressed()[1:])[12:]), nil
+}
+
+// SIWERequest 提交签名的请求体
+type SIWERequest struct {
+	Message   string `json:"message" binding:"required,max=4096"`
+	Signature string `json:"signature" binding:"required,max=200"`
+}
+
+// verifySIWE 校验消息内容和签名，返回解析后的消息；nonce 由调用方在事务中消费
+func verifySIWE(req SIWERequest) (*SIWEMessage, *APIError) {
+	errMessage := func(messageID string) *APIError {
+		return newAPIError(http.StatusBadRequest, CodeSIWEInvalidMessage, messageID)
+	}
+
+	msg, err := parseSIWEMessage(strings.ReplaceAll(req.Message, "\r\n", "\n"))
+	if err != nil {
+		log.Printf("SIWE 消息解析失败: %v", err)
+		return nil, errMessage("auth.siwe_invalid_message")
+	}
+
+	cfg := currentConfig().SIWE
+	if !strings.EqualFold(msg.Domain, cfg.Domain) || (cfg.URI != "" && !strings.HasPrefix(msg.URI, cfg.URI)) {
+		return nil, errMessage("auth.siwe_domain_mismatch")
+	}
+	if cfg.ChainID != 0 && msg.ChainID != cfg.ChainID {
+		return nil, errMessage("auth.siwe_chain_not_allowed")
+	}
+	now := time.Now()
+	if msg.IssuedAt.After(now.Add(siweClockSkew)) || (msg.NotBefore != nil && msg.NotBefore.After(now.Add(siweClockSkew))) {
+		return nil, errMessage("auth.siwe_not_yet_valid")
+	}
+	if msg.ExpirationTime != nil && msg.ExpirationTime.Before(now) {
+		return nil, errMessage("auth.siwe_expired")
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(req.Signature, "0x"))
+	if err != nil {
+		return nil, errMessage("auth.siwe_invalid_signature")
+	}
+	signer, err := recoverAddress(personalMessageHash(req.Message), sig)
+	if err != nil || signer != msg.Address {
+		return nil, newAPIError(http.StatusUnauthorized, CodeSIWEInvalidSignature, "auth.siwe_invalid_signature")
+	}
+	return msg, nil
+}
+
+// consumeSIWENonce 把 nonce 标记为已使用，已使用、已过期或不是服务端签发的 nonce 返回 errSIWENonceInvalid
+func consumeSIWENonce(tx *gorm.DB, nonce string) error {
+	now := time.Now()
+	result := tx.Model(&SIWENonce{}).
+		Where("nonce = ? AND used_at IS NULL AND expires_at > ?", nonce, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errSIWENonceInvalid
+	}
+	return nil
+}
+
+// walletUsername 为钱包新建的账号生成用户名：eth_ 加地址的前几位，重名时使用更长的地址
+func walletUsername(tx *gorm.DB, address string) (string, error) {
+	hexAddr := strings.ToLower(address[2:])
+	for _, n := range []int{8, 12, 40} {
+		username := "eth_" + hexAddr[:n]
+		var count int64
+		if err := tx.Unscoped().Model(&User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("无法为钱包 %s 生成用户名", address)
+}
+
+// respondSIWEError 统一处理 SIWE 事务中的错误
+func respondSIWEError(c *gin.Context, err error, messageID string) {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		abortWithError(c, apiErr)
+	case errors.Is(err, errSIWENonceInvalid):
+		abortWithError(c, newAPIError(http.StatusUnauthorized, CodeSIWENonceInvalid, "auth.siwe_nonce_invalid"))
+	default:
+		abortWithError(c, internalError(messageID, err))
+	}
+}
+
+// SIWENonceHandler 签发一个一次性 nonce，客户端把它写入待签名的 SIWE 消息
+func SIWENonceHandler(c *gin.Context) {
+	nonce, err := randomToken(16)
+	if err != nil {
+		abortWithError(c, internalError("auth.siwe_nonce_failed", err))
+		return
+	}
+	cfg := currentConfig().SIWE
+	record := SIWENonce{Nonce: nonce, ExpiresAt: time.Now().Add(cfg.NonceTTL.Duration)}
+	if err := DB.Create(&record).Error; err != nil {
+		abortWithError(c, internalError("auth.siwe_nonce_failed", err))
+		return
+	}
+
+	resp := gin.H{
+		"nonce":      nonce,
+		"domain":     cfg.Domain,
+		"expires_at": record.ExpiresAt,
+	}
+	if cfg.ChainID != 0 {
+		resp["chain_id"] = cfg.ChainID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SIWELoginHandler 使用签名后的 SIWE 消息登录；钱包还没有绑定账号时自动创建一个新账号
+// 开启了两步验证的账号与密码登录一样，需要再通过 /login/mfa 完成登录
+func SIWELoginHandler(c *gin.Context) {
+	var req SIWERequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	msg, apiErr := verifySIWE(req)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	var user User
+	created := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeSIWENonce(tx, msg.Nonce); err != nil {
+			return err
+		}
+		err := tx.Where("wallet_address = ?", msg.Address).First(&user).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		username, err := walletUsername(tx, msg.Address)
+		if err != nil {
+			return err
+		}
+		address := msg.Address
+		user = User{Username: username, Role: RoleUser, WalletAddress: &address}
+		// 钱包账号没有邮箱和密码；邮箱写入 NULL，避免多个钱包账号的空邮箱违反唯一索引
+		if err := tx.Omit("Email").Create(&user).Error; err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		respondSIWEError(c, err, "auth.siwe_failed")
+		return
+	}
+
+	if created {
+		log.Printf("钱包 %s 创建了新账号 %s", msg.Address, user.Username)
+	} else {
+		log.Printf("用户 %s 通过钱包 %s 登录", user.Username, msg.Address)
+	}
+	if user.TOTPEnabledAt != nil {
+		respondMFAChallenge(c, user)
+		return
+	}
+	respondWithTokens(c, user)
+}
+
+// LinkWalletHandler 为当前账号绑定钱包（已绑定时替换为新的钱包），之后可以用这个钱包登录
+func LinkWalletHandler(c *gin.Context) {
+	var req SIWERequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+	msg, apiErr := verifySIWE(req)
+	if apiErr != nil {
+		abortWithError(c, apiErr)
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeSIWENonce(tx, msg.Nonce); err != nil {
+			return err
+		}
+		var owner User
+		err := tx.Unscoped().Select("id").Where("wallet_address = ?", msg.Address).Limit(1).Find(&owner).Error
+		if err != nil {
+			return err
+		}
+		if owner.ID != 0 && owner.ID != user.ID {
+			return newAPIError(http.StatusConflict, CodeWalletTaken, "user.wallet_taken")
+		}
+		return tx.Model(user).Update("wallet_address", msg.Address).Error
+	})
+	if err != nil {
+		respondSIWEError(c, err, "user.wallet_link_failed")
+		return
+	}
+
+	log.Printf("用户 %s 绑定了钱包 %s", user.Username, msg.Address)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.wallet_linked"), "wallet_address": msg.Address})
+}
+
+// UnlinkWalletHandler 解除钱包绑定；没有邮箱和密码的钱包账号解除后将无法登录，所以不允许解除
+func UnlinkWalletHandler(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		abortWithError(c, toAPIError(err))
+		return
+	}
+	if user.WalletAddress == nil {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeWalletNotLinked, "user.wallet_not_linked"))
+		return
+	}
+	if user.Email == "" || user.Password == "" {
+		abortWithError(c, newAPIError(http.StatusConflict, CodeWalletRequired, "user.wallet_required"))
+		return
+	}
+	address := *user.WalletAddress
+	if err := DB.Model(user).Update("wallet_address", nil).Error; err != nil {
+		abortWithError(c, internalError("user.wallet_unlink_failed", err))
+		return
+	}
+
+	log.Printf("用户 %s 解除了钱包 %s 的绑定", user.Username, address)
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "user.wallet_unlinked")})
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// testWallet 本地生成的以太坊钱包，用于签名 SIWE 消息
+type testWallet struct {
+	key     *secp256k1.PrivateKey
+	address string
+}
+
+func newTestWallet(t *testing.T) testWallet {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return walletFromKey(key)
+}
+
+func walletFromKey(key *secp256k1.PrivateKey) testWallet {
+	pub := key.PubKey().SerializeUncompressed()
+	return testWallet{key: key, address: checksumAddress(keccak256(pub[1:])[12:])}
+}
+
+// sign 按 personal_sign 签名，返回以太坊格式的 0x 开头的 r || s || v
+func (w testWallet) sign(message string) string {
+	compact := ecdsa.SignCompact(w.key, personalMessageHash(message), false)
+	sig := append(append([]byte{}, compact[1:]...), compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+// siweTestMessage 测试用的 SIWE 消息，String 按 EIP-4361 格式输出
+type siweTestMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+}
+
+func newSIWETestMessage(address, nonce string) siweTestMessage {
+	return siweTestMessage{
+		Domain:    "localhost:8080",
+		Address:   address,
+		Statement: "Sign in to the blog",
+		URI:       "http://localhost:8080/login",
+		ChainID:   1,
+		Nonce:     nonce,
+		IssuedAt:  time.Now().UTC(),
+	}
+}
+
+func (m siweTestMessage) String() string {
+	lines := []string{m.Domain + siweHeaderSuffix, m.Address, ""}
+	if m.Statement != "" {
+		lines = append(lines, m.Statement, "")
+	} else {
+		lines = append(lines, "")
+	}
+	lines = append(lines,
+		"URI: "+m.URI,
+		"Version: 1",
+		fmt.Sprintf("Chain ID: %d", m.ChainID),
+		"Nonce: "+m.Nonce,
+		"Issued At: "+m.IssuedAt.Format(time.RFC3339),
+	)
+	if m.ExpirationTime != nil {
+		lines = append(lines, "Expiration Time: "+m.ExpirationTime.UTC().Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		lines = append(lines, "Not Before: "+m.NotBefore.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// issueTestNonce 通过接口获取一个 nonce
+func issueTestNonce(t *testing.T, r http.Handler) string {
+	t.Helper()
+	w := doJSON(r, http.MethodGet, "/api/v1/siwe/nonce", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取 nonce 失败: %d %s", w.Code, w.Body.String())
+	}
+	return decodeJSON(t, w)["nonce"].(string)
+}
+
+// assertAPIError 检查错误响应的状态码、错误码和消息；messageID 为空时不检查消息
+func assertAPIError(t *testing.T, w *httptest.ResponseRecorder, status int, code, messageID string) {
+	t.Helper()
+	if w.Code != status || errorCode(t, w) != code {
+		t.Fatalf("应返回 %d %s: %d %s", status, code, w.Code, w.Body.String())
+	}
+	if messageID == "" {
+		return
+	}
+	msg := decodeJSON(t, w)["error"].(map[string]interface{})["message"]
+	if want := translate(LocaleZhCN, messageID); msg != want {
+		t.Fatalf("错误消息为 %v，期望 %s（%s）", msg, want, messageID)
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	const address = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	full := strings.Join([]string{
+		"https://example.com wants you to sign in with your Ethereum account:",
+		address,
+		"",
+		"I accept the Terms of Service",
+		"",
+		"URI: https://example.com/login",
+		"Version: 1",
+		"Chain ID: 5",
+		"Nonce: 32891756abc",
+		"Issued At: 2021-09-30T16:25:24Z",
+		"Expiration Time: 2021-10-01T16:25:24.000Z",
+		"Not Before: 2021-09-30T16:00:00+08:00",
+		"Request ID: req-1",
+		"Resources:",
+		"- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/",
+		"- https://example.com/my-web2-claim.json",
+	}, "\n")
+
+	msg, err := parseSIWEMessage(full)
+	if err != nil {
+		t.Fatalf("解析完整消息失败: %v", err)
+	}
+	if msg.Domain != "example.com" {
+		t.Errorf("带协议的 domain 应去掉协议: %q", msg.Domain)
+	}
+	if msg.Address != address || msg.Statement != "I accept the Terms of Service" || msg.URI != "https://example.com/login" ||
+		msg.Version != "1" || msg.ChainID != 5 || msg.Nonce != "32891756abc" || msg.RequestID != "req-1" {
+		t.Errorf("字段解析错误: %+v", msg)
+	}
+	if !msg.IssuedAt.Equal(time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC)) {
+		t.Errorf("Issued At 解析错误: %v", msg.IssuedAt)
+	}
+	if msg.ExpirationTime == nil || !msg.ExpirationTime.Equal(time.Date(2021, 10, 1, 16, 25, 24, 0, time.UTC)) {
+		t.Errorf("Expiration Time 解析错误: %v", msg.ExpirationTime)
+	}
+	if msg.NotBefore == nil || !msg.NotBefore.Equal(time.Date(2021, 9, 30, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Not Before 解析错误: %v", msg.NotBefore)
+	}
+	if len(msg.Resources) != 2 || msg.Resources[1] != "https://example.com/my-web2-claim.json" {
+		t.Errorf("Resources 解析错误: %v", msg.Resources)
+	}
+
+	// 省略声明和全部可选字段
+	minimal := strings.Join([]string{
+		"localhost:8080 wants you to sign in with your Ethereum account:",
+		address, "", "",
+		"URI: http://localhost:8080",
+		"Version: 1",
+		"Chain ID: 1",
+		"Nonce: abcdefgh",
+		"Issued At: 2021-09-30T16:25:24Z",
+	}, "\n")
+	msg, err = parseSIWEMessage(minimal)
+	if err != nil {
+		t.Fatalf("解析最简消息失败: %v", err)
+	}
+	if msg.Domain != "localhost:8080" || msg.Statement != "" || msg.ExpirationTime != nil || msg.NotBefore != nil ||
+		msg.RequestID != "" || msg.Resources != nil {
+		t.Errorf("可选字段应为空: %+v", msg)
+	}
+
+	malformed := []struct {
+		name    string
+		old     string
+		replace string
+	}{
+		{"消息头错误", "wants you to sign in", "wants to sign in"},
+		{"缺少 domain", "https://example.com wants", " wants"},
+		{"只有协议的 domain", "https://example.com wants", "https:// wants"},
+		{"地址不是校验和格式", address, strings.ToLower(address)},
+		{"地址长度错误", address, address[:41]},
+		{"地址缺少 0x", address, "0X" + address[2:]},
+		{"地址后缺少空行", address + "\n\n", address + "\n"},
+		{"声明后缺少空行", "Service\n\n", "Service\n"},
+		{"缺少 URI", "URI: https://example.com/login\n", ""},
+		{"字段名大小写错误", "URI: ", "uri: "},
+		{"不支持的版本", "Version: 1", "Version: 2"},
+		{"Chain ID 不是数字", "Chain ID: 5", "Chain ID: five"},
+		{"Chain ID 为 0", "Chain ID: 5", "Chain ID: 0"},
+		{"Nonce 太短", "Nonce: 32891756abc", "Nonce: 1234567"},
+		{"Nonce 含有非字母数字", "Nonce: 32891756abc", "Nonce: 3289-1756abc"},
+		{"缺少 Issued At", "Issued At: 2021-09-30T16:25:24Z\n", ""},
+		{"Issued At 格式错误", "2021-09-30T16:25:24Z", "2021/09/30 16:25:24"},
+		{"Expiration Time 格式错误", "2021-10-01T16:25:24.000Z", "tomorrow"},
+		{"字段顺序错误", "Version: 1\nChain ID: 5", "Chain ID: 5\nVersion: 1"},
+		{"多余的行", "Request ID: req-1", "Request ID: req-1\nExtra: field"},
+		{"Resources 格式错误", "- https://example.com/my-web2-claim.json", "* https://example.com/my-web2-claim.json"},
+	}
+	for _, tt := range malformed {
+		t.Run(tt.name, func(t *testing.T) {
+			text := strings.Replace(full, tt.old, tt.replace, 1)
+			if text == full {
+				t.Fatalf("测试用例没有修改消息: %q", tt.old)
+			}
+			if _, err := parseSIWEMessage(text); !errors.Is(err, errSIWEMessage) {
+				t.Errorf("应返回 errSIWEMessage，得到 %v", err)
+			}
+		})
+	}
+	if _, err := parseSIWEMessage(""); !errors.Is(err, errSIWEMessage) {
+		t.Errorf("空消息应返回 errSIWEMessage，得到 %v", err)
+	}
+	if _, err := parseSIWEMessage(strings.SplitN(full, "\n", 4)[0] + "\n" + address + "\n"); !errors.Is(err, errSIWEMessage) {
+		t.Errorf("不完整的消息应返回 errSIWEMessage，得到 %v", err)
+	}
+}
+
+// EIP-55 中给出的测试向量
+func TestChecksumAddress(t *testing.T) {
+	vectors := []string{
+		// 全部大写
+		"0x52908400098527886E0F7030069857D2E4169EE7",
+		"0x8617E340B3D01FA5F11F306F4090FD50E238070D",
+		// 全部小写
+		"0xde709f2102306220921060314715629080e2fb77",
+		"0x27b1fdb04752bbc536007a920d24acb045561c26",
+		// 大小写混合
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, want := range vectors {
+		raw, err := hex.DecodeString(want[2:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := checksumAddress(raw); got != want {
+			t.Errorf("checksumAddress(%s) = %s", strings.ToLower(want), got)
+		}
+	}
+}
+
+func TestRecoverAddress(t *testing.T) {
+	// 已知私钥对应的地址（web3.js 文档中的示例）
+	keyBytes, _ := hex.DecodeString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+	known := walletFromKey(secp256k1.PrivKeyFromBytes(keyBytes))
+	if known.address != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Fatalf("私钥推导出的地址错误: %s", known.address)
+	}
+
+	wallet := newTestWallet(t)
+	hash := personalMessageHash("hello")
+	sig, _ := hex.DecodeString(strings.TrimPrefix(wallet.sign("hello"), "0x"))
+
+	tests := []struct {
+		name string
+		hash []byte
+		sig  func() []byte
+		want string
+		err  bool
+	}{
+		{"v 为 27/28", hash, func() []byte { return sig }, wallet.address, false},
+		{"v 为 0/1", hash, func() []byte {
+			s := append([]byte{}, sig...)
+			s[64] -= 27
+			return s
+		}, wallet.address, false},
+		{"已知私钥", personalMessageHash("known"), func() []byte {
+			s, _ := hex.DecodeString(strings.TrimPrefix(known.sign("known"), "0x"))
+			return s
+		}, known.address, false},
+		{"签名长度错误", hash, func() []byte { return sig[:64] }, "", true},
+		{"v 超出范围", hash, func() []byte {
+			s := append([]byte{}, sig...)
+			s[64] = 29
+			return s
+		}, "", true},
+		{"r 为 0", hash, func() []byte {
+			s := append([]byte{}, sig...)
+			copy(s[:32], make([]byte, 32))
+			return s
+		}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := recoverAddress(tt.hash, tt.sig())
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("recoverAddress = %q, %v，期望 %q，出错 = %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+
+	// 签名的是另一条消息时恢复出的地址不同
+	if got, err := recoverAddress(personalMessageHash("other"), sig); err == nil && got == wallet.address {
+		t.Error("不同消息的签名不应恢复出同一个地址")
+	}
+}
+
+func TestConsumeSIWENonce(t *testing.T) {
+	setupTestApp(t)
+	now := time.Now()
+	DB.Create(&SIWENonce{Nonce: "validnonce", ExpiresAt: now.Add(time.Minute)})
+	DB.Create(&SIWENonce{Nonce: "expirednonce", ExpiresAt: now.Add(-time.Second)})
+
+	if err := consumeSIWENonce(DB, "validnonce"); err != nil {
+		t.Fatalf("第一次使用 nonce 应成功: %v", err)
+	}
+	tests := []string{"validnonce", "expirednonce", "unknownnonce"}
+	for _, nonce := range tests {
+		if err := consumeSIWENonce(DB, nonce); !errors.Is(err, errSIWENonceInvalid) {
+			t.Errorf("%s 应返回 errSIWENonceInvalid，得到 %v", nonce, err)
+		}
+	}
+}
+
+func TestSIWELogin(t *testing.T) {
+	r := setupTestApp(t, func(cfg *Config) {
+		cfg.SIWE.URI = "http://localhost:8080/"
+	})
+	wallet := newTestWallet(t)
+
+	login := func(msg siweTestMessage, signer testWallet) *httptest.ResponseRecorder {
+		text := msg.String()
+		return doJSON(r, http.MethodPost, "/api/v1/siwe/verify", "", SIWERequest{Message: text, Signature: signer.sign(text)})
+	}
+
+	// 第一次登录自动创建账号
+	first := newSIWETestMessage(wallet.address, issueTestNonce(t, r))
+	w := login(first, wallet)
+	if w.Code != http.StatusOK || decodeJSON(t, w)["token"] == nil {
+		t.Fatalf("钱包登录失败: %d %s", w.Code, w.Body.String())
+	}
+	var user User
+	if err := DB.Where("wallet_address = ?", wallet.address).First(&user).Error; err != nil {
+		t.Fatalf("应为钱包创建账号: %v", err)
+	}
+	if want := "eth_" + strings.ToLower(wallet.address[2:10]); user.Username != want || user.Role != RoleUser {
+		t.Errorf("新账号的用户名为 %s，期望 %s", user.Username, want)
+	}
+
+	// nonce 只能使用一次
+	assertAPIError(t, login(first, wallet), http.StatusUnauthorized, CodeSIWENonceInvalid, "auth.siwe_nonce_invalid")
+
+	// 再次登录使用同一个账号
+	if w := login(newSIWETestMessage(wallet.address, issueTestNonce(t, r)), wallet); w.Code != http.StatusOK {
+		t.Fatalf("再次登录失败: %d %s", w.Code, w.Body.String())
+	}
+	var count int64
+	DB.Model(&User{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("再次登录不应创建新账号，现有 %d 个账号", count)
+	}
+
+	// 服务端没有签发过或已过期的 nonce
+	assertAPIError(t, login(newSIWETestMessage(wallet.address, "notissued1"), wallet),
+		http.StatusUnauthorized, CodeSIWENonceInvalid, "auth.siwe_nonce_invalid")
+	DB.Create(&SIWENonce{Nonce: "expirednonce", ExpiresAt: time.Now().Add(-time.Second)})
+	assertAPIError(t, login(newSIWETestMessage(wallet.address, "expirednonce"), wallet),
+		http.StatusUnauthorized, CodeSIWENonceInvalid, "auth.siwe_nonce_invalid")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		modify    func(*siweTestMessage)
+		otherKey  bool // 用另一个钱包签名
+		status    int
+		code      string
+		messageID string
+	}{
+		{"domain 不一致", func(m *siweTestMessage) { m.Domain = "evil.example.com" },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_domain_mismatch"},
+		{"URI 不一致", func(m *siweTestMessage) { m.URI = "https://evil.example.com/login" },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_domain_mismatch"},
+		{"链 ID 不一致", func(m *siweTestMessage) { m.ChainID = 137 },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_chain_not_allowed"},
+		{"已过期", func(m *siweTestMessage) { m.ExpirationTime = &past },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_expired"},
+		{"签发时间在未来", func(m *siweTestMessage) { m.IssuedAt = future },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_not_yet_valid"},
+		{"尚未生效", func(m *siweTestMessage) { m.NotBefore = &future },
+			false, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_not_yet_valid"},
+		{"别人的签名", func(m *siweTestMessage) {},
+			true, http.StatusUnauthorized, CodeSIWEInvalidSignature, "auth.siwe_invalid_signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce := issueTestNonce(t, r)
+			msg := newSIWETestMessage(wallet.address, nonce)
+			tt.modify(&msg)
+			signer := wallet
+			if tt.otherKey {
+				signer = newTestWallet(t)
+			}
+			assertAPIError(t, login(msg, signer), tt.status, tt.code, tt.messageID)
+
+			// 校验失败时 nonce 没有被消费，可以继续使用
+			if w := login(newSIWETestMessage(wallet.address, nonce), wallet); w.Code != http.StatusOK {
+				t.Fatalf("校验失败后 nonce 应仍然可用: %d %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// 签名格式错误
+	text := newSIWETestMessage(wallet.address, issueTestNonce(t, r)).String()
+	w = doJSON(r, http.MethodPost, "/api/v1/siwe/verify", "", SIWERequest{Message: text, Signature: "0xzz"})
+	assertAPIError(t, w, http.StatusBadRequest, CodeSIWEInvalidMessage, "auth.siwe_invalid_signature")
+}
+
+func TestLinkAndUnlinkWallet(t *testing.T) {
+	r := setupTestApp(t)
+	alice := createTestUser(t, "alice", RoleUser)
+	bob := createTestUser(t, "bob", RoleUser)
+	aliceToken := testToken(t, alice)
+	wallet := newTestWallet(t)
+
+	link := func(token string, signer testWallet) *httptest.ResponseRecorder {
+		text := newSIWETestMessage(signer.address, issueTestNonce(t, r)).String()
+		return doJSON(r, http.MethodPost, "/api/v1/profile/wallet", token, SIWERequest{Message: text, Signature: signer.sign(text)})
+	}
+
+	if w := link(aliceToken, wallet); w.Code != http.StatusOK || decodeJSON(t, w)["wallet_address"] != wallet.address {
+		t.Fatalf("绑定钱包失败: %d %s", w.Code, w.Body.String())
+	}
+	DB.First(&alice, alice.ID)
+	if alice.WalletAddress == nil || *alice.WalletAddress != wallet.address {
+		t.Fatalf("钱包地址没有保存: %v", alice.WalletAddress)
+	}
+
+	// 绑定后用钱包登录得到的是原来的账号
+	text := newSIWETestMessage(wallet.address, issueTestNonce(t, r)).String()
+	w := doJSON(r, http.MethodPost, "/api/v1/siwe/verify", "", SIWERequest{Message: text, Signature: wallet.sign(text)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("钱包登录失败: %d %s", w.Code, w.Body.String())
+	}
+	w = doJSON(r, http.MethodGet, "/api/v1/profile", decodeJSON(t, w)["token"].(string), nil)
+	if profile, _ := decodeJSON(t, w)["profile"].(map[string]interface{}); profile["username"] != alice.Username {
+		t.Fatalf("钱包登录应得到 alice 的 token: %s", w.Body.String())
+	}
+
+	// 钱包已被别人绑定
+	assertAPIError(t, link(testToken(t, bob), wallet), http.StatusConflict, CodeWalletTaken, "user.wallet_taken")
+
+	// 替换为新的钱包
+	replacement := newTestWallet(t)
+	if w := link(aliceToken, replacement); w.Code != http.StatusOK {
+		t.Fatalf("替换钱包失败: %d %s", w.Code, w.Body.String())
+	}
+
+	// 解除绑定
+	if w := doJSON(r, http.MethodDelete, "/api/v1/profile/wallet", aliceToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("解除绑定失败: %d %s", w.Code, w.Body.String())
+	}
+	DB.First(&alice, alice.ID)
+	if alice.WalletAddress != nil {
+		t.Fatalf("解除绑定后钱包地址应为空: %v", *alice.WalletAddress)
+	}
+	assertAPIError(t, doJSON(r, http.MethodDelete, "/api/v1/profile/wallet", aliceToken, nil),
+		http.StatusBadRequest, CodeWalletNotLinked, "user.wallet_not_linked")
+
+	// 只有钱包的账号不能解除绑定，否则将无法登录
+	text = newSIWETestMessage(replacement.address, issueTestNonce(t, r)).String()
+	w = doJSON(r, http.MethodPost, "/api/v1/siwe/verify", "", SIWERequest{Message: text, Signature: replacement.sign(text)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("钱包登录失败: %d %s", w.Code, w.Body.String())
+	}
+	walletToken := decodeJSON(t, w)["token"].(string)
+	assertAPIError(t, doJSON(r, http.MethodDelete, "/api/v1/profile/wallet", walletToken, nil),
+		http.StatusConflict, CodeWalletRequired, "user.wallet_required")
+}
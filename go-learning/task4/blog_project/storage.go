@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 支持的文件存储驱动
+const (
+	StorageDriverLocal = "local"
+	StorageDriverS3    = "s3"
+)
+
+// errObjectNotFound 存储中不存在该对象
+var errObjectNotFound = errors.New("存储对象不存在")
+
+// Storage 保存上传文件的接口，key 是形如 attachments/1/xxx.png 的相对路径
+// 单实例部署可以使用本地磁盘，多实例部署需要共享的对象存储（S3 或兼容 S3 的 MinIO 等）
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// storage 当前使用的存储驱动，由 initStorage 初始化
+var storage Storage
+
+// LocalStorage 把文件保存在 Dir 目录下
+type LocalStorage struct {
+	Dir string
+}
+
+// path 把 key 转换为磁盘路径，拒绝跳出存储目录的 key
+func (s LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("无效的存储 key: %q", key)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+// Put 先写入临时文件再重命名，读取方不会看到写了一半的文件
+func (s LocalStorage) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errObjectNotFound
+	}
+	return f, err
+}
+
+// Delete 删除文件，文件不存在时不报错
+func (s LocalStorage) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3Storage 保存在 S3 或兼容 S3 协议的对象存储中，请求使用 AWS Signature Version 4 签名
+// 本地开发可以用 MinIO 代替：endpoint 设为 http://localhost:9000，并开启 path_style
+type S3Storage struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用 endpoint/bucket/key 形式的地址，否则使用 bucket.endpoint/key
+	Client    *http.Client
+}
+
+// NewS3Storage 创建 S3 存储
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) (*S3Storage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的 S3 endpoint: %q", endpoint)
+	}
+	return &S3Storage{
+		Endpoint:  u,
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: pathStyle,
+		Client:    &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// objectURL 返回对象的地址
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.Endpoint
+	if s.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+// do 签名并发送请求；payloadHash 为请求体的 SHA-256，无法预先计算时使用 UNSIGNED-PAYLOAD
+func (s *S3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	signS3Request(req, s.AccessKey, s.SecretKey, s.Region, payloadHash, time.Now())
+	return s.Client.Do(req)
+}
+
+// Put 上传对象；r 可以 Seek 时计算请求体的哈希一起签名
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	payloadHash := "UNSIGNED-PAYLOAD"
+	if rs, ok := r.(io.ReadSeeker); ok {
+		h := sha256.New()
+		if _, err := io.Copy(h, rs); err != nil {
+			return err
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		payloadHash = hex.EncodeToString(h.Sum(nil))
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, r, size, contentType, payloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "", emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errObjectNotFound
+	}
+	defer resp.Body.Close()
+	return nil, s3Error(resp)
+}
+
+// Delete 删除对象，S3 删除不存在的对象也返回 204
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "", emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// s3Error 把 S3 的错误响应转换为 error，响应体是 XML，只截取前面一部分写入日志
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 请求失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// emptyPayloadHash 空请求体的 SHA-256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request 按 AWS Signature Version 4 给请求签名，签名覆盖 Host 和请求中已经设置的全部请求头
+func signS3Request(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath 按 SigV4 的规则编码路径：除 RFC 3986 的非保留字符和 / 之外全部百分号编码
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		if ch == '/' || ch == '-' || ch == '_' || ch == '.' || ch == '~' ||
+			('0' <= ch && ch <= '9') || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery 按参数名排序的查询字符串，空格编码为 %20
+func canonicalQuery(values url.Values) string {
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+// newStorage 按配置创建存储驱动
+func newStorage(cfg *Config) (Storage, error) {
+	if cfg.Storage.Driver == StorageDriverS3 {
+		s3 := cfg.Storage.S3
+		return NewS3Storage(s3.Endpoint, s3.Region, s3.Bucket, s3.AccessKey, s3.SecretKey, s3.PathStyle)
+	}
+	return LocalStorage{Dir: cfg.Storage.Dir}, nil
+}
+
+// initStorage 初始化文件存储，配置错误时直接退出
+func initStorage() {
+	s, err := newStorage(currentConfig())
+	if err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+	storage = s
+	log.Printf("文件存储驱动: %s", currentConfig().Storage.Driver)
+}
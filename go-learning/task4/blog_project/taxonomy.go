@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TaxonomyCount 标签或分类及其下已发布文章的数量
+type TaxonomyCount struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	PostCount int64  `json:"post_count"`
+}
+
+// normalizeNames 去掉首尾空白、统一小写并去重，保持原有顺序
+func normalizeNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		result = append(result, n)
+	}
+	return result
+}
+
+// findOrCreateTags 根据标签名查找标签，不存在的标签会自动创建
+func findOrCreateTags(tx *gorm.DB, names []string) ([]Tag, error) {
+	names = normalizeNames(names)
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		var tag Tag
+		if err := tx.Where(Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// findOrCreateCategories 根据分类名查找分类，不存在的分类会自动创建
+func findOrCreateCategories(tx *gorm.DB, names []string) ([]Category, error) {
+	names = normalizeNames(names)
+	categories := make([]Category, 0, len(names))
+	for _, name := range names {
+		var category Category
+		if err := tx.Where(Category{Name: name}).FirstOrCreate(&category).Error; err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// setPostTaxonomy 替换文章的标签和分类；传入 nil 表示保持不变
+func setPostTaxonomy(tx *gorm.DB, post *Post, tagNames, categoryNames *[]string) error {
+	if tagNames != nil {
+		tags, err := findOrCreateTags(tx, *tagNames)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(post).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		post.Tags = tags
+	}
+	if categoryNames != nil {
+		categories, err := findOrCreateCategories(tx, *categoryNames)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(post).Association("Categories").Replace(categories); err != nil {
+			return err
+		}
+		post.Categories = categories
+	}
+	return nil
+}
+
+// filterByTaxonomy 返回一个查询作用域，按 ?tag= 和 ?category= 过滤文章
+func filterByTaxonomy(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		tag := c.Param("tag") // 按标签订阅的路由中标签在路径里
+		if tag == "" {
+			tag = c.Query("tag")
+		}
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			db = db.Where("posts.id IN (?)", DB.Table("post_tags").
+				Select("post_tags.post_id").
+				Joins("JOIN tags ON tags.id = post_tags.tag_id").
+				Where("tags.name = ? AND tags.deleted_at IS NULL", tag))
+		}
+		if category := strings.ToLower(strings.TrimSpace(c.Query("category"))); category != "" {
+			db = db.Where("posts.id IN (?)", DB.Table("post_categories").
+				Select("post_categories.post_id").
+				Joins("JOIN categories ON categories.id = post_categories.category_id").
+				Where("categories.name = ? AND categories.deleted_at IS NULL", category))
+		}
+		return db
+	}
+}
+
+// countPostsBy 统计每个标签或分类下已发布文章的数量
+// table 为 tags 或 categories，joinTable 为对应的关联表
+func countPostsBy(table, joinTable, joinColumn string) ([]TaxonomyCount, error) {
+	var counts []TaxonomyCount
+	err := DB.Table(table).
+		Select(table+".id, "+table+".name, COUNT(posts.id) AS post_count").
+		Joins("LEFT JOIN "+joinTable+" ON "+joinTable+"."+joinColumn+" = "+table+".id").
+		Joins("LEFT JOIN posts ON posts.id = "+joinTable+".post_id AND posts.status = ? AND posts.deleted_at IS NULL", PostStatusPublished).
+		Where(table + ".deleted_at IS NULL").
+		Group(table + ".id, " + table + ".name").
+		Order("post_count desc, " + table + ".name asc").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// GetTagsHandler 获取所有标签及每个标签的文章数量
+func GetTagsHandler(c *gin.Context) {
+	log.Printf("获取标签列表")
+	tags, err := countPostsBy("tags", "post_tags", "tag_id")
+	if err != nil {
+		abortWithError(c, internalError("tag.list_failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// GetCategoriesHandler 获取所有分类及每个分类的文章数量
+func GetCategoriesHandler(c *gin.Context) {
+	log.Printf("获取分类列表")
+	categories, err := countPostsBy("categories", "post_categories", "category_id")
+	if err != nil {
+		abortWithError(c, internalError("category.list_failed", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeNames(t *testing.T) {
+	got := normalizeNames([]string{" Go ", "go", "", "Web", "  ", "GO", "数据库"})
+	want := []string{"go", "web", "数据库"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeNames = %q，期望 %q", got, want)
+	}
+}
+
+func TestTaxonomyFilterAndCounts(t *testing.T) {
+	r := setupTestApp(t)
+	author := createTestUser(t, "author", RoleUser)
+	token := testToken(t, author)
+
+	create := func(title, status string, tags, categories []string) uint {
+		t.Helper()
+		w := doJSON(r, http.MethodPost, "/api/v1/posts", token, map[string]interface{}{
+			"title": title, "content": "content", "status": status, "tags": tags, "categories": categories,
+		})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("创建文章返回 %d: %s", w.Code, w.Body.String())
+		}
+		return uint(decodeJSON(t, w)["post_id"].(float64))
+	}
+	create("go web", PostStatusPublished, []string{"Go", "web"}, []string{"后端"})
+	create("go cli", PostStatusPublished, []string{"go", "cli"}, []string{"工具"})
+	drafted := create("draft", PostStatusDraft, []string{"go"}, []string{"后端"})
+
+	titles := func(query string) []string {
+		t.Helper()
+		w := doJSON(r, http.MethodGet, "/api/v1/posts"+query, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("获取文章列表返回 %d: %s", w.Code, w.Body.String())
+		}
+		var out []string
+		for _, p := range decodeJSON(t, w)["posts"].([]interface{}) {
+			out = append(out, p.(map[string]interface{})["Title"].(string))
+		}
+		return out
+	}
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"?tag=GO", []string{"go cli", "go web"}},
+		{"?tag=web", []string{"go web"}},
+		{"?category=%E5%90%8E%E7%AB%AF", []string{"go web"}},
+		{"?tag=cli&category=%E5%90%8E%E7%AB%AF", nil},
+		{"?tag=missing", nil},
+	}
+	for _, tc := range cases {
+		if got := titles(tc.query); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GET /posts%s = %q，期望 %q", tc.query, got, tc.want)
+		}
+	}
+
+	// 数量只统计已发布的文章
+	w := doJSON(r, http.MethodGet, "/api/v1/tags", "", nil)
+	counts := map[string]float64{}
+	for _, item := range decodeJSON(t, w)["tags"].([]interface{}) {
+		tag := item.(map[string]interface{})
+		counts[tag["name"].(string)] = tag["post_count"].(float64)
+	}
+	if want := map[string]float64{"go": 2, "web": 1, "cli": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("标签数量 %v，期望 %v", counts, want)
+	}
+
+	// 更新时传入的标签整体替换原有标签，不传则保持不变
+	w = doJSON(r, http.MethodPut, fmt.Sprintf("/api/v1/posts/%d", drafted), token,
+		map[string]interface{}{"title": "draft", "content": "content", "tags": []string{"rust"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("更新文章返回 %d: %s", w.Code, w.Body.String())
+	}
+	var post Post
+	DB.Preload("Tags").Preload("Categories").First(&post, drafted)
+	if len(post.Tags) != 1 || post.Tags[0].Name != "rust" || len(post.Categories) != 1 {
+		t.Errorf("更新后的标签 %v，分类 %v", post.Tags, post.Categories)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// setupTestApp 使用临时 SQLite 数据库初始化全局状态并返回路由
+// configure 可以在初始化前调整配置，测试结束后关闭数据库连接
+func setupTestApp(t *testing.T, configure ...func(*Config)) *gin.Engine {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := defaultConfig()
+	// 写事务立即加锁，并发请求排队等待而不是直接返回 database is locked
+	cfg.Database.DSN = filepath.Join(dir, "blog.db") + "?_busy_timeout=5000&_txlock=immediate"
+	cfg.Auth.JWTSecret = "test-secret-test-secret-test-secret"
+	cfg.Auth.BcryptCost = 4
+	cfg.RateLimit.Enabled = false
+	cfg.Storage.Dir = filepath.Join(dir, "uploads")
+	cfg.Mail.Dir = filepath.Join(dir, "mail")
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	appConfig.Store(cfg)
+	jwtKey = []byte(cfg.Auth.JWTSecret)
+
+	db, err := openDatabase()
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	DB = db
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	// PostgreSQL、MySQL 是共用的测试库，先回滚全部迁移清掉上一个测试留下的数据
+	if !isSQLite(DB) {
+		if _, err := migrateDown(DB, len(migrations)); err != nil {
+			t.Fatalf("清理测试数据库失败: %v", err)
+		}
+	}
+	if _, err := migrateUp(DB); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	searchEnabled = false
+	initSearchIndex(DB)
+	initValidator()
+	initMailer()
+	initRateLimiter()
+	initStorage()
+	initRenderCache()
+	return setupRouter()
+}
+
+// createTestUser 直接在数据库中创建一个已验证邮箱的用户，密码为 Passw0rd!x
+func createTestUser(t *testing.T, username, role string) User {
+	t.Helper()
+	hash, err := HashPassword("Passw0rd!x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := User{Username: username, Password: hash, Email: username + "@example.com", Role: role, EmailVerifiedAt: &now}
+	if err := DB.Create(&user).Error; err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+// testToken 为用户签发访问 token
+func testToken(t *testing.T, user User) string {
+	t.Helper()
+	token, err := GenerateJWT(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// doJSON 发送 JSON 请求；token 不为空时带上 Bearer 认证，headers 按键值对依次传入
+func doJSON(r http.Handler, method, path, token string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decodeJSON 把响应体解析为 map
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("响应不是 JSON: %v\n%s", err, w.Body.String())
+	}
+	return out
+}
+
+// errorCode 返回错误响应中的错误码
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	body := decodeJSON(t, w)
+	if e, ok := body["error"].(map[string]interface{}); ok {
+		code, _ := e["code"].(string)
+		return code
+	}
+	return ""
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("刷新 token 无效")
+	errRefreshTokenExpired = errors.New("刷新 token 已过期")
+	errRefreshTokenReused  = errors.New("检测到刷新 token 被重复使用")
+)
+
+// TokenPair 登录或刷新后返回给客户端的一组 token
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问 token 剩余秒数
+}
+
+// randomToken 生成 n 字节的随机数并以十六进制字符串返回
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken 计算 token 的 SHA-256，数据库中只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokenPair 签发访问 token 和刷新 token；familyID 为空时开启一个新的 token 家族
+func issueTokenPair(tx *gorm.DB, user User, familyID string) (*TokenPair, error) {
+	accessToken, err := GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(16); err != nil {
+			return nil, err
+		}
+	}
+
+	record := RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(currentConfig().Auth.RefreshTokenTTL.Duration),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(currentConfig().Auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// rotateRefreshToken 用旧的刷新 token 换取新的 token 对
+// 如果旧 token 已经被使用过，说明可能被盗用，整个家族都会被吊销
+func rotateRefreshToken(refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return revokeTokenFamily(tx, current.FamilyID)
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return errRefreshTokenExpired
+		}
+
+		var user User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errRefreshTokenInvalid
+			}
+			return err
+		}
+
+		// 条件更新：只有仍未吊销时才能轮换，并发的两次刷新只有一个会成功，另一个按重放处理
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return revokeTokenFamily(tx, current.FamilyID)
+		}
+
+		newPair, err := issueTokenPair(tx, user, current.FamilyID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&RefreshToken{}).Where("id = ?", current.ID).
+			Update("replaced_by", hashToken(newPair.RefreshToken)).Error; err != nil {
+			return err
+		}
+
+		pair = newPair
+		return nil
+	})
+
+	// 重放检测需要提交家族吊销，所以在事务结束后再返回错误
+	if err == nil && reused {
+		return nil, errRefreshTokenReused
+	}
+	return pair, err
+}
+
+// revokeTokenFamily 吊销同一家族下所有仍然有效的刷新 token
+func revokeTokenFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeUserSessions 吊销某个用户的全部会话，用于修改密码等场景
+// 刷新 token 全部吊销；token 版本加一，所有设备上已签发的访问 token 立即失效
+func revokeUserSessions(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return tx.Model(&User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// revokeAccessToken 把访问 token 的 jti 加入吊销列表
+func revokeAccessToken(tx *gorm.DB, claims *Claims) error {
+	if claims.ID == "" {
+		return nil
+	}
+	expiresAt := time.Now().Add(currentConfig().Auth.AccessTokenTTL.Duration)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	revoked := RevokedToken{JTI: claims.ID, ExpiresAt: expiresAt}
+	return tx.Where(RevokedToken{JTI: claims.ID}).FirstOrCreate(&revoked).Error
+}
+
+// isTokenRevoked 检查 jti 是否在吊销列表中
+func isTokenRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	var count int64
+	if err := DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// runTokenJanitor 定期清理已经过期的吊销记录、刷新 token、一次性 token 和 SIWE nonce
+func runTokenJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		if err := DB.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+			log.Printf("清理吊销 token 失败: %v", err)
+		}
+		if err := DB.Unscoped().Where("expires_at < ?", now).Delete(&RefreshToken{}).Error; err != nil {
+			log.Printf("清理刷新 token 失败: %v", err)
+		}
+		if err := DB.Where("expires_at < ?", now).Delete(&ActionToken{}).Error; err != nil {
+			log.Printf("清理一次性 token 失败: %v", err)
+		}
+		if err := DB.Where("expires_at < ?", now).Delete(&SIWENonce{}).Error; err != nil {
+			log.Printf("清理 SIWE nonce 失败: %v", err)
+		}
+	}
+}
+
+// RefreshTokenHandler 使用刷新 token 换取新的访问 token（刷新 token 同时轮换）
+func RefreshTokenHandler(c *gin.Context) {
+	log.Printf("刷新 token 请求")
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, badRequest(err))
+		return
+	}
+
+	tokens, err := rotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, errRefreshTokenInvalid):
+			abortWithError(c, newAPIError(http.StatusUnauthorized, CodeRefreshTokenInvalid, "auth.refresh_token_invalid"))
+		case errors.Is(err, errRefreshTokenExpired):
+			abortWithError(c, newAPIError(http.StatusUnauthorized, CodeRefreshTokenInvalid, "auth.refresh_token_expired"))
+		case errors.Is(err, errRefreshTokenReused):
+			abortWithError(c, newAPIError(http.StatusUnauthorized, CodeRefreshTokenReused, "auth.refresh_token_reused").Wrap(err))
+		default:
+			abortWithError(c, internalError("auth.refresh_failed", err))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       T(c, "auth.refreshed"),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// LogoutHandler 登出：吊销当前访问 token，并吊销请求中携带的刷新 token 所在的家族
+func LogoutHandler(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	log.Printf("用户登出请求: 用户ID=%v", userID)
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// 请求体是可选的
+	_ = c.ShouldBindJSON(&req)
+
+	claimsVal, _ := c.Get("claims")
+	claims, ok := claimsVal.(*Claims)
+	if !ok {
+		abortWithError(c, errAuthRequired)
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessToken(tx, claims); err != nil {
+			return err
+		}
+		if req.RefreshToken == "" {
+			return nil
+		}
+		var rt RefreshToken
+		err := tx.Where("token_hash = ? AND user_id = ?", hashToken(req.RefreshToken), claims.UserID).First(&rt).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return revokeTokenFamily(tx, rt.FamilyID)
+	})
+	if err != nil {
+		abortWithError(c, internalError("auth.logout_failed", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": T(c, "auth.logged_out")})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	setupTestApp(t)
+	user := createTestUser(t, "alice", RoleUser)
+
+	first, err := issueTokenPair(DB, user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := rotateRefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("第一次轮换失败: %v", err)
+	}
+
+	// 旧 token 再次使用视为重放，整个家族（包括刚签发的新 token）都被吊销
+	if _, err := rotateRefreshToken(first.RefreshToken); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("重复使用旧 token 应返回 errRefreshTokenReused，得到 %v", err)
+	}
+	if _, err := rotateRefreshToken(second.RefreshToken); !errors.Is(err, errRefreshTokenReused) {
+		t.Fatalf("家族被吊销后新 token 也应失效，得到 %v", err)
+	}
+	if _, err := rotateRefreshToken("unknown"); !errors.Is(err, errRefreshTokenInvalid) {
+		t.Fatalf("未知 token 应返回 errRefreshTokenInvalid，得到 %v", err)
+	}
+}
+
+func TestRotateRefreshTokenConcurrent(t *testing.T) {
+	setupTestApp(t)
+	user := createTestUser(t, "bob", RoleUser)
+	pair, err := issueTokenPair(DB, user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 8
+	var wg sync.WaitGroup
+	results := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, results[i] = rotateRefreshToken(pair.RefreshToken)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, errRefreshTokenReused):
+			t.Errorf("意外的错误: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("并发轮换同一个刷新 token 应只有一次成功，实际 %d 次", succeeded)
+	}
+
+	var active int64
+	DB.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&active)
+	if active != 0 {
+		t.Fatalf("检测到重放后家族中不应再有有效的刷新 token，实际 %d 个", active)
+	}
+}
+
+func TestRefreshTokenHandler(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "carol", RoleUser)
+	pair, err := issueTokenPair(DB, user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := doJSON(r, http.MethodPost, "/api/v1/token/refresh", "", map[string]string{"refresh_token": pair.RefreshToken})
+	if w.Code != http.StatusOK {
+		t.Fatalf("刷新失败: %d %s", w.Code, w.Body.String())
+	}
+	if body := decodeJSON(t, w); body["refresh_token"] == "" || body["refresh_token"] == pair.RefreshToken {
+		t.Fatalf("刷新后应返回新的刷新 token: %v", body)
+	}
+
+	w = doJSON(r, http.MethodPost, "/api/v1/token/refresh", "", map[string]string{"refresh_token": pair.RefreshToken})
+	if w.Code != http.StatusUnauthorized || errorCode(t, w) != CodeRefreshTokenReused {
+		t.Fatalf("重复使用应返回 401 %s: %d %s", CodeRefreshTokenReused, w.Code, w.Body.String())
+	}
+}
+
+// 修改或重置密码后，其他设备上尚未过期的访问 token 立即失效，之后签发的 token 不受影响
+func TestPasswordChangeRevokesAccessTokens(t *testing.T) {
+	r := setupTestApp(t)
+	user := createTestUser(t, "dave", RoleUser)
+	phone, laptop := testToken(t, user), testToken(t, user)
+
+	w := doJSON(r, http.MethodPut, "/api/v1/profile/password", laptop,
+		map[string]string{"current_password": "Passw0rd!x", "new_password": "N3w-Passw0rd!"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改密码失败: %d %s", w.Code, w.Body.String())
+	}
+	for name, token := range map[string]string{"其他设备": phone, "当前设备": laptop} {
+		w := doJSON(r, http.MethodGet, "/api/v1/profile", token, nil)
+		if w.Code != http.StatusUnauthorized || errorCode(t, w) != CodeTokenRevoked {
+			t.Errorf("%s的访问 token 应返回 401 %s: %d %s", name, CodeTokenRevoked, w.Code, w.Body.String())
+		}
+	}
+
+	if err := DB.First(&user, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	fresh := testToken(t, user)
+	if w := doJSON(r, http.MethodGet, "/api/v1/profile", fresh, nil); w.Code != http.StatusOK {
+		t.Fatalf("重新登录后的 token 应该有效: %d %s", w.Code, w.Body.String())
+	}
+
+	resetToken, err := issueActionToken(DB, user, PurposeResetPassword, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = doJSON(r, http.MethodPost, "/api/v1/password/reset", "", map[string]string{"token": resetToken, "password": "An0ther-Passw0rd!"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("重置密码失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(r, http.MethodGet, "/api/v1/profile", fresh, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("重置密码后旧的访问 token 应失效: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// 密码长度限制；bcrypt 只使用前 72 个字节，超出部分会被忽略
+const (
+	passwordMinLength = 8
+	passwordMaxBytes  = 72
+)
+
+// usernamePattern 用户名：字母开头，只能包含字母、数字和下划线，3 到 32 个字符
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{2,31}$`)
+
+// customValidators 自定义校验规则，在请求结构体的 binding 标签中使用
+var customValidators = map[string]validator.Func{
+	"username":   validateUsername,
+	"password":   validatePassword,
+	"maxcontent": validateMaxContent,
+	"locale":     validateLocale,
+	"apiscope":   validateAPIKeyScope,
+	"httpurl":    validateHTTPURL,
+}
+
+func validateUsername(fl validator.FieldLevel) bool {
+	return usernamePattern.MatchString(fl.Field().String())
+}
+
+// validatePassword 密码至少 8 个字符，同时包含字母和数字，且不超过 bcrypt 的 72 字节上限
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if utf8.RuneCountInString(password) < passwordMinLength || len(password) > passwordMaxBytes {
+		return false
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}
+
+// validateMaxContent 正文长度不能超过配置的上限，参数为 post 或 comment
+func validateMaxContent(fl validator.FieldLevel) bool {
+	return utf8.RuneCountInString(fl.Field().String()) <= maxContentLength(fl.Param())
+}
+
+func validateLocale(fl validator.FieldLevel) bool {
+	return validLocale(fl.Field().String())
+}
+
+func validateAPIKeyScope(fl validator.FieldLevel) bool {
+	return validAPIKeyScope(fl.Field().String())
+}
+
+// validateHTTPURL 只接受 http:// 或 https:// 开头的绝对地址，防止 javascript: 等地址被渲染成链接
+func validateHTTPURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// maxContentLength 返回文章或评论正文的最大字符数
+func maxContentLength(kind string) int {
+	if kind == "comment" {
+		return currentConfig().Comments.MaxContentLength
+	}
+	return currentConfig().Posts.MaxContentLength
+}
+
+// registerCustomValidators 注册自定义校验规则及其中英文提示
+func registerCustomValidators(v *validator.Validate) error {
+	for tag, fn := range customValidators {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+	for locale, trans := range validTranslators {
+		for tag := range customValidators {
+			if err := v.RegisterTranslation(tag, trans, registerNoop, customTranslation(locale, tag)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// registerNoop 自定义规则的提示放在消息目录中，不需要注册到翻译器
+func registerNoop(ut.Translator) error {
+	return nil
+}
+
+// customTranslation 从消息目录中取出自定义规则的提示，消息 ID 为 validation.<tag>
+func customTranslation(locale, tag string) validator.TranslationFunc {
+	return func(_ ut.Translator, fe validator.FieldError) string {
+		id := "validation." + tag
+		switch tag {
+		case "password":
+			return translate(locale, id, fe.Field(), passwordMinLength)
+		case "maxcontent":
+			return translate(locale, id, fe.Field(), maxContentLength(fe.Param()))
+		case "locale":
+			return translate(locale, id, fe.Field(), strings.Join(supportedLocales, ", "))
+		case "apiscope":
+			return translate(locale, id, fe.Field(), strings.Join(apiKeyScopes, ", "))
+		}
+		return translate(locale, id, fe.Field())
+	}
+}
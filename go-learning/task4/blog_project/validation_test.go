@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// testValidator 返回注册了自定义规则的 gin 校验器
+func testValidator(t *testing.T) *validator.Validate {
+	t.Helper()
+	setupTestApp(t, func(cfg *Config) {
+		cfg.Posts.MaxContentLength = 10
+		cfg.Comments.MaxContentLength = 5
+	})
+	return binding.Validator.Engine().(*validator.Validate)
+}
+
+func TestValidatePassword(t *testing.T) {
+	v := testValidator(t)
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"最短 8 个字符", "Passw0rd", true},
+		{"只有 7 个字符", "Passw0r", false},
+		{"没有数字", "Password", false},
+		{"没有字母", "12345678", false},
+		{"正好 72 字节", "a1" + strings.Repeat("x", 70), true},
+		{"超过 72 字节", "a1" + strings.Repeat("x", 71), false},
+		{"8 个中文字符", "密码密码密码密1", true},
+		{"字节数够但字符数不足", "密码密码1", false},
+		{"中文正好 72 字节", strings.Repeat("密", 23) + "123", true},
+		{"中文字符数少于 72 但超过 72 字节", strings.Repeat("密", 24) + "1", false},
+		{"全角数字", "密码密码密码密１", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Var(tt.password, "password"); (err == nil) != tt.valid {
+				t.Errorf("password(%q) 校验结果为 %v，期望有效 = %v", tt.password, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCustomValidators(t *testing.T) {
+	v := testValidator(t)
+	tests := []struct {
+		tag     string
+		valid   []string
+		invalid []string
+	}{
+		{
+			tag:     "username",
+			valid:   []string{"alice", "a_1", "Bob2024", "a" + strings.Repeat("b", 31)},
+			invalid: []string{"ab", "1abc", "_abc", "al ice", "alice!", "a" + strings.Repeat("b", 32), "名字abc"},
+		},
+		{
+			tag:     "maxcontent=post",
+			valid:   []string{"", "0123456789", strings.Repeat("文", 10)},
+			invalid: []string{"01234567890", strings.Repeat("文", 11)},
+		},
+		{
+			tag:     "maxcontent=comment",
+			valid:   []string{"12345", "评论评论评"},
+			invalid: []string{"123456", "评论评论评论"},
+		},
+		{
+			tag:     "locale",
+			valid:   []string{LocaleZhCN, LocaleEnUS},
+			invalid: []string{"", "zh", "en", "fr-FR", "zh-cn"},
+		},
+		{
+			tag:     "apiscope",
+			valid:   apiKeyScopes,
+			invalid: []string{"", "root", "posts", "READ"},
+		},
+		{
+			tag:     "httpurl",
+			valid:   []string{"http://example.com", "https://example.com/a?b=c#d", "https://例子.测试/"},
+			invalid: []string{"", "example.com", "//example.com", "https://", "javascript:alert(1)", "ftp://example.com", "data:text/html,x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			for _, s := range tt.valid {
+				if err := v.Var(s, tt.tag); err != nil {
+					t.Errorf("%q 应通过 %s 校验: %v", s, tt.tag, err)
+				}
+			}
+			for _, s := range tt.invalid {
+				if err := v.Var(s, tt.tag); err == nil {
+					t.Errorf("%q 不应通过 %s 校验", s, tt.tag)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomValidatorTranslations(t *testing.T) {
+	v := testValidator(t)
+	var sample struct {
+		Username string `json:"username" binding:"username"`
+		Password string `json:"password" binding:"password"`
+		Content  string `json:"content" binding:"maxcontent=comment"`
+		Locale   string `json:"locale" binding:"locale"`
+		Scope    string `json:"scope" binding:"apiscope"`
+		Website  string `json:"website" binding:"httpurl"`
+	}
+	sample.Content = "超过五个字符的评论"
+	sample.Website = "javascript:alert(1)"
+
+	var verrs validator.ValidationErrors
+	if err := v.Struct(&sample); !errors.As(err, &verrs) {
+		t.Fatalf("应返回校验错误: %v", err)
+	}
+	want := map[string]map[string]string{
+		LocaleZhCN: {
+			"username": "username必须以字母开头，只能包含字母、数字和下划线，长度为3到32个字符",
+			"password": "password至少需要8个字符，且必须同时包含字母和数字",
+			"content":  "content不能超过5个字符",
+			"locale":   "locale必须是以下语言之一：zh-CN, en-US",
+			"scope":    "scope必须是以下权限范围之一：" + strings.Join(apiKeyScopes, ", "),
+			"website":  "website必须是以 http:// 或 https:// 开头的网址",
+		},
+		LocaleEnUS: {
+			"username": "username must start with a letter and contain only letters, digits and underscores (3-32 characters)",
+			"password": "password must be at least 8 characters long and contain both letters and digits",
+			"content":  "content must not exceed 5 characters",
+			"locale":   "locale must be one of: zh-CN, en-US",
+			"scope":    "scope must be one of: " + strings.Join(apiKeyScopes, ", "),
+			"website":  "website must be a URL starting with http:// or https://",
+		},
+	}
+	if len(verrs) != len(want[LocaleZhCN]) {
+		t.Fatalf("应有 %d 个字段校验失败，实际 %d 个: %v", len(want[LocaleZhCN]), len(verrs), verrs)
+	}
+	for locale, messages := range want {
+		for _, fe := range verrs {
+			if got := fe.Translate(validTranslators[locale]); got != messages[fe.Field()] {
+				t.Errorf("[%s] %s 的提示为 %q，期望 %q", locale, fe.Field(), got, messages[fe.Field()])
+			}
+		}
+	}
+}
+
+// 接口返回的字段错误按 Accept-Language 翻译
+func TestValidationErrorResponse(t *testing.T) {
+	r := setupTestApp(t)
+	body := map[string]string{"username": "1abc", "password": "short", "email": "a@example.com"}
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"en-GB,en;q=0.9", "username must start with a letter"},
+		{"zh-CN", "username必须以字母开头"},
+	}
+	for _, tt := range tests {
+		w := doJSON(r, http.MethodPost, "/api/v1/register", "", body, "Accept-Language", tt.acceptLanguage)
+		if w.Code != http.StatusBadRequest || errorCode(t, w) != CodeValidationFailed {
+			t.Fatalf("应返回校验错误: %d %s", w.Code, w.Body.String())
+		}
+		details := decodeJSON(t, w)["error"].(map[string]interface{})["details"].([]interface{})
+		rules := make(map[string]string)
+		for _, d := range details {
+			field := d.(map[string]interface{})
+			rules[field["field"].(string)] = field["message"].(string)
+		}
+		if !strings.HasPrefix(rules["username"], tt.want) {
+			t.Errorf("[%s] username 的提示为 %q，期望以 %q 开头", tt.acceptLanguage, rules["username"], tt.want)
+		}
+		if _, ok := rules["password"]; !ok {
+			t.Errorf("[%s] 应包含 password 的校验错误: %v", tt.acceptLanguage, rules)
+		}
+	}
+}